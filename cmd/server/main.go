@@ -12,11 +12,16 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	jwtaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/jwt_access"
+	mtlsaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/mtls_access"
+	virtualkeys "github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtual_keys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypto"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -120,6 +125,7 @@ func main() {
 		pgStoreDSN           string
 		pgStoreSchema        string
 		pgStoreLocalPath     string
+		pgStoreSharedState   bool
 		pgStoreInst          *store.PostgresStore
 		useGitStore          bool
 		gitStoreRemoteURL    string
@@ -171,6 +177,9 @@ func main() {
 		if value, ok := lookupEnv("PGSTORE_LOCAL_PATH", "pgstore_local_path"); ok {
 			pgStoreLocalPath = value
 		}
+		if value, ok := lookupEnv("PGSTORE_SHARED_STATE", "pgstore_shared_state"); ok {
+			pgStoreSharedState, _ = strconv.ParseBool(value)
+		}
 		if pgStoreLocalPath == "" {
 			if writableBase != "" {
 				pgStoreLocalPath = writableBase
@@ -210,6 +219,27 @@ func main() {
 		objectStoreLocalPath = value
 	}
 
+	// Encrypt stored auth records at rest when a key is configured. Listing the previous keys
+	// keeps rotated records readable until they are re-encrypted on load.
+	var encryptionKey, encryptionKeyFile, encryptionPreviousKeys string
+	if value, ok := lookupEnv("AUTH_ENCRYPTION_KEY", "auth_encryption_key"); ok {
+		encryptionKey = value
+	}
+	if value, ok := lookupEnv("AUTH_ENCRYPTION_KEY_FILE", "auth_encryption_key_file"); ok {
+		encryptionKeyFile = value
+	}
+	if value, ok := lookupEnv("AUTH_ENCRYPTION_PREVIOUS_KEYS", "auth_encryption_previous_keys"); ok {
+		encryptionPreviousKeys = value
+	}
+	keyring, errKeyring := authcrypto.LoadKeyring(encryptionKey, encryptionKeyFile, encryptionPreviousKeys)
+	if errKeyring != nil {
+		log.Fatalf("failed to load auth encryption key: %v", errKeyring)
+	}
+	if keyring != nil {
+		authcrypto.SetKeyring(keyring)
+		log.Infof("auth records are encrypted at rest (key %s)", keyring.PrimaryID())
+	}
+
 	// Check for cloud deploy mode only on first execution
 	// Read env var name in uppercase: DEPLOY
 	deployEnv := os.Getenv("DEPLOY")
@@ -227,9 +257,10 @@ func main() {
 		pgStoreLocalPath = filepath.Join(pgStoreLocalPath, "pgstore")
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		pgStoreInst, err = store.NewPostgresStore(ctx, store.PostgresStoreConfig{
-			DSN:      pgStoreDSN,
-			Schema:   pgStoreSchema,
-			SpoolDir: pgStoreLocalPath,
+			DSN:         pgStoreDSN,
+			Schema:      pgStoreSchema,
+			SpoolDir:    pgStoreLocalPath,
+			SharedState: pgStoreSharedState,
 		})
 		cancel()
 		if err != nil {
@@ -424,6 +455,9 @@ func main() {
 
 	// Register built-in access providers before constructing services.
 	configaccess.Register()
+	virtualkeys.Register()
+	jwtaccess.Register()
+	mtlsaccess.Register()
 
 	// Handle different command modes based on the provided flags.
 
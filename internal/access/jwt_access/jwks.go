@@ -0,0 +1,238 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefreshGap throttles reloads, whether due to the refresh interval or triggered by tokens
+// signed with an unknown key ID, so a failing or slow issuer is not hit on every request.
+const minRefreshGap = time.Minute
+
+// jwk is a single JSON Web Key; only the members needed for signature keys are decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type verificationKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet loads a JWKS from a file or URL and reloads it periodically or when a token names a
+// key it does not know, which is how issuers roll their keys. Concurrent reloads collapse into
+// one fetch.
+type keySet struct {
+	file     string
+	url      string
+	interval time.Duration
+	client   *http.Client
+	group    singleflight.Group
+
+	mu        sync.RWMutex
+	keys      []verificationKey
+	loadedAt  time.Time
+	attempted time.Time
+}
+
+func newKeySet(file, url string, interval time.Duration) *keySet {
+	return &keySet{file: file, url: url, interval: interval, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// lookup returns the keys that may verify a token with the given key ID and algorithm. A
+// stale key set keeps serving while it reloads in the background; only a token naming a key
+// that is not cached waits for the reload.
+func (s *keySet) lookup(ctx context.Context, kid, alg string) []verificationKey {
+	now := time.Now()
+	s.mu.RLock()
+	keys := matchKeys(s.keys, kid, alg)
+	stale := now.Sub(s.loadedAt) >= s.interval
+	due := now.Sub(s.attempted) >= minRefreshGap
+	s.mu.RUnlock()
+	switch {
+	case !due:
+	case len(keys) == 0:
+		if err := s.refresh(ctx); err != nil {
+			log.Warnf("jwt access: failed to load key set: %v", err)
+		}
+		s.mu.RLock()
+		keys = matchKeys(s.keys, kid, alg)
+		s.mu.RUnlock()
+	case stale:
+		s.group.DoChan("refresh", func() (any, error) {
+			if err := s.load(context.WithoutCancel(ctx)); err != nil {
+				log.Warnf("jwt access: failed to reload key set: %v", err)
+			}
+			return nil, nil
+		})
+	}
+	return keys
+}
+
+// refresh reloads the key set, joining a reload that is already running.
+func (s *keySet) refresh(ctx context.Context) error {
+	_, err, _ := s.group.Do("refresh", func() (any, error) {
+		return nil, s.load(ctx)
+	})
+	return err
+}
+
+func matchKeys(keys []verificationKey, kid, alg string) []verificationKey {
+	var out []verificationKey
+	for _, key := range keys {
+		if kid != "" && key.kid != "" && key.kid != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		out = append(out, key)
+	}
+	return out
+}
+
+// load fetches and parses the key set unless another load was attempted within minRefreshGap.
+// A failed load keeps the previous keys.
+func (s *keySet) load(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.attempted.IsZero() && time.Since(s.attempted) < minRefreshGap {
+		s.mu.Unlock()
+		return nil
+	}
+	s.attempted = time.Now()
+	s.mu.Unlock()
+	raw, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *keySet) fetch(ctx context.Context) ([]byte, error) {
+	if s.file != "" {
+		return os.ReadFile(s.file)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: unexpected status %d", s.url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseJWKS decodes the RSA and EC signature keys of a key set and skips everything else.
+func parseJWKS(raw []byte) ([]verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("decode key set: %w", err)
+	}
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Debugf("jwt access: skipping key %q: %v", k.Kid, err)
+			continue
+		}
+		keys = append(keys, verificationKey{kid: k.Kid, alg: k.Alg, key: pub})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key set holds no usable signature keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,338 @@
+// Package jwtaccess validates bearer JWTs issued by an external identity provider so the proxy
+// can sit behind single sign-on instead of distributing static keys.
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPrincipalClaim  = "sub"
+	defaultClockSkew       = time.Minute
+	defaultRefreshInterval = time.Hour
+)
+
+var registerOnce sync.Once
+
+// Register ensures the jwt provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(sdkconfig.AccessProviderTypeJWT, newProvider)
+	})
+}
+
+type provider struct {
+	name           string
+	issuer         string
+	audiences      []string
+	principalClaim string
+	metadataClaims []string
+	clockSkew      time.Duration
+	keys           *keySet
+}
+
+func newProvider(cfg *sdkconfig.AccessProvider, root *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	var settings sdkconfig.JWTAuthConfig
+	switch v := cfg.Config[sdkconfig.JWTAccessProviderConfigKey].(type) {
+	case sdkconfig.JWTAuthConfig:
+		settings = v
+	case *sdkconfig.JWTAuthConfig:
+		settings = *v
+	default:
+		if root == nil || root.JWTAuth == nil {
+			return nil, fmt.Errorf("jwt access: missing configuration")
+		}
+		settings = *root.JWTAuth
+	}
+	file := strings.TrimSpace(settings.JWKSFile)
+	url := strings.TrimSpace(settings.JWKSURL)
+	if file == "" && url == "" {
+		return nil, fmt.Errorf("jwt access: jwks-file or jwks-url is required")
+	}
+	// Without iss and aud checks any token signed by the key set would be accepted, including
+	// tokens the identity provider issued for other applications.
+	if strings.TrimSpace(settings.Issuer) == "" {
+		return nil, fmt.Errorf("jwt access: issuer is required")
+	}
+	p := &provider{
+		name:           cfg.Name,
+		issuer:         strings.TrimSpace(settings.Issuer),
+		principalClaim: strings.TrimSpace(settings.PrincipalClaim),
+		clockSkew:      defaultClockSkew,
+	}
+	if p.name == "" {
+		p.name = sdkconfig.AccessProviderTypeJWT
+	}
+	if p.principalClaim == "" {
+		p.principalClaim = defaultPrincipalClaim
+	}
+	if settings.ClockSkewSeconds > 0 {
+		p.clockSkew = time.Duration(settings.ClockSkewSeconds) * time.Second
+	}
+	for _, aud := range settings.Audiences {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			p.audiences = append(p.audiences, aud)
+		}
+	}
+	if len(p.audiences) == 0 {
+		return nil, fmt.Errorf("jwt access: at least one audience is required")
+	}
+	for _, claim := range settings.MetadataClaims {
+		if claim = strings.TrimSpace(claim); claim != "" {
+			p.metadataClaims = append(p.metadataClaims, claim)
+		}
+	}
+	interval := defaultRefreshInterval
+	if settings.RefreshIntervalSeconds > 0 {
+		interval = time.Duration(settings.RefreshIntervalSeconds) * time.Second
+	}
+	p.keys = newKeySet(file, url, interval)
+	if err := p.keys.refresh(context.Background()); err != nil {
+		// The issuer may be unreachable at startup; keys are fetched again on first use once
+		// the refresh throttle has passed.
+		log.Warnf("jwt access: failed to load key set: %v", err)
+	}
+	return p, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return sdkconfig.AccessProviderTypeJWT
+	}
+	return p.name
+}
+
+// Authenticate validates the bearer token. Bearer values that are not JWTs are left to the
+// other providers.
+func (p *provider) Authenticate(ctx context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if header == "" {
+		return nil, sdkaccess.ErrNoCredentials
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") || strings.Count(strings.TrimSpace(token), ".") != 2 {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	claims, err := p.verify(ctx, strings.TrimSpace(token), time.Now())
+	if err != nil {
+		log.Debugf("jwt access: rejected token: %v", err)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	principal := claimString(lookupClaim(claims, p.principalClaim))
+	if principal == "" {
+		log.Debugf("jwt access: token has no %s claim", p.principalClaim)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	metadata := map[string]string{"source": "authorization"}
+	for _, name := range p.metadataClaims {
+		if value := claimString(lookupClaim(claims, name)); value != "" {
+			metadata[name] = value
+		}
+	}
+	return &sdkaccess.Result{Provider: p.Identifier(), Principal: principal, Metadata: metadata}, nil
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks the signature and the registered claims and returns the claim set.
+func (p *provider) verify(ctx context.Context, token string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	hash, err := algorithmHash(header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	digest := hasher.Sum(nil)
+	verified := false
+	for _, key := range p.keys.lookup(ctx, header.Kid, header.Alg) {
+		if verifySignature(header.Alg, hash, key.key, digest, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("signature does not match any key")
+	}
+
+	var claims map[string]any
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	exp, ok := numericClaim(claims["exp"])
+	if !ok {
+		return nil, errors.New("missing exp")
+	}
+	if now.After(exp.Add(p.clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, hasNbf := numericClaim(claims["nbf"]); hasNbf && now.Add(p.clockSkew).Before(nbf) {
+		return nil, errors.New("token not yet valid")
+	}
+	if claims["iss"] != p.issuer {
+		return nil, fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	if !audienceMatches(claims["aud"], p.audiences) {
+		return nil, fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, out any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// algorithmHash maps the supported asymmetric algorithms to their digest. Symmetric and
+// unsigned tokens are refused.
+func algorithmHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, digest, signature []byte) bool {
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil
+		case "PS":
+			return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" {
+			return false
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+	return false
+}
+
+func numericClaim(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return time.Unix(n, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func audienceMatches(value any, accepted []string) bool {
+	var audiences []string
+	switch v := value.(type) {
+	case string:
+		audiences = []string{v}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	for _, aud := range audiences {
+		for _, want := range accepted {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupClaim resolves a claim name, treating dots as nested object access when the full name
+// is not a claim itself.
+func lookupClaim(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+	var current any = claims
+	for _, part := range strings.Split(name, ".") {
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = obj[part]
+	}
+	return current
+}
+
+// claimString renders a claim for Principal or Metadata; lists are comma separated.
+func claimString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return big.NewFloat(v).Text('f', -1)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := claimString(item); s != "" {
+				items = append(items, s)
+			}
+		}
+		return strings.Join(items, ",")
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(raw)
+	}
+}
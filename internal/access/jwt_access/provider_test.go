@@ -0,0 +1,219 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	signingInput := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	signingInput := encodeSegment(t, map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func b64(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+
+func TestJWTProviderValidatesTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	jwks := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+	}}
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	raw, _ := json.Marshal(jwks)
+	if err = os.WriteFile(jwksPath, raw, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	settings := sdkconfig.JWTAuthConfig{
+		JWKSFile:       jwksPath,
+		Issuer:         "https://sso.example.com/",
+		Audiences:      []string{"cli-proxy-api"},
+		PrincipalClaim: "email",
+		MetadataClaims: []string{"groups", "org.id"},
+	}
+	root := &sdkconfig.SDKConfig{JWTAuth: &settings}
+	p, err := newProvider(root.RuntimeAccessProviders()[0], root)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+
+	now := time.Now()
+	valid := map[string]any{
+		"iss":    "https://sso.example.com/",
+		"aud":    []string{"other", "cli-proxy-api"},
+		"exp":    now.Add(time.Hour).Unix(),
+		"email":  "dev@example.com",
+		"groups": []string{"eng", "admins"},
+		"org":    map[string]any{"id": "acme"},
+	}
+	authenticate := func(token string) (*sdkaccess.Result, error) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return p.Authenticate(context.Background(), req)
+	}
+
+	result, err := authenticate(signRS256(t, rsaKey, "rsa-1", valid))
+	if err != nil {
+		t.Fatalf("authenticate rs256: %v", err)
+	}
+	if result.Principal != "dev@example.com" || result.Metadata["groups"] != "eng,admins" || result.Metadata["org.id"] != "acme" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if _, err = authenticate(signES256(t, ecKey, "ec-1", valid)); err != nil {
+		t.Fatalf("authenticate es256: %v", err)
+	}
+
+	with := func(key string, value any) map[string]any {
+		claims := make(map[string]any, len(valid))
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rejected := map[string]string{
+		"expired":        signRS256(t, rsaKey, "rsa-1", with("exp", now.Add(-time.Hour).Unix())),
+		"wrong issuer":   signRS256(t, rsaKey, "rsa-1", with("iss", "https://evil.example.com/")),
+		"wrong audience": signRS256(t, rsaKey, "rsa-1", with("aud", "someone-else")),
+		"unknown key":    signRS256(t, otherKey, "rsa-1", valid),
+		"no principal":   signRS256(t, rsaKey, "rsa-1", with("email", nil)),
+	}
+	for name, token := range rejected {
+		if _, err = authenticate(token); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+			t.Fatalf("%s: expected an invalid credential, got %v", name, err)
+		}
+	}
+	if _, err = authenticate("sk-static-key"); !errors.Is(err, sdkaccess.ErrNotHandled) {
+		t.Fatalf("expected static keys to be left to other providers, got %v", err)
+	}
+}
+
+func TestJWTProviderRequiresIssuerAndAudience(t *testing.T) {
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksPath, []byte(`{"keys":[]}`), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	cases := map[string]sdkconfig.JWTAuthConfig{
+		"no issuer":   {JWKSFile: jwksPath, Audiences: []string{"cli-proxy-api"}},
+		"no audience": {JWKSFile: jwksPath, Issuer: "https://sso.example.com/", Audiences: []string{" "}},
+	}
+	for name, settings := range cases {
+		root := &sdkconfig.SDKConfig{JWTAuth: &settings}
+		if _, err := newProvider(root.RuntimeAccessProviders()[0], root); err == nil {
+			t.Fatalf("%s: expected a configuration error", name)
+		}
+	}
+}
+
+func TestKeySetServesStaleKeysWhileRefreshing(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	raw, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+	}})
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		_, _ = w.Write(raw)
+	}))
+	defer srv.Close()
+
+	keys := newKeySet("", srv.URL, time.Millisecond)
+	if got := keys.lookup(context.Background(), "rsa-1", "RS256"); len(got) != 1 {
+		t.Fatalf("expected the key after the first load, got %d", len(got))
+	}
+
+	// Once the throttle has passed, a stale set keeps serving while one reload runs.
+	keys.mu.Lock()
+	keys.attempted = time.Now().Add(-minRefreshGap)
+	keys.mu.Unlock()
+	time.Sleep(2 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := keys.lookup(context.Background(), "rsa-1", "RS256"); len(got) != 1 {
+				t.Errorf("expected the cached key during the reload, got %d", len(got))
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	// Unknown key IDs within the throttle window do not hit the issuer again.
+	for i := 0; i < 4; i++ {
+		if got := keys.lookup(context.Background(), "rsa-2", "RS256"); len(got) != 0 {
+			t.Fatalf("expected no key for an unknown key ID, got %d", len(got))
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for fetches.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("expected 2 fetches, got %d", got)
+	}
+}
@@ -0,0 +1,136 @@
+// Package mtlsaccess authenticates service-to-service callers by the client certificate they
+// presented during the TLS handshake, so they do not need bearer keys.
+package mtlsaccess
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// Principal sources accepted in MTLSAuthConfig.PrincipalFrom.
+const (
+	PrincipalSubjectCN = "subject-cn"
+	PrincipalSANDNS    = "san-dns"
+	PrincipalSANURI    = "san-uri"
+	PrincipalSANEmail  = "san-email"
+)
+
+var registerOnce sync.Once
+
+// Register ensures the mtls provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(sdkconfig.AccessProviderTypeMTLS, newProvider)
+	})
+}
+
+type provider struct {
+	name    string
+	from    string
+	allowed []string
+}
+
+func newProvider(cfg *sdkconfig.AccessProvider, root *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	var settings sdkconfig.MTLSAuthConfig
+	switch v := cfg.Config[sdkconfig.MTLSAccessProviderConfigKey].(type) {
+	case sdkconfig.MTLSAuthConfig:
+		settings = v
+	case *sdkconfig.MTLSAuthConfig:
+		settings = *v
+	default:
+		if root != nil && root.MTLSAuth != nil {
+			settings = *root.MTLSAuth
+		}
+	}
+	from := strings.ToLower(strings.TrimSpace(settings.PrincipalFrom))
+	switch from {
+	case "":
+		from = PrincipalSubjectCN
+	case PrincipalSubjectCN, PrincipalSANDNS, PrincipalSANURI, PrincipalSANEmail:
+	default:
+		return nil, fmt.Errorf("mtls access: unsupported principal-from %q", settings.PrincipalFrom)
+	}
+	p := &provider{name: cfg.Name, from: from}
+	if p.name == "" {
+		p.name = sdkconfig.AccessProviderTypeMTLS
+	}
+	for _, pattern := range settings.AllowedPrincipals {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			p.allowed = append(p.allowed, pattern)
+		}
+	}
+	return p, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return sdkconfig.AccessProviderTypeMTLS
+	}
+	return p.name
+}
+
+// Authenticate accepts requests whose client certificate chain was verified by the listener.
+// Unverified certificates never reach this point because the TLS handshake rejects them.
+func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, sdkaccess.ErrNoCredentials
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	principal := p.principal(cert)
+	if principal == "" || !p.allows(principal) {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	return &sdkaccess.Result{
+		Provider:  p.Identifier(),
+		Principal: principal,
+		Metadata: map[string]string{
+			"source":  "client-certificate",
+			"subject": cert.Subject.String(),
+			"issuer":  cert.Issuer.String(),
+			"serial":  cert.SerialNumber.String(),
+		},
+	}, nil
+}
+
+func (p *provider) principal(cert *x509.Certificate) string {
+	switch p.from {
+	case PrincipalSANDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case PrincipalSANURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case PrincipalSANEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	default:
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+func (p *provider) allows(principal string) bool {
+	if len(p.allowed) == 0 {
+		return true
+	}
+	for _, pattern := range p.allowed {
+		if util.MatchModelPattern(pattern, principal) {
+			return true
+		}
+	}
+	return false
+}
@@ -183,6 +183,11 @@ func accessProviderMap(cfg *config.Config) map[string]*sdkConfig.AccessProvider
 			}
 		}
 	}
+	for _, provider := range cfg.RuntimeAccessProviders() {
+		if key := providerIdentifier(provider); key != "" {
+			result[key] = provider
+		}
+	}
 	return result
 }
 
@@ -202,7 +207,7 @@ func collectProviderEntries(cfg *config.Config) []*sdkConfig.AccessProvider {
 			entries = append(entries, inline)
 		}
 	}
-	return entries
+	return append(entries, cfg.RuntimeAccessProviders()...)
 }
 
 func providerIdentifier(provider *sdkConfig.AccessProvider) string {
@@ -0,0 +1,131 @@
+package virtualkeys
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+const (
+	// MetadataScopes is the access metadata entry holding the comma separated scopes of the key.
+	MetadataScopes = "scopes"
+	// MetadataModels is the access metadata entry holding the comma separated model patterns
+	// the key may call.
+	MetadataModels = "models"
+)
+
+var registerOnce sync.Once
+
+// Register ensures the virtual key provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(sdkconfig.AccessProviderTypeVirtualKey, newProvider)
+	})
+}
+
+type provider struct {
+	name     string
+	registry *Registry
+}
+
+func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	name := cfg.Name
+	if name == "" {
+		name = sdkconfig.VirtualKeyAccessProviderName
+	}
+	return &provider{name: name, registry: Default()}, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return sdkconfig.VirtualKeyAccessProviderName
+	}
+	return p.name
+}
+
+// Authenticate accepts requests carrying an enabled, unexpired virtual key. The principal is
+// the key ID so policies, rate limits and usage statistics never see the secret.
+func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil || p.registry == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	candidates := credentialCandidates(r)
+	if len(candidates) == 0 {
+		return nil, sdkaccess.ErrNoCredentials
+	}
+	handled := false
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate.value, SecretPrefix) {
+			continue
+		}
+		handled = true
+		key, err := p.registry.Validate(candidate.value, time.Now())
+		if err != nil {
+			continue
+		}
+		metadata := KeyMetadata(key)
+		metadata["source"] = candidate.source
+		return &sdkaccess.Result{Provider: p.Identifier(), Principal: key.ID, Metadata: metadata}, nil
+	}
+	if !handled {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	return nil, sdkaccess.ErrInvalidCredential
+}
+
+// KeyMetadata returns the access metadata describing key: its owner, model patterns and
+// scopes.
+func KeyMetadata(key Key) map[string]string {
+	metadata := make(map[string]string, 3)
+	if key.Owner != "" {
+		metadata["owner"] = key.Owner
+	}
+	if len(key.Models) > 0 {
+		metadata[MetadataModels] = strings.Join(key.Models, ",")
+	}
+	if len(key.Scopes) > 0 {
+		metadata[MetadataScopes] = strings.Join(key.Scopes, ",")
+	}
+	return metadata
+}
+
+type credential struct {
+	value  string
+	source string
+}
+
+// credentialCandidates collects the key from the same places the inline provider reads it.
+func credentialCandidates(r *http.Request) []credential {
+	candidates := []credential{
+		{bearerToken(r.Header.Get("Authorization")), "authorization"},
+		{r.Header.Get("X-Goog-Api-Key"), "x-goog-api-key"},
+		{r.Header.Get("X-Api-Key"), "x-api-key"},
+	}
+	if r.URL != nil {
+		query := r.URL.Query()
+		candidates = append(candidates,
+			credential{query.Get("key"), "query-key"},
+			credential{query.Get("auth_token"), "query-auth-token"},
+		)
+	}
+	out := candidates[:0]
+	for _, candidate := range candidates {
+		if candidate.value != "" {
+			out = append(out, candidate)
+		}
+	}
+	return out
+}
+
+func bearerToken(header string) string {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		return strings.TrimSpace(parts[1])
+	}
+	return header
+}
@@ -0,0 +1,387 @@
+// Package virtualkeys issues client API keys at runtime and validates requests against them.
+// Only a hash of each key is kept; the key itself is returned once, when it is created.
+package virtualkeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// SecretPrefix starts every issued key so the provider can tell virtual keys apart.
+const SecretPrefix = "cpk-"
+
+// displayPrefixLength is how much of a key is kept in clear to help identify it.
+const displayPrefixLength = len(SecretPrefix) + 6
+
+var (
+	// ErrKeyNotFound reports an unknown key ID or secret.
+	ErrKeyNotFound = errors.New("virtual key not found")
+	// ErrKeyDisabled reports a key that was disabled.
+	ErrKeyDisabled = errors.New("virtual key disabled")
+	// ErrKeyExpired reports a key past its expiry.
+	ErrKeyExpired = errors.New("virtual key expired")
+	// ErrInvalidModelPattern reports a model pattern the policy matcher cannot use.
+	ErrInvalidModelPattern = errors.New("invalid model pattern")
+)
+
+// Key describes an issued client key. It never carries the secret. Models lists the model
+// patterns the key may call, with '*' wildcards as in api-key-policies; Scopes are free-form
+// labels handed to the request metadata and carry no meaning for the proxy itself.
+type Key struct {
+	ID          string     `json:"id"`
+	Owner       string     `json:"owner,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Prefix      string     `json:"prefix"`
+	Models      []string   `json:"models,omitempty"`
+	Scopes      []string   `json:"scopes,omitempty"`
+	Enabled     bool       `json:"enabled"`
+	CreatedAt   time.Time  `json:"created-at"`
+	ExpiresAt   *time.Time `json:"expires-at,omitempty"`
+}
+
+// Expired reports whether the key is past its expiry at now.
+func (k Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+func (k Key) clone() Key {
+	k.Models = append([]string(nil), k.Models...)
+	k.Scopes = append([]string(nil), k.Scopes...)
+	if k.ExpiresAt != nil {
+		expiresAt := *k.ExpiresAt
+		k.ExpiresAt = &expiresAt
+	}
+	return k
+}
+
+// record is the persisted form of a key.
+type record struct {
+	Key
+	SecretHash string `json:"secret-hash"`
+}
+
+// Store persists the keys. The token stores implement it next to the auth records.
+type Store interface {
+	LoadVirtualKeys(ctx context.Context) ([]byte, error)
+	SaveVirtualKeys(ctx context.Context, data []byte) error
+}
+
+// Registry holds the issued keys and keeps them in sync with the store.
+type Registry struct {
+	mu      sync.RWMutex
+	records map[string]*record
+	hashes  map[string]string
+	store   Store
+	cancel  context.CancelFunc
+
+	// writeMu serialises read-modify-write cycles against the store.
+	writeMu sync.Mutex
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process-wide registry used by the access provider and management API.
+func Default() *Registry { return defaultRegistry }
+
+// NewRegistry constructs an empty registry without persistence.
+func NewRegistry() *Registry {
+	return &Registry{records: make(map[string]*record), hashes: make(map[string]string)}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("virtual keys: generate secret: %w", err)
+	}
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Start loads the keys from the store and reloads them every interval so keys revoked by
+// another replica stop working here too.
+func (r *Registry) Start(ctx context.Context, store Store, interval time.Duration) {
+	if store == nil {
+		return
+	}
+	r.Stop()
+	loopCtx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	r.store = store
+	r.cancel = cancel
+	r.mu.Unlock()
+	if err := r.Reload(ctx); err != nil {
+		log.Warnf("failed to load virtual keys: %v", err)
+	}
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-loopCtx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Reload(loopCtx); err != nil {
+					log.Warnf("failed to reload virtual keys: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the periodic reload loop.
+func (r *Registry) Stop() {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.cancel = nil
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Reload replaces the in-memory keys with the content of the store.
+func (r *Registry) Reload(ctx context.Context) error {
+	r.mu.RLock()
+	store := r.store
+	r.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+	data, err := store.LoadVirtualKeys(ctx)
+	if err != nil {
+		return err
+	}
+	var records []*record
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("virtual keys: decode: %w", err)
+		}
+	}
+	r.mu.Lock()
+	r.setLocked(records)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registry) setLocked(records []*record) {
+	r.records = make(map[string]*record, len(records))
+	r.hashes = make(map[string]string, len(records))
+	for _, rec := range records {
+		if rec == nil || rec.ID == "" || rec.SecretHash == "" {
+			continue
+		}
+		r.records[rec.ID] = rec
+		r.hashes[rec.SecretHash] = rec.ID
+	}
+}
+
+// snapshotLocked returns the records ordered by creation time.
+func (r *Registry) snapshotLocked() []*record {
+	out := make([]*record, 0, len(r.records))
+	for _, rec := range r.records {
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// mutate reloads the store, applies fn to a copy of the records and persists the result. The
+// in-memory keys change only once the store accepted them.
+func (r *Registry) mutate(ctx context.Context, fn func(records map[string]*record) error) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if err := r.Reload(ctx); err != nil {
+		return err
+	}
+	r.mu.RLock()
+	records := make(map[string]*record, len(r.records))
+	for id, rec := range r.records {
+		copied := *rec
+		copied.Key = rec.Key.clone()
+		records[id] = &copied
+	}
+	store := r.store
+	r.mu.RUnlock()
+	if err := fn(records); err != nil {
+		return err
+	}
+	next := &Registry{records: records}
+	ordered := next.snapshotLocked()
+	if store != nil {
+		var raw []byte
+		if len(ordered) > 0 {
+			var err error
+			if raw, err = json.Marshal(ordered); err != nil {
+				return fmt.Errorf("virtual keys: encode: %w", err)
+			}
+		}
+		if err := store.SaveVirtualKeys(ctx, raw); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	r.setLocked(ordered)
+	r.mu.Unlock()
+	return nil
+}
+
+// List returns the keys ordered by creation time.
+func (r *Registry) List() []Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ordered := r.snapshotLocked()
+	out := make([]Key, 0, len(ordered))
+	for _, rec := range ordered {
+		out = append(out, rec.Key.clone())
+	}
+	return out
+}
+
+// Get returns the key with the given ID.
+func (r *Registry) Get(id string) (Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rec, ok := r.records[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return rec.Key.clone(), nil
+}
+
+// Create issues a new key from the template and returns it along with its secret. The ID,
+// prefix and creation time of the template are ignored.
+func (r *Registry) Create(ctx context.Context, template Key) (Key, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return Key{}, "", err
+	}
+	key := template.clone()
+	key.ID = uuid.NewString()
+	key.Prefix = secret[:displayPrefixLength]
+	key.CreatedAt = time.Now().UTC()
+	key.Scopes = normalizeScopes(key.Scopes)
+	if key.Models, err = normalizeModels(key.Models); err != nil {
+		return Key{}, "", err
+	}
+	err = r.mutate(ctx, func(records map[string]*record) error {
+		records[key.ID] = &record{Key: key, SecretHash: hashSecret(secret)}
+		return nil
+	})
+	if err != nil {
+		return Key{}, "", err
+	}
+	return key.clone(), secret, nil
+}
+
+// Update applies fn to the key with the given ID and persists the result. It fails with
+// ErrInvalidModelPattern when fn leaves an unusable model pattern behind.
+func (r *Registry) Update(ctx context.Context, id string, fn func(key *Key)) (Key, error) {
+	var updated Key
+	err := r.mutate(ctx, func(records map[string]*record) error {
+		rec, ok := records[id]
+		if !ok {
+			return ErrKeyNotFound
+		}
+		key := rec.Key
+		fn(&key)
+		// The identity of a key is fixed at creation.
+		key.ID, key.Prefix, key.CreatedAt = rec.ID, rec.Prefix, rec.CreatedAt
+		key.Scopes = normalizeScopes(key.Scopes)
+		models, err := normalizeModels(key.Models)
+		if err != nil {
+			return err
+		}
+		key.Models = models
+		rec.Key = key
+		updated = key.clone()
+		return nil
+	})
+	return updated, err
+}
+
+// Delete revokes the key with the given ID.
+func (r *Registry) Delete(ctx context.Context, id string) error {
+	return r.mutate(ctx, func(records map[string]*record) error {
+		if _, ok := records[id]; !ok {
+			return ErrKeyNotFound
+		}
+		delete(records, id)
+		return nil
+	})
+}
+
+// Validate returns the key matching the secret when it is enabled and not expired.
+func (r *Registry) Validate(secret string, now time.Time) (Key, error) {
+	hash := hashSecret(secret)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.hashes[hash]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	rec := r.records[id]
+	if !rec.Enabled {
+		return Key{}, ErrKeyDisabled
+	}
+	if rec.Expired(now) {
+		return Key{}, ErrKeyExpired
+	}
+	return rec.Key.clone(), nil
+}
+
+func normalizeScopes(scopes []string) []string {
+	out := make([]string, 0, len(scopes))
+	seen := make(map[string]struct{}, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		out = append(out, scope)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// normalizeModels drops blank and duplicate model patterns. Patterns travel comma separated
+// in the request metadata, so commas and whitespace inside a pattern are rejected.
+func normalizeModels(models []string) ([]string, error) {
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		if strings.ContainsAny(model, ", \t\r\n") {
+			return nil, fmt.Errorf("%w %q", ErrInvalidModelPattern, model)
+		}
+	}
+	return normalizeScopes(models), nil
+}
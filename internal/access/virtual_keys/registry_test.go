@@ -0,0 +1,84 @@
+package virtualkeys
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+type memoryStore struct {
+	data []byte
+}
+
+func (s *memoryStore) LoadVirtualKeys(context.Context) ([]byte, error) { return s.data, nil }
+
+func (s *memoryStore) SaveVirtualKeys(_ context.Context, data []byte) error {
+	s.data = append([]byte(nil), data...)
+	return nil
+}
+
+func TestVirtualKeyLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	registry := NewRegistry()
+	registry.Start(ctx, store, 0)
+
+	if _, _, err := registry.Create(ctx, Key{Enabled: true, Models: []string{"gemini-*,gpt-*"}}); !errors.Is(err, ErrInvalidModelPattern) {
+		t.Fatalf("expected a model pattern with a comma to be rejected, got %v", err)
+	}
+	key, secret, err := registry.Create(ctx, Key{Owner: "team-a", Enabled: true, Models: []string{"gemini-*", " "}, Scopes: []string{"batch"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(secret, key.Prefix) || strings.Contains(string(store.data), secret) {
+		t.Fatal("expected only the hash of the secret to be persisted")
+	}
+	if len(key.Models) != 1 {
+		t.Fatalf("expected blank model patterns to be dropped, got %v", key.Models)
+	}
+
+	p := &provider{name: "virtual-keys", registry: registry}
+	req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	result, err := p.Authenticate(ctx, req)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if result.Principal != key.ID || result.Metadata[MetadataModels] != "gemini-*" || result.Metadata[MetadataScopes] != "batch" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	other := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	other.Header.Set("Authorization", "Bearer sk-config-key")
+	if _, err = p.Authenticate(ctx, other); !errors.Is(err, sdkaccess.ErrNotHandled) {
+		t.Fatalf("expected keys of other providers to be left alone, got %v", err)
+	}
+
+	// A second replica sharing the store sees the revocation on its next reload.
+	replica := NewRegistry()
+	replica.Start(ctx, store, 0)
+	expired := time.Now().Add(-time.Minute)
+	if _, err = registry.Update(ctx, key.ID, func(k *Key) { k.ExpiresAt = &expired }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err = registry.Validate(secret, time.Now()); !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("expected the key to be expired, got %v", err)
+	}
+	if err = registry.Delete(ctx, key.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err = replica.Validate(secret, time.Now()); err != nil {
+		t.Fatalf("expected the replica to accept the key before reloading, got %v", err)
+	}
+	if err = replica.Reload(ctx); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err = replica.Validate(secret, time.Now()); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected the revoked key to be rejected, got %v", err)
+	}
+}
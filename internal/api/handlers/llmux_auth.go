@@ -8,7 +8,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/llmux"
-	log "github.com/sirupsen/logrus"
 )
 
 // LLMuxAuthHandler handles OAuth for LLMux providers
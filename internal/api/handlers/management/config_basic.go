@@ -16,9 +16,9 @@ func (h *Handler) GetConfig(c *gin.Context) {
 		c.JSON(200, gin.H{})
 		return
 	}
-	cfgCopy := *h.cfg
-	cfgCopy.GlAPIKey = geminiKeyStringsFromConfig(h.cfg)
-	c.JSON(200, &cfgCopy)
+	cfgCopy := h.cfg.WithSecretRefs()
+	cfgCopy.GlAPIKey = geminiKeyStringsFromConfig(cfgCopy)
+	c.JSON(200, cfgCopy)
 }
 
 func (h *Handler) GetConfigYAML(c *gin.Context) {
@@ -68,6 +68,14 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": err.Error()})
 		return
 	}
+	// Validation resolves secret references, so only those already in the config file pass.
+	h.mu.Lock()
+	err = cfg.CheckSecretRefs(h.cfg)
+	h.mu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_config", "message": err.Error()})
+		return
+	}
 	// Validate config using LoadConfigOptional with optional=false to enforce parsing
 	tmpDir := filepath.Dir(h.configFilePath)
 	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
@@ -142,6 +150,14 @@ func (h *Handler) PutUsageStatisticsEnabled(c *gin.Context) {
 	h.updateBoolField(c, func(v bool) { h.cfg.UsageStatisticsEnabled = v })
 }
 
+// VirtualKeys
+func (h *Handler) GetVirtualKeysEnabled(c *gin.Context) {
+	c.JSON(200, gin.H{"virtual-keys": h.cfg.VirtualKeys})
+}
+func (h *Handler) PutVirtualKeysEnabled(c *gin.Context) {
+	h.updateBoolField(c, func(v bool) { h.cfg.VirtualKeys = v })
+}
+
 // UsageStatisticsEnabled
 func (h *Handler) GetLoggingToFile(c *gin.Context) {
 	c.JSON(200, gin.H{"logging-to-file": h.cfg.LoggingToFile})
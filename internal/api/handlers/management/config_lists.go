@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 )
 
 // Generic helpers for list[string]
@@ -55,7 +56,7 @@ func (h *Handler) patchStringList(c *gin.Context, target *[]string, after func()
 	}
 	if body.Old != nil && body.New != nil {
 		for i := range *target {
-			if (*target)[i] == *body.Old {
+			if (*target)[i] == h.cfg.ResolveSecretValue(*body.Old) {
 				(*target)[i] = *body.New
 				if after != nil {
 					after()
@@ -87,7 +88,7 @@ func (h *Handler) deleteFromStringList(c *gin.Context, target *[]string, after f
 			return
 		}
 	}
-	if val := strings.TrimSpace(c.Query("value")); val != "" {
+	if val := h.cfg.ResolveSecretValue(strings.TrimSpace(c.Query("value"))); val != "" {
 		out := make([]string, 0, len(*target))
 		for _, v := range *target {
 			if strings.TrimSpace(v) != val {
@@ -152,7 +153,9 @@ func (h *Handler) applyLegacyKeys(keys []string) {
 }
 
 // api-keys
-func (h *Handler) GetAPIKeys(c *gin.Context) { c.JSON(200, gin.H{"api-keys": h.cfg.APIKeys}) }
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	c.JSON(200, gin.H{"api-keys": h.cfg.WithSecretRefs().APIKeys})
+}
 func (h *Handler) PutAPIKeys(c *gin.Context) {
 	h.putStringList(c, func(v []string) {
 		h.cfg.APIKeys = append([]string(nil), v...)
@@ -166,9 +169,103 @@ func (h *Handler) DeleteAPIKeys(c *gin.Context) {
 	h.deleteFromStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
 }
 
+// api-key-policies: []APIKeyPolicy
+func (h *Handler) GetAPIKeyPolicies(c *gin.Context) {
+	c.JSON(200, gin.H{"api-key-policies": h.cfg.WithSecretRefs().APIKeyPolicies})
+}
+func (h *Handler) PutAPIKeyPolicies(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []sdkconfig.APIKeyPolicy
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []sdkconfig.APIKeyPolicy `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	out := make([]sdkconfig.APIKeyPolicy, 0, len(arr))
+	for i := range arr {
+		normalizeAPIKeyPolicy(&arr[i])
+		if arr[i].APIKey != "" {
+			out = append(out, arr[i])
+		}
+	}
+	h.cfg.APIKeyPolicies = out
+	h.persist(c)
+}
+func (h *Handler) PatchAPIKeyPolicy(c *gin.Context) {
+	var body struct {
+		Index *int                    `json:"index"`
+		Match *string                 `json:"match"`
+		Value *sdkconfig.APIKeyPolicy `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	value := *body.Value
+	normalizeAPIKeyPolicy(&value)
+	if value.APIKey == "" {
+		c.JSON(400, gin.H{"error": "missing api-key"})
+		return
+	}
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.APIKeyPolicies) {
+		h.cfg.APIKeyPolicies[*body.Index] = value
+		h.persist(c)
+		return
+	}
+	match := value.APIKey
+	if body.Match != nil {
+		match = h.cfg.ResolveSecretValue(*body.Match)
+	}
+	for i := range h.cfg.APIKeyPolicies {
+		if h.cfg.APIKeyPolicies[i].APIKey == match {
+			h.cfg.APIKeyPolicies[i] = value
+			h.persist(c)
+			return
+		}
+	}
+	if body.Match == nil && body.Index == nil {
+		h.cfg.APIKeyPolicies = append(h.cfg.APIKeyPolicies, value)
+		h.persist(c)
+		return
+	}
+	c.JSON(404, gin.H{"error": "item not found"})
+}
+func (h *Handler) DeleteAPIKeyPolicy(c *gin.Context) {
+	if val := h.cfg.ResolveSecretValue(c.Query("api-key")); val != "" {
+		out := make([]sdkconfig.APIKeyPolicy, 0, len(h.cfg.APIKeyPolicies))
+		for _, v := range h.cfg.APIKeyPolicies {
+			if v.APIKey != val {
+				out = append(out, v)
+			}
+		}
+		h.cfg.APIKeyPolicies = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.APIKeyPolicies) {
+			h.cfg.APIKeyPolicies = append(h.cfg.APIKeyPolicies[:idx], h.cfg.APIKeyPolicies[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing api-key or index"})
+}
+
 // generative-language-api-key
 func (h *Handler) GetGlKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"generative-language-api-key": geminiKeyStringsFromConfig(h.cfg)})
+	c.JSON(200, gin.H{"generative-language-api-key": geminiKeyStringsFromConfig(h.cfg.WithSecretRefs())})
 }
 func (h *Handler) PutGlKeys(c *gin.Context) {
 	h.putStringList(c, func(v []string) {
@@ -186,7 +283,7 @@ func (h *Handler) DeleteGlKeys(c *gin.Context) {
 
 // gemini-api-key: []GeminiKey
 func (h *Handler) GetGeminiKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"gemini-api-key": h.cfg.GeminiKey})
+	c.JSON(200, gin.H{"gemini-api-key": h.cfg.WithSecretRefs().GeminiKey})
 }
 func (h *Handler) PutGeminiKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -232,7 +329,7 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 			return
 		}
 		if body.Match != nil {
-			match := strings.TrimSpace(*body.Match)
+			match := h.cfg.ResolveSecretValue(strings.TrimSpace(*body.Match))
 			if match != "" {
 				out := make([]config.GeminiKey, 0, len(h.cfg.GeminiKey))
 				removed := false
@@ -262,7 +359,7 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 		return
 	}
 	if body.Match != nil {
-		match := strings.TrimSpace(*body.Match)
+		match := h.cfg.ResolveSecretValue(strings.TrimSpace(*body.Match))
 		for i := range h.cfg.GeminiKey {
 			if h.cfg.GeminiKey[i].APIKey == match {
 				h.cfg.GeminiKey[i] = value
@@ -275,7 +372,7 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 	c.JSON(404, gin.H{"error": "item not found"})
 }
 func (h *Handler) DeleteGeminiKey(c *gin.Context) {
-	if val := strings.TrimSpace(c.Query("api-key")); val != "" {
+	if val := h.cfg.ResolveSecretValue(strings.TrimSpace(c.Query("api-key"))); val != "" {
 		out := make([]config.GeminiKey, 0, len(h.cfg.GeminiKey))
 		for _, v := range h.cfg.GeminiKey {
 			if v.APIKey != val {
@@ -305,7 +402,7 @@ func (h *Handler) DeleteGeminiKey(c *gin.Context) {
 
 // claude-api-key: []ClaudeKey
 func (h *Handler) GetClaudeKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"claude-api-key": h.cfg.ClaudeKey})
+	c.JSON(200, gin.H{"claude-api-key": h.cfg.WithSecretRefs().ClaudeKey})
 }
 func (h *Handler) PutClaudeKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -351,7 +448,7 @@ func (h *Handler) PatchClaudeKey(c *gin.Context) {
 	}
 	if body.Match != nil {
 		for i := range h.cfg.ClaudeKey {
-			if h.cfg.ClaudeKey[i].APIKey == *body.Match {
+			if h.cfg.ClaudeKey[i].APIKey == h.cfg.ResolveSecretValue(*body.Match) {
 				h.cfg.ClaudeKey[i] = value
 				h.cfg.SanitizeClaudeKeys()
 				h.persist(c)
@@ -362,7 +459,7 @@ func (h *Handler) PatchClaudeKey(c *gin.Context) {
 	c.JSON(404, gin.H{"error": "item not found"})
 }
 func (h *Handler) DeleteClaudeKey(c *gin.Context) {
-	if val := c.Query("api-key"); val != "" {
+	if val := h.cfg.ResolveSecretValue(c.Query("api-key")); val != "" {
 		out := make([]config.ClaudeKey, 0, len(h.cfg.ClaudeKey))
 		for _, v := range h.cfg.ClaudeKey {
 			if v.APIKey != val {
@@ -389,7 +486,7 @@ func (h *Handler) DeleteClaudeKey(c *gin.Context) {
 
 // openai-compatibility: []OpenAICompatibility
 func (h *Handler) GetOpenAICompat(c *gin.Context) {
-	c.JSON(200, gin.H{"openai-compatibility": normalizedOpenAICompatibilityEntries(h.cfg.OpenAICompatibility)})
+	c.JSON(200, gin.H{"openai-compatibility": normalizedOpenAICompatibilityEntries(h.cfg.WithSecretRefs().OpenAICompatibility)})
 }
 func (h *Handler) PutOpenAICompat(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -506,7 +603,7 @@ func (h *Handler) DeleteOpenAICompat(c *gin.Context) {
 
 // codex-api-key: []CodexKey
 func (h *Handler) GetCodexKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"codex-api-key": h.cfg.CodexKey})
+	c.JSON(200, gin.H{"codex-api-key": h.cfg.WithSecretRefs().CodexKey})
 }
 func (h *Handler) PutCodexKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -569,7 +666,7 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 			out := make([]config.CodexKey, 0, len(h.cfg.CodexKey))
 			removed := false
 			for i := range h.cfg.CodexKey {
-				if !removed && h.cfg.CodexKey[i].APIKey == *body.Match {
+				if !removed && h.cfg.CodexKey[i].APIKey == h.cfg.ResolveSecretValue(*body.Match) {
 					removed = true
 					continue
 				}
@@ -591,7 +688,7 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 		}
 		if body.Match != nil {
 			for i := range h.cfg.CodexKey {
-				if h.cfg.CodexKey[i].APIKey == *body.Match {
+				if h.cfg.CodexKey[i].APIKey == h.cfg.ResolveSecretValue(*body.Match) {
 					h.cfg.CodexKey[i] = value
 					h.cfg.SanitizeCodexKeys()
 					h.persist(c)
@@ -603,7 +700,7 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 	c.JSON(404, gin.H{"error": "item not found"})
 }
 func (h *Handler) DeleteCodexKey(c *gin.Context) {
-	if val := c.Query("api-key"); val != "" {
+	if val := h.cfg.ResolveSecretValue(c.Query("api-key")); val != "" {
 		out := make([]config.CodexKey, 0, len(h.cfg.CodexKey))
 		for _, v := range h.cfg.CodexKey {
 			if v.APIKey != val {
@@ -686,6 +783,24 @@ func normalizedOpenAICompatibilityEntries(entries []config.OpenAICompatibility)
 	return out
 }
 
+func normalizeAPIKeyPolicy(entry *sdkconfig.APIKeyPolicy) {
+	if entry == nil {
+		return
+	}
+	entry.APIKey = strings.TrimSpace(entry.APIKey)
+	entry.AllowedModels = sanitizeStringSlice(entry.AllowedModels)
+	entry.DeniedModels = sanitizeStringSlice(entry.DeniedModels)
+	providers := sanitizeStringSlice(entry.AllowedProviders)
+	for i := range providers {
+		providers[i] = strings.ToLower(providers[i])
+	}
+	entry.AllowedProviders = providers
+	entry.RequestsPerMinute = max(entry.RequestsPerMinute, 0)
+	entry.TokensPerMinute = max(entry.TokensPerMinute, 0)
+	entry.DailyTokens = max(entry.DailyTokens, 0)
+	entry.MonthlyTokens = max(entry.MonthlyTokens, 0)
+}
+
 func normalizeClaudeKey(entry *config.ClaudeKey) {
 	if entry == nil {
 		return
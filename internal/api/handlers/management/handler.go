@@ -3,7 +3,9 @@
 package management
 
 import (
+	"crypto/sha256"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,14 +15,26 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	virtualkeys "github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtual_keys"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// maxVerifiedManagementKeys bounds the cache of verified management secrets.
+const maxVerifiedManagementKeys = 64
+
+// attemptKey identifies a failed-attempt counter: the client IP and the name of the
+// management key used, empty when the key could not be identified.
+type attemptKey struct {
+	ip   string
+	name string
+}
+
 type attemptInfo struct {
 	count        int
 	blockedUntil time.Time
@@ -32,10 +46,13 @@ type Handler struct {
 	configFilePath      string
 	mu                  sync.Mutex
 	attemptsMu          sync.Mutex
-	failedAttempts      map[string]*attemptInfo // keyed by client IP
+	failedAttempts      map[attemptKey]*attemptInfo
+	verifiedMu          sync.Mutex
+	verified            map[[sha256.Size]byte]string // secret digest -> matching bcrypt hash
 	authManager         *coreauth.Manager
 	usageStats          *usage.RequestStatistics
 	tokenStore          coreauth.Store
+	virtualKeys         *virtualkeys.Registry
 	localPassword       string
 	allowRemoteOverride bool
 	envSecret           string
@@ -50,10 +67,11 @@ func NewHandler(cfg *config.Config, configFilePath string, manager *coreauth.Man
 	return &Handler{
 		cfg:                 cfg,
 		configFilePath:      configFilePath,
-		failedAttempts:      make(map[string]*attemptInfo),
+		failedAttempts:      make(map[attemptKey]*attemptInfo),
 		authManager:         manager,
 		usageStats:          usage.GetRequestStatistics(),
 		tokenStore:          sdkAuth.GetTokenStore(),
+		virtualKeys:         virtualkeys.Default(),
 		allowRemoteOverride: envSecret != "",
 		envSecret:           envSecret,
 	}
@@ -85,8 +103,11 @@ func (h *Handler) SetLogDirectory(dir string) {
 }
 
 // Middleware enforces access control for management endpoints.
-// All requests (local and remote) require a valid management key.
-// Additionally, remote access requires allow-remote-management=true.
+// All requests (local and remote) require a valid management key, and the key's role must
+// cover the requested route. Additionally, remote access requires allow-remote-management=true.
+// Failed attempts from a remote IP count towards a ban per key: a revoked key is banned on its
+// own, while missing and unknown keys share one counter per IP. A ban does not lock out
+// other valid keys used from the same IP.
 func (h *Handler) Middleware() gin.HandlerFunc {
 	const maxFailures = 5
 	const banDuration = 30 * time.Minute
@@ -101,57 +122,22 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		cfg := h.cfg
 		var (
 			allowRemote bool
-			secretHash  string
+			remote      config.RemoteManagement
 		)
 		if cfg != nil {
 			allowRemote = cfg.RemoteManagement.AllowRemote
-			secretHash = cfg.RemoteManagement.SecretKey
+			remote = cfg.RemoteManagement
 		}
 		if h.allowRemoteOverride {
 			allowRemote = true
 		}
 		envSecret := h.envSecret
 
-		fail := func() {}
-		if !localClient {
-			h.attemptsMu.Lock()
-			ai := h.failedAttempts[clientIP]
-			if ai != nil {
-				if !ai.blockedUntil.IsZero() {
-					if time.Now().Before(ai.blockedUntil) {
-						remaining := time.Until(ai.blockedUntil).Round(time.Second)
-						h.attemptsMu.Unlock()
-						c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("IP banned due to too many failed attempts. Try again in %s", remaining)})
-						return
-					}
-					// Ban expired, reset state
-					ai.blockedUntil = time.Time{}
-					ai.count = 0
-				}
-			}
-			h.attemptsMu.Unlock()
-
-			if !allowRemote {
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management disabled"})
-				return
-			}
-
-			fail = func() {
-				h.attemptsMu.Lock()
-				aip := h.failedAttempts[clientIP]
-				if aip == nil {
-					aip = &attemptInfo{}
-					h.failedAttempts[clientIP] = aip
-				}
-				aip.count++
-				if aip.count >= maxFailures {
-					aip.blockedUntil = time.Now().Add(banDuration)
-					aip.count = 0
-				}
-				h.attemptsMu.Unlock()
-			}
+		if !localClient && !allowRemote {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management disabled"})
+			return
 		}
-		if secretHash == "" && envSecret == "" {
+		if !remote.HasKeys() && envSecret == "" {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management key not set"})
 			return
 		}
@@ -170,63 +156,164 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			provided = c.GetHeader("X-Management-Key")
 		}
 
-		if provided == "" {
-			if !localClient {
-				fail()
-			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing management key"})
-			return
+		var name, role string
+		var revoked bool
+		if provided != "" {
+			name, role, revoked = h.resolveManagementKey(provided, localClient, remote)
 		}
-
-		if localClient {
-			if lp := h.localPassword; lp != "" {
-				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
-					c.Next()
-					return
-				}
+		attempt := attemptKey{ip: clientIP, name: name}
+		if !localClient {
+			if remaining := h.banRemaining(attempt); remaining > 0 {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("IP banned due to too many failed attempts. Try again in %s", remaining.Round(time.Second))})
+				return
 			}
 		}
 
-		if envSecret != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(envSecret)) == 1 {
+		if provided == "" {
 			if !localClient {
-				h.attemptsMu.Lock()
-				if ai := h.failedAttempts[clientIP]; ai != nil {
-					ai.count = 0
-					ai.blockedUntil = time.Time{}
-				}
-				h.attemptsMu.Unlock()
+				h.recordFailure(attempt, maxFailures, banDuration)
 			}
-			c.Next()
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing management key"})
 			return
 		}
-
-		if secretHash == "" || bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) != nil {
+		if role == "" {
 			if !localClient {
-				fail()
+				h.recordFailure(attempt, maxFailures, banDuration)
+			}
+			message := "invalid management key"
+			if revoked {
+				message = "management key revoked"
 			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid management key"})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
 			return
 		}
 
 		if !localClient {
 			h.attemptsMu.Lock()
-			if ai := h.failedAttempts[clientIP]; ai != nil {
-				ai.count = 0
-				ai.blockedUntil = time.Time{}
-			}
+			delete(h.failedAttempts, attempt)
 			h.attemptsMu.Unlock()
 		}
 
+		required := requiredRole(c.Request.Method, c.FullPath())
+		if config.ManagementRoleRank(role) < config.ManagementRoleRank(required) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("management key %q has role %s, %s required", name, role, required)})
+			return
+		}
+		c.Set("managementKey", name)
+		c.Set("managementRole", role)
 		c.Next()
 	}
 }
 
+// banRemaining returns how long the attempts identified by key stay banned; an expired ban
+// is reset.
+func (h *Handler) banRemaining(key attemptKey) time.Duration {
+	h.attemptsMu.Lock()
+	defer h.attemptsMu.Unlock()
+	ai := h.failedAttempts[key]
+	if ai == nil || ai.blockedUntil.IsZero() {
+		return 0
+	}
+	if remaining := time.Until(ai.blockedUntil); remaining > 0 {
+		return remaining
+	}
+	// Ban expired, reset state
+	delete(h.failedAttempts, key)
+	return 0
+}
+
+// recordFailure counts a failed attempt and bans key once maxFailures is reached.
+func (h *Handler) recordFailure(key attemptKey, maxFailures int, banDuration time.Duration) {
+	h.attemptsMu.Lock()
+	defer h.attemptsMu.Unlock()
+	ai := h.failedAttempts[key]
+	if ai == nil {
+		ai = &attemptInfo{}
+		h.failedAttempts[key] = ai
+	}
+	ai.count++
+	if ai.count >= maxFailures {
+		ai.blockedUntil = time.Now().Add(banDuration)
+		ai.count = 0
+	}
+}
+
+// resolveManagementKey identifies the key behind provided and returns its name and role. The
+// local password, MANAGEMENT_PASSWORD and the legacy secret-key act as admin. revoked reports
+// a match against a disabled named key.
+func (h *Handler) resolveManagementKey(provided string, localClient bool, remote config.RemoteManagement) (name, role string, revoked bool) {
+	if localClient {
+		if lp := h.localPassword; lp != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
+			return "local", config.ManagementRoleAdmin, false
+		}
+	}
+	if h.envSecret != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(h.envSecret)) == 1 {
+		return "env", config.ManagementRoleAdmin, false
+	}
+	hash := h.matchManagementHash(provided, remote)
+	switch {
+	case hash == "":
+		return "", "", false
+	case hash == remote.SecretKey:
+		return "secret-key", config.ManagementRoleAdmin, false
+	}
+	for _, key := range remote.Keys {
+		if key.Key != hash {
+			continue
+		}
+		if key.Disabled {
+			return key.Name, "", true
+		}
+		return key.Name, key.Role, false
+	}
+	return "", "", false
+}
+
+// matchManagementHash returns the configured bcrypt hash that provided matches. Verified
+// secrets are remembered by digest so each request costs at most one bcrypt comparison; the
+// cached hash only counts while it is still configured, so removed keys stop working at once.
+func (h *Handler) matchManagementHash(provided string, remote config.RemoteManagement) string {
+	hashes := make([]string, 0, len(remote.Keys)+1)
+	if remote.SecretKey != "" {
+		hashes = append(hashes, remote.SecretKey)
+	}
+	for _, key := range remote.Keys {
+		if key.Key != "" {
+			hashes = append(hashes, key.Key)
+		}
+	}
+	digest := sha256.Sum256([]byte(provided))
+	h.verifiedMu.Lock()
+	cached, ok := h.verified[digest]
+	h.verifiedMu.Unlock()
+	if ok {
+		for _, hash := range hashes {
+			if hash == cached {
+				return hash
+			}
+		}
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(provided)) == nil {
+			h.verifiedMu.Lock()
+			if h.verified == nil || len(h.verified) >= maxVerifiedManagementKeys {
+				h.verified = make(map[[sha256.Size]byte]string)
+			}
+			h.verified[digest] = hash
+			h.verifiedMu.Unlock()
+			return hash
+		}
+	}
+	return ""
+}
+
 // persist saves the current in-memory config to disk.
 func (h *Handler) persist(c *gin.Context) bool {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	// Preserve comments when writing
-	if err := config.SaveConfigPreserveComments(h.configFilePath, h.cfg); err != nil {
+	if err := h.saveConfig(); err != nil {
+		if errors.Is(err, config.ErrSecretRefNotAllowed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save config: %v", err)})
 		return false
 	}
@@ -234,6 +321,30 @@ func (h *Handler) persist(c *gin.Context) bool {
 	return true
 }
 
+// saveConfig writes the in-memory config to disk for handlers that build their own response.
+// A change that brings in a secret reference the config file did not already hold is undone
+// and fails with config.ErrSecretRefNotAllowed.
+func (h *Handler) saveConfig() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.cfg.AdoptSecretRefs(); err != nil {
+		h.revertConfigLocked()
+		return err
+	}
+	// Preserve comments when writing
+	return config.SaveConfigPreserveComments(h.configFilePath, h.cfg)
+}
+
+// revertConfigLocked drops unsaved changes by reloading the config file into the shared config.
+func (h *Handler) revertConfigLocked() {
+	reloaded, err := config.LoadConfig(h.configFilePath)
+	if err != nil {
+		log.Warnf("failed to reload config after a rejected change: %v", err)
+		return
+	}
+	*h.cfg = *reloaded
+}
+
 // Helper methods for simple types
 func (h *Handler) updateBoolField(c *gin.Context, set func(bool)) {
 	var body struct {
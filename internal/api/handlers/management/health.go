@@ -29,9 +29,6 @@ func HealthHandler(c *gin.Context) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	// Format memory in human-readable format
-	memoryMB := float64(m.Alloc) / 1024 / 1024
-
 	response := HealthCheckResponse{
 		Status:    "healthy",
 		Timestamp: time.Now().UTC(),
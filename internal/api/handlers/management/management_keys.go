@@ -0,0 +1,138 @@
+package management
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// managementKeyBody carries the editable fields of a named management key.
+type managementKeyBody struct {
+	Name     *string `json:"name"`
+	Key      *string `json:"key"`
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+func normalizeManagementRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if config.ManagementRoleRank(role) == 0 {
+		return "", fmt.Errorf("invalid role %q, expected viewer, operator or admin", role)
+	}
+	return role, nil
+}
+
+func (h *Handler) managementKeyIndex(name string) int {
+	for i, key := range h.cfg.RemoteManagement.Keys {
+		if key.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetManagementKeys lists the named management keys without their hashes.
+func (h *Handler) GetManagementKeys(c *gin.Context) {
+	keys := h.cfg.RemoteManagement.Keys
+	if keys == nil {
+		keys = []config.ManagementKey{}
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// CreateManagementKey adds a named key. When no key is supplied one is generated, and the
+// response is the only place it appears in clear.
+func (h *Handler) CreateManagementKey(c *gin.Context) {
+	var body managementKeyBody
+	if err := c.ShouldBindJSON(&body); err != nil || body.Name == nil || strings.TrimSpace(*body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
+		return
+	}
+	name := strings.TrimSpace(*body.Name)
+	if h.managementKeyIndex(name) >= 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "key already exists"})
+		return
+	}
+	role := config.ManagementRoleViewer
+	if body.Role != nil {
+		normalized, err := normalizeManagementRole(*body.Role)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		role = normalized
+	}
+	secret := ""
+	if body.Key != nil {
+		secret = strings.TrimSpace(*body.Key)
+	}
+	if secret == "" {
+		raw := make([]byte, 24)
+		if _, err := rand.Read(raw); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate key"})
+			return
+		}
+		secret = "mgmt-" + base64.RawURLEncoding.EncodeToString(raw)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash key"})
+		return
+	}
+	key := config.ManagementKey{Name: name, Key: string(hashed), Role: role}
+	if body.Disabled != nil {
+		key.Disabled = *body.Disabled
+	}
+	h.cfg.RemoteManagement.Keys = append(h.cfg.RemoteManagement.Keys, key)
+	if err = h.saveConfig(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save config: %v", err)})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"management-key": secret, "key": key})
+}
+
+// PatchManagementKey changes the role of a named key or revokes it with "disabled": true.
+func (h *Handler) PatchManagementKey(c *gin.Context) {
+	idx := h.managementKeyIndex(c.Param("name"))
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "key not found"})
+		return
+	}
+	var body managementKeyBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	key := h.cfg.RemoteManagement.Keys[idx]
+	if body.Role != nil {
+		role, err := normalizeManagementRole(*body.Role)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.Role = role
+	}
+	if body.Disabled != nil {
+		key.Disabled = *body.Disabled
+	}
+	h.cfg.RemoteManagement.Keys[idx] = key
+	h.persist(c)
+}
+
+// DeleteManagementKey removes a named key.
+func (h *Handler) DeleteManagementKey(c *gin.Context) {
+	idx := h.managementKeyIndex(c.Param("name"))
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "key not found"})
+		return
+	}
+	keys := h.cfg.RemoteManagement.Keys
+	h.cfg.RemoteManagement.Keys = append(keys[:idx:idx], keys[idx+1:]...)
+	h.persist(c)
+}
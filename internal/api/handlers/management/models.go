@@ -0,0 +1,20 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// GetModels lists the models currently served, with the providers backing each one.
+func (h *Handler) GetModels(c *gin.Context) {
+	reg := registry.GetGlobalRegistry()
+	models := reg.GetAvailableModels("openai")
+	for _, model := range models {
+		if id, ok := model["id"].(string); ok {
+			model["providers"] = reg.GetModelProviders(id)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"models": models})
+}
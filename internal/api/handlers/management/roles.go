@@ -0,0 +1,51 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// managementRoutePrefix is stripped from gin route templates before role lookups.
+const managementRoutePrefix = "/v0/management"
+
+// viewerRoutes are read-only endpoints that expose statistics and logs but no secrets.
+var viewerRoutes = map[string]struct{}{
+	"/usage":               {},
+	"/models":              {},
+	"/routing/scores":      {},
+	"/routing/concurrency": {},
+	"/logs":                {},
+}
+
+// operatorRoutes manage credentials day to day without touching configuration or raw tokens.
+var operatorRoutes = map[string]struct{}{
+	"/auth-files":           {},
+	"/auth-files/status":    {},
+	"/auth-files/refresh":   {},
+	"/anthropic-auth-url":   {},
+	"/codex-auth-url":       {},
+	"/gemini-cli-auth-url":  {},
+	"/antigravity-auth-url": {},
+	"/qwen-auth-url":        {},
+	"/iflow-auth-url":       {},
+	"/get-auth-status":      {},
+}
+
+// requiredRole returns the least privileged role allowed to call the route. Viewer routes
+// are limited to GET, operators may not upload or delete auth files, and anything not listed
+// requires admin.
+func requiredRole(method, route string) string {
+	route = strings.TrimPrefix(route, managementRoutePrefix)
+	if _, ok := viewerRoutes[route]; ok && method == http.MethodGet {
+		return config.ManagementRoleViewer
+	}
+	if _, ok := operatorRoutes[route]; ok {
+		if route == "/auth-files" && method != http.MethodGet {
+			return config.ManagementRoleAdmin
+		}
+		return config.ManagementRoleOperator
+	}
+	return config.ManagementRoleAdmin
+}
@@ -0,0 +1,44 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetRoutingScores returns the latency, time-to-first-token and error-rate moving averages
+// used by adaptive routing. Optional provider and model query parameters filter the list.
+func (h *Handler) GetRoutingScores(c *gin.Context) {
+	scores := []coreauth.AuthScore{}
+	if h != nil && h.authManager != nil {
+		scores = h.authManager.AuthScores()
+	}
+	provider := strings.TrimSpace(c.Query("provider"))
+	model := strings.TrimSpace(c.Query("model"))
+	if provider != "" || model != "" {
+		filtered := scores[:0]
+		for _, score := range scores {
+			if provider != "" && !strings.EqualFold(score.Provider, provider) {
+				continue
+			}
+			if model != "" && score.Model != model {
+				continue
+			}
+			filtered = append(filtered, score)
+		}
+		scores = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"scores": scores})
+}
+
+// GetRoutingConcurrency returns the concurrency queue depth and wait times per provider along
+// with the in-flight requests of every credential that has a concurrency limit.
+func (h *Handler) GetRoutingConcurrency(c *gin.Context) {
+	stats := coreauth.ConcurrencyStats{Queues: []coreauth.ProviderQueueStats{}, Auths: []coreauth.AuthConcurrency{}}
+	if h != nil && h.authManager != nil {
+		stats = h.authManager.ConcurrencyStats()
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,138 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	virtualkeys "github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtual_keys"
+)
+
+// virtualKeyBody carries the editable fields of a virtual key. ExpiresAt takes an RFC 3339
+// timestamp; an empty string clears the expiry.
+type virtualKeyBody struct {
+	Owner       *string   `json:"owner"`
+	Description *string   `json:"description"`
+	Models      *[]string `json:"models"`
+	Scopes      *[]string `json:"scopes"`
+	Enabled     *bool     `json:"enabled"`
+	ExpiresAt   *string   `json:"expires-at"`
+}
+
+func (b *virtualKeyBody) apply(key *virtualkeys.Key) error {
+	if b.Owner != nil {
+		key.Owner = strings.TrimSpace(*b.Owner)
+	}
+	if b.Description != nil {
+		key.Description = strings.TrimSpace(*b.Description)
+	}
+	if b.Models != nil {
+		key.Models = *b.Models
+	}
+	if b.Scopes != nil {
+		key.Scopes = *b.Scopes
+	}
+	if b.Enabled != nil {
+		key.Enabled = *b.Enabled
+	}
+	if b.ExpiresAt != nil {
+		raw := strings.TrimSpace(*b.ExpiresAt)
+		if raw == "" {
+			key.ExpiresAt = nil
+			return nil
+		}
+		expiresAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return errors.New("invalid expires-at, expected RFC 3339")
+		}
+		expiresAt = expiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+	return nil
+}
+
+func (h *Handler) virtualKeyRegistry() *virtualkeys.Registry {
+	if h.virtualKeys == nil {
+		h.virtualKeys = virtualkeys.Default()
+	}
+	return h.virtualKeys
+}
+
+// GetVirtualKeys lists the issued virtual keys without their secrets.
+func (h *Handler) GetVirtualKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": h.virtualKeyRegistry().List()})
+}
+
+// GetVirtualKey returns one virtual key by ID.
+func (h *Handler) GetVirtualKey(c *gin.Context) {
+	key, err := h.virtualKeyRegistry().Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// CreateVirtualKey issues a key. The response is the only place the key appears in clear.
+func (h *Handler) CreateVirtualKey(c *gin.Context) {
+	var body virtualKeyBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	template := virtualkeys.Key{Enabled: true}
+	if err := body.apply(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, secret, err := h.virtualKeyRegistry().Create(c.Request.Context(), template)
+	if err != nil {
+		h.writeVirtualKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api-key": secret, "key": key})
+}
+
+// PatchVirtualKey updates the owner, description, models, scopes, enabled state or expiry of a key.
+func (h *Handler) PatchVirtualKey(c *gin.Context) {
+	var body virtualKeyBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := body.apply(&virtualkeys.Key{}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := h.virtualKeyRegistry().Update(c.Request.Context(), c.Param("id"), func(key *virtualkeys.Key) {
+		_ = body.apply(key)
+	})
+	if err != nil {
+		h.writeVirtualKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// DeleteVirtualKey revokes a key.
+func (h *Handler) DeleteVirtualKey(c *gin.Context) {
+	if err := h.virtualKeyRegistry().Delete(c.Request.Context(), c.Param("id")); err != nil {
+		h.writeVirtualKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *Handler) writeVirtualKeyError(c *gin.Context, err error) {
+	if errors.Is(err, virtualkeys.ErrKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "key not found"})
+		return
+	}
+	if errors.Is(err, virtualkeys.ErrInvalidModelPattern) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -23,6 +24,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batches"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
@@ -165,6 +167,18 @@ type Server struct {
 	keepAliveOnTimeout func()
 	keepAliveHeartbeat chan struct{}
 	keepAliveStop      chan struct{}
+
+	// tls serves and hot-reloads the certificate when the listener runs HTTPS.
+	tls       atomic.Pointer[certReloader]
+	tlsCancel context.CancelFunc
+
+	// cors holds the current origin policies, swapped on config reload.
+	cors atomic.Pointer[config.CORSConfig]
+
+	// batches stores the files and batches of the emulated OpenAI Batch API, which
+	// batchHandlers serves and runs.
+	batches       *batches.Manager
+	batchHandlers *openai.OpenAIBatchAPIHandler
 }
 
 // NewServer creates and initializes a new API server instance.
@@ -216,7 +230,6 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		}
 	}
 
-	engine.Use(corsMiddleware())
 	wd, err := os.Getwd()
 	if err != nil {
 		wd = configFilePath
@@ -244,6 +257,9 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		wsRoutes:            make(map[string]struct{}),
 	}
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
+	corsCfg := cfg.CORS
+	s.cors.Store(&corsCfg)
+	engine.Use(s.corsMiddleware())
 	// Save initial YAML snapshot
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
 	s.applyAccessConfig(nil, cfg)
@@ -260,6 +276,12 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	s.mgmt.SetLogDirectory(logDir)
 	s.localPassword = optionState.localPassword
+	batchDir := strings.TrimSpace(cfg.Batches.Dir)
+	if batchDir == "" {
+		batchDir = filepath.Join(filepath.Dir(logDir), "batches")
+	}
+	s.batches = batches.NewManager(batchDir)
+	s.batches.SetLimits(cfg.Batches.MaxConcurrency, int64(cfg.Batches.MaxFileSizeMB)<<20)
 
 	// Setup routes
 	s.setupRoutes()
@@ -282,7 +304,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 
 	// Register management routes when configuration or environment secrets are available.
-	hasManagementSecret := cfg.RemoteManagement.SecretKey != "" || envManagementSecret
+	hasManagementSecret := cfg.RemoteManagement.HasKeys() || envManagementSecret
 	s.managementRoutesEnabled.Store(hasManagementSecret)
 	if hasManagementSecret {
 		s.registerManagementRoutes()
@@ -310,6 +332,7 @@ func (s *Server) setupRoutes() {
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
+	s.batchHandlers = openai.NewOpenAIBatchAPIHandler(s.handlers, s.batches)
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
@@ -318,6 +341,19 @@ func (s *Server) setupRoutes() {
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
+		v1.POST("/images/generations", openaiHandlers.ImageGenerations)
+		v1.POST("/images/edits", openaiHandlers.ImageEdits)
+		v1.POST("/audio/transcriptions", openaiHandlers.AudioTranscriptions)
+		v1.POST("/files", s.batchHandlers.UploadFile)
+		v1.GET("/files", s.batchHandlers.ListFiles)
+		v1.GET("/files/:id", s.batchHandlers.GetFile)
+		v1.DELETE("/files/:id", s.batchHandlers.DeleteFile)
+		v1.GET("/files/:id/content", s.batchHandlers.GetFileContent)
+		v1.POST("/batches", s.batchHandlers.CreateBatch)
+		v1.GET("/batches", s.batchHandlers.ListBatches)
+		v1.GET("/batches/:id", s.batchHandlers.GetBatch)
+		v1.POST("/batches/:id/cancel", s.batchHandlers.CancelBatch)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
@@ -469,6 +505,9 @@ func (s *Server) registerManagementRoutes() {
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.GET("/models", s.mgmt.GetModels)
+		mgmt.GET("/routing/scores", s.mgmt.GetRoutingScores)
+		mgmt.GET("/routing/concurrency", s.mgmt.GetRoutingConcurrency)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigFile)
@@ -503,6 +542,26 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
 
+		mgmt.GET("/api-key-policies", s.mgmt.GetAPIKeyPolicies)
+		mgmt.PUT("/api-key-policies", s.mgmt.PutAPIKeyPolicies)
+		mgmt.PATCH("/api-key-policies", s.mgmt.PatchAPIKeyPolicy)
+		mgmt.DELETE("/api-key-policies", s.mgmt.DeleteAPIKeyPolicy)
+
+		mgmt.GET("/virtual-keys", s.mgmt.GetVirtualKeysEnabled)
+		mgmt.PUT("/virtual-keys", s.mgmt.PutVirtualKeysEnabled)
+		mgmt.PATCH("/virtual-keys", s.mgmt.PutVirtualKeysEnabled)
+
+		mgmt.GET("/keys", s.mgmt.GetVirtualKeys)
+		mgmt.POST("/keys", s.mgmt.CreateVirtualKey)
+		mgmt.GET("/keys/:id", s.mgmt.GetVirtualKey)
+		mgmt.PATCH("/keys/:id", s.mgmt.PatchVirtualKey)
+		mgmt.DELETE("/keys/:id", s.mgmt.DeleteVirtualKey)
+
+		mgmt.GET("/management-keys", s.mgmt.GetManagementKeys)
+		mgmt.POST("/management-keys", s.mgmt.CreateManagementKey)
+		mgmt.PATCH("/management-keys/:name", s.mgmt.PatchManagementKey)
+		mgmt.DELETE("/management-keys/:name", s.mgmt.DeleteManagementKey)
+
 		mgmt.GET("/generative-language-api-key", s.mgmt.GetGlKeys)
 		mgmt.PUT("/generative-language-api-key", s.mgmt.PutGlKeys)
 		mgmt.PATCH("/generative-language-api-key", s.mgmt.PatchGlKeys)
@@ -545,6 +604,8 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
+		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
+		mgmt.POST("/auth-files/refresh", s.mgmt.RefreshAuthFile)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
 
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
@@ -698,6 +759,31 @@ func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, cl
 // Returns:
 //   - error: An error if the server fails to start
 func (s *Server) Start() error {
+	// Resume the batches interrupted by the last shutdown.
+	if err := s.batchHandlers.Start(); err != nil {
+		log.Errorf("failed to resume batches: %v", err)
+	}
+
+	if s.cfg != nil && s.cfg.TLS.Enabled() {
+		reloader, err := newCertReloader(s.cfg.TLS)
+		if err != nil {
+			return fmt.Errorf("failed to start HTTPS server: %v", err)
+		}
+		watchCtx, cancel := context.WithCancel(context.Background())
+		s.tlsCancel = cancel
+		if errWatch := reloader.watch(watchCtx); errWatch != nil {
+			log.Warnf("tls certificates will not be reloaded automatically: %v", errWatch)
+		}
+		s.tls.Store(reloader)
+		s.server.TLSConfig = reloader.tlsConfig()
+		log.Debugf("Starting API server with TLS on %s", s.server.Addr)
+
+		if err = s.server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to start HTTPS server: %v", err)
+		}
+		return nil
+	}
+
 	log.Debugf("Starting API server on %s", s.server.Addr)
 
 	// Start the HTTP server.
@@ -726,6 +812,13 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
+	if s.tlsCancel != nil {
+		s.tlsCancel()
+	}
+
+	// Interrupt running batches; they resume on the next start.
+	s.batches.Stop()
+
 	// Shutdown the HTTP server.
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
@@ -735,18 +828,58 @@ func (s *Server) Stop(ctx context.Context) error {
 	return nil
 }
 
-// corsMiddleware returns a Gin middleware handler that adds CORS headers
-// to every response, allowing cross-origin requests.
+// corsMiddleware returns a Gin middleware handler that adds CORS headers to every response
+// using the policy of the route family being called: management, websocket relay or API.
+// Requests from origins a restricted policy does not list are rejected, since browsers still
+// deliver simple cross-origin requests even when the response cannot be read. Management
+// and websocket relay routes without a policy send no CORS headers, so only same-origin pages
+// can call them.
 //
 // Returns:
 //   - gin.HandlerFunc: The CORS middleware handler
-func corsMiddleware() gin.HandlerFunc {
+func (s *Server) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "*")
+		path := c.Request.URL.Path
+		policy, sameOriginDefault := s.corsPolicy(path)
+		origin := c.GetHeader("Origin")
+		if sameOriginDefault && len(policy.AllowedOrigins) == 0 {
+			if c.Request.Method == "OPTIONS" {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+		if !policy.Restricted() {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			if !policy.AllowsRequest(origin) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+				return
+			}
+			if origin != "" {
+				c.Header("Access-Control-Allow-Origin", origin)
+				if policy.AllowCredentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+			}
+		}
+		methods := "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+		if len(policy.AllowedMethods) > 0 {
+			methods = strings.Join(policy.AllowedMethods, ", ")
+		}
+		headers := "*"
+		if len(policy.AllowedHeaders) > 0 {
+			headers = strings.Join(policy.AllowedHeaders, ", ")
+		}
+		c.Header("Access-Control-Allow-Methods", methods)
+		c.Header("Access-Control-Allow-Headers", headers)
 
 		if c.Request.Method == "OPTIONS" {
+			if policy.MaxAgeSeconds > 0 {
+				c.Header("Access-Control-Max-Age", strconv.Itoa(policy.MaxAgeSeconds))
+			}
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -755,6 +888,26 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// corsPolicy selects the origin policy for a request path and reports whether the route family
+// defaults to same-origin when the policy lists no origins.
+func (s *Server) corsPolicy(path string) (config.CORSPolicy, bool) {
+	management := strings.HasPrefix(path, "/v0/management")
+	s.wsRouteMu.Lock()
+	_, websocket := s.wsRoutes[path]
+	s.wsRouteMu.Unlock()
+	cfg := s.cors.Load()
+	if cfg == nil {
+		return config.CORSPolicy{}, management || websocket
+	}
+	switch {
+	case management:
+		return cfg.Management, true
+	case websocket:
+		return cfg.Websocket, true
+	}
+	return cfg.API, false
+}
+
 func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 	if s == nil || s.accessManager == nil || newCfg == nil {
 		return
@@ -795,6 +948,21 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if reloader := s.tls.Load(); reloader != nil {
+		if cfg.TLS.Enabled() {
+			reloader.update(cfg.TLS)
+		} else {
+			log.Warn("tls settings removed; restart the server to serve plain HTTP")
+		}
+	} else if oldCfg != nil && !oldCfg.TLS.Enabled() && cfg.TLS.Enabled() {
+		log.Warn("tls settings added; restart the server to serve HTTPS")
+	}
+
+	corsCfg := cfg.CORS
+	s.cors.Store(&corsCfg)
+
+	s.batches.SetLimits(cfg.Batches.MaxConcurrency, int64(cfg.Batches.MaxFileSizeMB)<<20)
+
 	if oldCfg != nil && oldCfg.LoggingToFile != cfg.LoggingToFile {
 		if err := logging.ConfigureLogOutput(cfg.LoggingToFile); err != nil {
 			log.Errorf("failed to reconfigure log output: %v", err)
@@ -833,9 +1001,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 
 	prevSecretEmpty := true
 	if oldCfg != nil {
-		prevSecretEmpty = oldCfg.RemoteManagement.SecretKey == ""
+		prevSecretEmpty = !oldCfg.RemoteManagement.HasKeys()
 	}
-	newSecretEmpty := cfg.RemoteManagement.SecretKey == ""
+	newSecretEmpty := !cfg.RemoteManagement.HasKeys()
 	if s.envManagementSecret {
 		s.registerManagementRoutes()
 		if s.managementRoutesEnabled.CompareAndSwap(false, true) {
@@ -9,10 +9,12 @@ import (
 	"testing"
 
 	gin "github.com/gin-gonic/gin"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func newTestServer(t *testing.T) *Server {
@@ -109,3 +111,193 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIKeyPolicyDeniesModelInClientDialect(t *testing.T) {
+	configaccess.Register()
+	server := newTestServer(t)
+	server.cfg.APIKeyPolicies = []sdkconfig.APIKeyPolicy{{APIKey: "test-key", DeniedModels: []string{"blocked-*"}}}
+	server.handlers.UpdateClients(&server.cfg.SDKConfig)
+
+	testCases := []struct {
+		name         string
+		path         string
+		body         string
+		wantContains string
+	}{
+		{
+			name:         "openai",
+			path:         "/v1/chat/completions",
+			body:         `{"model":"blocked-model","messages":[{"role":"user","content":"hi"}]}`,
+			wantContains: `"type":"permission_error"`,
+		},
+		{
+			name:         "claude",
+			path:         "/v1/messages",
+			body:         `{"model":"blocked-model","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`,
+			wantContains: `"type":"error"`,
+		},
+		{
+			name:         "gemini",
+			path:         "/v1beta/models/blocked-model:generateContent",
+			body:         `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`,
+			wantContains: `"status":"PERMISSION_DENIED"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer test-key")
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			server.engine.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusForbidden {
+				t.Fatalf("unexpected status code: got %d want %d; body=%s", rr.Code, http.StatusForbidden, rr.Body.String())
+			}
+			if body := rr.Body.String(); !strings.Contains(body, tc.wantContains) {
+				t.Fatalf("response body missing %q: %s", tc.wantContains, body)
+			}
+		})
+	}
+}
+
+func TestManagementKeyRoles(t *testing.T) {
+	hash := func(secret string) string {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+		return string(hashed)
+	}
+	gin.SetMode(gin.TestMode)
+	tmpDir := t.TempDir()
+	cfg := &proxyconfig.Config{
+		AuthDir: tmpDir,
+		RemoteManagement: proxyconfig.RemoteManagement{
+			AllowRemote: true,
+			Keys: []proxyconfig.ManagementKey{
+				{Name: "dashboard", Key: hash("viewer-secret"), Role: proxyconfig.ManagementRoleViewer},
+				{Name: "oncall", Key: hash("operator-secret"), Role: proxyconfig.ManagementRoleOperator},
+				{Name: "ops-admin", Key: hash("admin-secret"), Role: proxyconfig.ManagementRoleAdmin},
+				{Name: "old", Key: hash("revoked-secret"), Role: proxyconfig.ManagementRoleAdmin, Disabled: true},
+			},
+		},
+	}
+	server := NewServer(cfg, auth.NewManager(nil, nil, nil), sdkaccess.NewManager(), filepath.Join(tmpDir, "config.yaml"))
+
+	call := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{"viewer reads usage", http.MethodGet, "/v0/management/usage", "viewer-secret", http.StatusOK},
+		{"viewer lists models", http.MethodGet, "/v0/management/models", "viewer-secret", http.StatusOK},
+		{"viewer cannot list auth files", http.MethodGet, "/v0/management/auth-files", "viewer-secret", http.StatusForbidden},
+		{"operator lists auth files", http.MethodGet, "/v0/management/auth-files", "operator-secret", http.StatusOK},
+		{"operator cannot download auth files", http.MethodGet, "/v0/management/auth-files/download?name=a.json", "operator-secret", http.StatusForbidden},
+		{"operator cannot read config", http.MethodGet, "/v0/management/config", "operator-secret", http.StatusForbidden},
+		{"admin reads config", http.MethodGet, "/v0/management/config", "admin-secret", http.StatusOK},
+		{"admin lists management keys", http.MethodGet, "/v0/management/management-keys", "admin-secret", http.StatusOK},
+		{"revoked key", http.MethodGet, "/v0/management/usage", "revoked-secret", http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		if got := call(tc.method, tc.path, tc.key); got != tc.want {
+			t.Fatalf("%s: got %d want %d", tc.name, got, tc.want)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		call(http.MethodGet, "/v0/management/usage", "wrong-secret")
+	}
+	if got := call(http.MethodGet, "/v0/management/usage", "another-wrong-secret"); got != http.StatusForbidden {
+		t.Fatalf("expected unknown keys to be banned, got %d", got)
+	}
+	if got := call(http.MethodGet, "/v0/management/usage", "admin-secret"); got != http.StatusOK {
+		t.Fatalf("expected the ban not to lock out other keys, got %d", got)
+	}
+
+	for i := 0; i < 4; i++ {
+		call(http.MethodGet, "/v0/management/usage", "revoked-secret")
+	}
+	if got := call(http.MethodGet, "/v0/management/usage", "revoked-secret"); got != http.StatusForbidden {
+		t.Fatalf("expected the revoked key to be banned, got %d", got)
+	}
+	if got := call(http.MethodGet, "/v0/management/usage", "viewer-secret"); got != http.StatusOK {
+		t.Fatalf("expected the ban to apply to the revoked key only, got %d", got)
+	}
+}
+
+func TestCORSPolicyPerRouteFamily(t *testing.T) {
+	server := newTestServer(t)
+
+	request := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = "proxy.local:8317"
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := request(http.MethodOptions, "/v1/chat/completions", "https://anywhere.example"); rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("expected the default policy to allow every origin, got %d %q", rr.Code, rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if rr := request(http.MethodOptions, "/v0/management/config", "https://anywhere.example"); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected the management API to default to same-origin, got %q", rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+	server.AttachWebsocketRoute("/v1/ws", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	if rr := request(http.MethodOptions, "/v1/ws", "https://anywhere.example"); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected the websocket relay to default to same-origin, got %q", rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+	var wsDefault proxyconfig.CORSPolicy
+	if wsDefault.AllowsWebsocket("https://anywhere.example") || !wsDefault.AllowsWebsocket("") || !wsDefault.AllowsWebsocket("http://127.0.0.1:8317") {
+		t.Fatal("expected websocket upgrades to default to loopback and non-browser clients")
+	}
+
+	cfg := *server.cfg
+	cfg.CORS = proxyconfig.CORSConfig{
+		API:        proxyconfig.CORSPolicy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true, MaxAgeSeconds: 600},
+		Management: proxyconfig.CORSPolicy{AllowedOrigins: []string{"https://panel.example.org"}},
+	}
+	server.UpdateClients(&cfg)
+
+	rr := request(http.MethodOptions, "/v1/chat/completions", "https://chat.example.com")
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://chat.example.com" {
+		t.Fatalf("expected a listed origin to pass preflight, got %d %q", rr.Code, rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "true" || rr.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("expected credentials and max age headers, got %v", rr.Header())
+	}
+	if rr = request(http.MethodPost, "/v1/chat/completions", "https://example.com"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected the apex domain not to match a wildcard subdomain, got %d", rr.Code)
+	}
+	if rr = request(http.MethodOptions, "/v0/management/config", "https://chat.example.com"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected the management policy to apply to management routes, got %d", rr.Code)
+	}
+	if rr = request(http.MethodOptions, "/v0/management/config", "http://proxy.local:8317"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected an origin matching only the Host header to be rejected, got %d", rr.Code)
+	}
+	if rr = request(http.MethodOptions, "/v0/management/config", "http://localhost:8317"); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected loopback origins to pass, got %d", rr.Code)
+	}
+	if rr = request(http.MethodGet, "/v1/models", ""); rr.Code == http.StatusForbidden {
+		t.Fatal("expected requests without an Origin header to pass")
+	}
+}
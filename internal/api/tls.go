@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// certReloadDebounce lets certificate rotations that touch several files settle before reloading.
+const certReloadDebounce = 250 * time.Millisecond
+
+// certReloader serves the configured certificate and client CA to new TLS handshakes and
+// reloads them when the files change. A failed reload keeps the previous material.
+type certReloader struct {
+	mu       sync.RWMutex
+	settings config.TLSConfig
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+
+	watcher *fsnotify.Watcher
+	timerMu sync.Mutex
+	timer   *time.Timer
+}
+
+func newCertReloader(settings config.TLSConfig) (*certReloader, error) {
+	r := &certReloader{}
+	if err := r.apply(settings); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// apply loads the material named by settings and makes it current.
+func (r *certReloader) apply(settings config.TLSConfig) error {
+	cert, err := tls.LoadX509KeyPair(settings.Cert, settings.Key)
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if settings.ClientCA != "" {
+		pem, errRead := os.ReadFile(settings.ClientCA)
+		if errRead != nil {
+			return fmt.Errorf("read tls client ca: %w", errRead)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls client ca %s holds no certificates", settings.ClientCA)
+		}
+	}
+	r.mu.Lock()
+	r.settings = settings
+	r.cert = &cert
+	r.clientCA = pool
+	r.mu.Unlock()
+	return nil
+}
+
+// update switches to new settings, e.g. after a config reload, and watches the new files.
+func (r *certReloader) update(settings config.TLSConfig) {
+	r.mu.RLock()
+	unchanged := r.settings == settings
+	r.mu.RUnlock()
+	if unchanged {
+		return
+	}
+	if err := r.apply(settings); err != nil {
+		log.Errorf("failed to apply tls settings, keeping the previous certificate: %v", err)
+		return
+	}
+	r.watchFiles()
+	log.Info("tls certificate settings updated")
+}
+
+// tlsConfig returns the listener configuration; every handshake picks up the current material.
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.configForClient,
+	}
+}
+
+func (r *certReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.clientCA != nil {
+		cfg.ClientCAs = r.clientCA
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.settings.RequireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg, nil
+}
+
+// watch reloads the material whenever one of its files changes until ctx is done. Directories
+// are watched rather than files so atomic replacements and symlink swaps are seen.
+func (r *certReloader) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.watcher = watcher
+	r.mu.Unlock()
+	r.watchFiles()
+	go func() {
+		defer func() { _ = watcher.Close() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 && r.relevant(event.Name) {
+					r.scheduleReload()
+				}
+			case errWatch, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Errorf("tls certificate watcher error: %v", errWatch)
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *certReloader) files() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	files := []string{r.settings.Cert, r.settings.Key}
+	if r.settings.ClientCA != "" {
+		files = append(files, r.settings.ClientCA)
+	}
+	return files
+}
+
+func (r *certReloader) watchFiles() {
+	r.mu.RLock()
+	watcher := r.watcher
+	r.mu.RUnlock()
+	if watcher == nil {
+		return
+	}
+	for _, file := range r.files() {
+		dir := filepath.Dir(file)
+		if err := watcher.Add(dir); err != nil {
+			log.Errorf("failed to watch tls directory %s: %v", dir, err)
+		}
+	}
+}
+
+// relevant reports whether an event may affect the material. Kubernetes secret mounts swap a
+// "..data" symlink instead of writing the files, so events on that link count as well.
+func (r *certReloader) relevant(name string) bool {
+	name = filepath.Clean(name)
+	for _, file := range r.files() {
+		if filepath.Clean(file) == name {
+			return true
+		}
+	}
+	return filepath.Base(name) == "..data"
+}
+
+func (r *certReloader) scheduleReload() {
+	r.timerMu.Lock()
+	defer r.timerMu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(certReloadDebounce, func() {
+		r.mu.RLock()
+		settings := r.settings
+		r.mu.RUnlock()
+		if err := r.apply(settings); err != nil {
+			log.Errorf("failed to reload tls certificate, keeping the previous one: %v", err)
+			return
+		}
+		log.Info("tls certificate reloaded")
+	})
+}
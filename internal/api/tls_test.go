@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mtlsaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/mtls_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func issueTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) writePEM(t *testing.T, certPath, keyPath string) {
+	t.Helper()
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if keyPath == "" {
+		return
+	}
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+}
+
+func TestTLSListenerReloadsCertificateAndAuthenticatesClients(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	serverTemplate := func(cn string) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:     pkix.Name{CommonName: cn},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+	}
+	settings := config.TLSConfig{
+		Cert:     filepath.Join(dir, "tls.crt"),
+		Key:      filepath.Join(dir, "tls.key"),
+		ClientCA: filepath.Join(dir, "ca.crt"),
+	}
+	ca.writePEM(t, settings.ClientCA, "")
+	issueTestCert(t, serverTemplate("server-1"), ca).writePEM(t, settings.Cert, settings.Key)
+
+	reloader, err := newCertReloader(settings)
+	if err != nil {
+		t.Fatalf("new cert reloader: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = reloader.watch(ctx); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+
+	mtlsaccess.Register()
+	root := &sdkconfig.SDKConfig{MTLSAuth: &sdkconfig.MTLSAuthConfig{PrincipalFrom: "san-uri", AllowedPrincipals: []string{"spiffe://example.org/*"}}}
+	providers, err := sdkaccess.BuildProviders(root)
+	if err != nil {
+		t.Fatalf("build providers: %v", err)
+	}
+	manager := sdkaccess.NewManager()
+	manager.SetProviders(providers)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, errAuth := manager.Authenticate(r.Context(), r)
+		if errAuth != nil {
+			http.Error(w, errAuth.Error(), http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(result.Principal))
+	}))
+	srv.TLS = reloader.tlsConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := issueTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "billing"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	clientWithURI := issueTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "billing"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		URIs:        []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/billing"}},
+	}, ca)
+
+	get := func(clientCert *testCert) (string, *x509.Certificate, int) {
+		tlsCfg := &tls.Config{RootCAs: roots}
+		if clientCert != nil {
+			tlsCfg.Certificates = []tls.Certificate{{Certificate: [][]byte{clientCert.der}, PrivateKey: clientCert.key}}
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+		resp, errGet := httpClient.Get(srv.URL)
+		if errGet != nil {
+			t.Fatalf("get: %v", errGet)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body := make([]byte, 256)
+		n, _ := resp.Body.Read(body)
+		return string(body[:n]), resp.TLS.PeerCertificates[0], resp.StatusCode
+	}
+
+	principal, served, status := get(clientWithURI)
+	if status != http.StatusOK || principal != "spiffe://example.org/billing" {
+		t.Fatalf("expected the SAN URI principal, got %d %q", status, principal)
+	}
+	if served.Subject.CommonName != "server-1" {
+		t.Fatalf("expected the initial certificate, got %s", served.Subject.CommonName)
+	}
+	if _, _, status = get(client); status != http.StatusUnauthorized {
+		t.Fatalf("expected a certificate without the SAN URI to be rejected, got %d", status)
+	}
+	if _, _, status = get(nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected a request without a certificate to be rejected, got %d", status)
+	}
+
+	issueTestCert(t, serverTemplate("server-2"), ca).writePEM(t, settings.Cert, settings.Key)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, served, _ = get(clientWithURI); served.Subject.CommonName == "server-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the rotated certificate to be served")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
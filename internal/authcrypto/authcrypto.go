@@ -0,0 +1,364 @@
+// Package authcrypto encrypts stored auth records at rest. Each record is sealed with its own
+// random data key using AES-256-GCM, and the data key is wrapped with a key-encryption key
+// supplied by the operator. Records sealed with a previous key, or still stored as plaintext,
+// are read transparently and re-sealed with the primary key when they are next loaded.
+package authcrypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// envelopeVersion marks a sealed record. Plaintext auth records never carry this field.
+const envelopeVersion = 1
+
+const keySize = 32
+
+// ErrNoKey is returned when a sealed record is read without any key configured.
+var ErrNoKey = errors.New("authcrypto: record is encrypted but no encryption key is configured")
+
+type envelope struct {
+	Version int    `json:"cliproxy-envelope"`
+	KeyID   string `json:"kid"`
+	DataKey string `json:"dek"`
+	Data    string `json:"data"`
+}
+
+// Keyring holds the primary key used for sealing and the previous keys still accepted
+// when opening records during a rotation.
+type Keyring struct {
+	primary   []byte
+	primaryID string
+	keys      map[string][]byte
+}
+
+// NewKeyring builds a keyring from a primary key and any number of previous keys.
+func NewKeyring(primary []byte, previous ...[]byte) (*Keyring, error) {
+	if len(primary) != keySize {
+		return nil, fmt.Errorf("authcrypto: encryption key must be %d bytes, got %d", keySize, len(primary))
+	}
+	ring := &Keyring{primary: primary, primaryID: keyID(primary), keys: make(map[string][]byte, len(previous)+1)}
+	ring.keys[ring.primaryID] = primary
+	for i, key := range previous {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("authcrypto: previous key %d must be %d bytes, got %d", i+1, keySize, len(key))
+		}
+		ring.keys[keyID(key)] = key
+	}
+	return ring, nil
+}
+
+// PrimaryID returns the identifier recorded in records sealed by this keyring.
+func (k *Keyring) PrimaryID() string {
+	if k == nil {
+		return ""
+	}
+	return k.primaryID
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// ParseKey decodes a 32-byte key given as base64 (standard or URL alphabet) or hex.
+func ParseKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("authcrypto: empty encryption key")
+	}
+	decoders := []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+	}
+	for _, decode := range decoders {
+		if key, err := decode(value); err == nil && len(key) == keySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("authcrypto: encryption key must be %d bytes encoded as base64 or hex", keySize)
+}
+
+var current atomic.Pointer[Keyring]
+
+// SetKeyring installs the process-wide keyring. A nil keyring disables encryption for new writes.
+func SetKeyring(ring *Keyring) { current.Store(ring) }
+
+// Enabled reports whether records are sealed on write.
+func Enabled() bool { return current.Load() != nil }
+
+// IsSealed reports whether data holds a sealed record.
+func IsSealed(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed, []byte(`"cliproxy-envelope"`)) {
+		return false
+	}
+	var env envelope
+	return json.Unmarshal(trimmed, &env) == nil && env.Version == envelopeVersion
+}
+
+// Seal encrypts plain with the primary key. Without a keyring the input is returned unchanged.
+func Seal(plain []byte) ([]byte, error) {
+	ring := current.Load()
+	if ring == nil {
+		return plain, nil
+	}
+	return ring.seal(plain)
+}
+
+// Open returns the plaintext of data. Plaintext input is returned as is. stale reports whether
+// the record should be rewritten: it is plaintext while encryption is enabled, or it was sealed
+// with a key other than the primary one.
+func Open(data []byte) (plain []byte, stale bool, err error) {
+	ring := current.Load()
+	if !IsSealed(data) {
+		return data, ring != nil && len(bytes.TrimSpace(data)) > 0, nil
+	}
+	if ring == nil {
+		return nil, false, ErrNoKey
+	}
+	return ring.open(data)
+}
+
+func (k *Keyring) seal(plain []byte) ([]byte, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, fmt.Errorf("authcrypto: generate data key: %w", err)
+	}
+	data, err := gcmSeal(dek, plain)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := gcmSeal(k.primary, dek)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{
+		Version: envelopeVersion,
+		KeyID:   k.primaryID,
+		DataKey: base64.StdEncoding.EncodeToString(wrapped),
+		Data:    base64.StdEncoding.EncodeToString(data),
+	})
+}
+
+func (k *Keyring) open(data []byte) ([]byte, bool, error) {
+	var env envelope
+	if err := json.Unmarshal(bytes.TrimSpace(data), &env); err != nil {
+		return nil, false, fmt.Errorf("authcrypto: decode envelope: %w", err)
+	}
+	kek, ok := k.keys[env.KeyID]
+	if !ok {
+		return nil, false, fmt.Errorf("authcrypto: record was sealed with unknown key %s", env.KeyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(env.DataKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("authcrypto: decode data key: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return nil, false, fmt.Errorf("authcrypto: decode data: %w", err)
+	}
+	dek, err := gcmOpen(kek, wrapped)
+	if err != nil {
+		return nil, false, fmt.Errorf("authcrypto: unwrap data key: %w", err)
+	}
+	plain, err := gcmOpen(dek, sealed)
+	if err != nil {
+		return nil, false, fmt.Errorf("authcrypto: decrypt record: %w", err)
+	}
+	return plain, env.KeyID != k.primaryID, nil
+}
+
+func gcmSeal(key, plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypto: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypto: %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("authcrypto: generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func gcmOpen(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// ReadFile reads and opens the record at path. Stale records are re-sealed in place with the
+// primary key; migrated reports whether that happened so callers can persist the change remotely.
+func ReadFile(path string) (plain []byte, migrated bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	plain, stale, err := Open(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	if !stale {
+		return plain, false, nil
+	}
+	sealed, err := Seal(plain)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = writeAtomic(path, sealed); err != nil {
+		return nil, false, fmt.Errorf("authcrypto: re-seal %s: %w", path, err)
+	}
+	return plain, true, nil
+}
+
+// WriteFile seals plain and writes it to path atomically. The write is skipped when the file
+// already holds the same record sealed with the primary key, because a fresh nonce would
+// otherwise change the bytes on every save and wake up file watchers for nothing.
+func WriteFile(path string, plain []byte) error {
+	if existing, err := os.ReadFile(path); err == nil {
+		if current, stale, errOpen := Open(existing); errOpen == nil && !stale && jsonEqual(current, plain) {
+			return nil
+		}
+	}
+	sealed, err := Seal(plain)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeAtomic(path, sealed)
+}
+
+// TokenSaver is implemented by the provider token storages in internal/auth.
+type TokenSaver interface {
+	SaveTokenToFile(authFilePath string) error
+}
+
+// SaveStorage persists a provider token storage to path, sealing it when encryption is enabled.
+// The storage writes its plaintext into a private temporary directory outside the auth
+// directory, so it never lands in a tree that a token store syncs, and the directory is
+// removed once the record is sealed.
+func SaveStorage(storage TokenSaver, path string) error {
+	if !Enabled() {
+		return storage.SaveTokenToFile(path)
+	}
+	dir, err := os.MkdirTemp("", "cliproxy-auth-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	tmp := filepath.Join(dir, filepath.Base(path))
+	if err = storage.SaveTokenToFile(tmp); err != nil {
+		return err
+	}
+	plain, err := os.ReadFile(tmp)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, plain)
+}
+
+func writeAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func jsonEqual(a, b []byte) bool {
+	var objA, objB any
+	if json.Unmarshal(a, &objA) != nil || json.Unmarshal(b, &objB) != nil {
+		return bytes.Equal(bytes.TrimSpace(a), bytes.TrimSpace(b))
+	}
+	left, errA := json.Marshal(objA)
+	right, errB := json.Marshal(objB)
+	return errA == nil && errB == nil && bytes.Equal(left, right)
+}
+
+// LoadKeyring builds a keyring from operator settings. key is the primary key; keyFile names a
+// file whose first non-comment line is the primary key and whose remaining lines are previous
+// keys; previous is a comma-separated list of previous keys. It returns nil when nothing is set.
+func LoadKeyring(key, keyFile, previous string) (*Keyring, error) {
+	var values []string
+	if key = strings.TrimSpace(key); key != "" {
+		values = append(values, key)
+	}
+	if keyFile = strings.TrimSpace(keyFile); keyFile != "" {
+		raw, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("authcrypto: read key file: %w", err)
+		}
+		for _, line := range strings.Split(string(raw), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				values = append(values, line)
+			}
+		}
+	}
+	for _, value := range strings.Split(previous, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	keys := make([][]byte, 0, len(values))
+	for _, value := range values {
+		parsed, err := ParseKey(value)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, parsed)
+	}
+	return NewKeyring(keys[0], keys[1:]...)
+}
+
+// OpenFile reads and opens the record at path without rewriting it.
+func OpenFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, _, err := Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,116 @@
+package authcrypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func TestMigrationAndRotation(t *testing.T) {
+	defer SetKeyring(nil)
+	path := filepath.Join(t.TempDir(), "claude-dev@example.com.json")
+	plain := []byte(`{"type":"claude","refresh_token":"rt-secret"}`)
+	if err := os.WriteFile(path, plain, 0o600); err != nil {
+		t.Fatalf("write plaintext: %v", err)
+	}
+
+	oldKey, newKey := newKey(t), newKey(t)
+	ring, err := LoadKeyring(base64.StdEncoding.EncodeToString(oldKey), "", "")
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	SetKeyring(ring)
+
+	got, migrated, err := ReadFile(path)
+	if err != nil || !migrated || !bytes.Equal(got, plain) {
+		t.Fatalf("expected the plaintext record to be migrated, got %q migrated=%v err=%v", got, migrated, err)
+	}
+	sealed, _ := os.ReadFile(path)
+	if !IsSealed(sealed) || bytes.Contains(sealed, []byte("rt-secret")) {
+		t.Fatalf("expected the record to be sealed on disk, got %s", sealed)
+	}
+	if _, migrated, _ = ReadFile(path); migrated {
+		t.Fatal("expected a record sealed with the primary key to be left alone")
+	}
+	if err = WriteFile(path, []byte(`{"refresh_token":"rt-secret","type":"claude"}`)); err != nil {
+		t.Fatalf("write unchanged record: %v", err)
+	}
+	if unchanged, _ := os.ReadFile(path); !bytes.Equal(unchanged, sealed) {
+		t.Fatal("expected an unchanged record not to be rewritten")
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	content := "# rotated\n" + base64.StdEncoding.EncodeToString(newKey) + "\n" + base64.StdEncoding.EncodeToString(oldKey) + "\n"
+	if err = os.WriteFile(keyFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	if ring, err = LoadKeyring("", keyFile, ""); err != nil {
+		t.Fatalf("load rotated keyring: %v", err)
+	}
+	SetKeyring(ring)
+	if got, migrated, err = ReadFile(path); err != nil || !migrated || !bytes.Equal(got, plain) {
+		t.Fatalf("expected the record to be re-sealed with the new key, got %q migrated=%v err=%v", got, migrated, err)
+	}
+
+	onlyOld, _ := NewKeyring(oldKey)
+	SetKeyring(onlyOld)
+	if _, err = OpenFile(path); err == nil {
+		t.Fatal("expected the retired key to no longer open the record")
+	}
+	SetKeyring(nil)
+	if _, err = OpenFile(path); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey without a keyring, got %v", err)
+	}
+}
+
+type fakeStorage struct {
+	payload string
+	written *string
+}
+
+func (f fakeStorage) SaveTokenToFile(path string) error {
+	if f.written != nil {
+		*f.written = path
+	}
+	return os.WriteFile(path, []byte(f.payload), 0o600)
+}
+
+func TestSaveStorageSealsProviderTokens(t *testing.T) {
+	defer SetKeyring(nil)
+	ring, _ := NewKeyring(newKey(t))
+	SetKeyring(ring)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "codex.json")
+	var written string
+	if err := SaveStorage(fakeStorage{payload: `{"type":"codex","refresh_token":"rt"}`, written: &written}, path); err != nil {
+		t.Fatalf("save storage: %v", err)
+	}
+	if strings.HasPrefix(written, dir) {
+		t.Fatalf("expected the plaintext to be staged outside the auth directory, got %s", written)
+	}
+	if _, err := os.Stat(written); !os.IsNotExist(err) {
+		t.Fatalf("expected the staged plaintext to be removed, got %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected no temporary files to remain, got %d entries", len(entries))
+	}
+	plain, err := OpenFile(path)
+	if err != nil || string(plain) != `{"type":"codex","refresh_token":"rt"}` {
+		t.Fatalf("unexpected record %q: %v", plain, err)
+	}
+}
@@ -0,0 +1,561 @@
+// Package batches emulates the OpenAI Files and Batch APIs. Uploaded JSONL files and batch
+// state live in a local directory, and the proxy runs the requests of each batch itself with
+// bounded concurrency. Unfinished batches are resumed when the manager starts, skipping the
+// requests whose results were already written.
+package batches
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoints a batch may target.
+const (
+	EndpointChatCompletions = "/v1/chat/completions"
+	EndpointCompletions     = "/v1/completions"
+	EndpointResponses       = "/v1/responses"
+	EndpointEmbeddings      = "/v1/embeddings"
+)
+
+// File purposes.
+const (
+	PurposeBatch       = "batch"
+	PurposeBatchOutput = "batch_output"
+)
+
+// Batch statuses, as reported by the OpenAI Batch API.
+const (
+	StatusValidating = "validating"
+	StatusFailed     = "failed"
+	StatusInProgress = "in_progress"
+	StatusFinalizing = "finalizing"
+	StatusCompleted  = "completed"
+	StatusExpired    = "expired"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+// CompletionWindow is the only completion window the Batch API offers.
+const CompletionWindow = "24h"
+
+// Defaults applied when the config leaves the limits unset.
+const (
+	DefaultMaxConcurrency = 4
+	DefaultMaxFileBytes   = 200 << 20
+)
+
+// Errors returned by the manager; other errors describe invalid requests.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrInUse    = errors.New("in use")
+	ErrTooLarge = errors.New("too large")
+)
+
+// Owner identifies the client that uploaded a file or created a batch. Files and batches
+// are only visible to their owner, and batch requests run with the owner's key policy. The
+// stored owner must not hold a credential: ID names the client within its access provider,
+// and the executor maps it back to the current principal before each request.
+type Owner struct {
+	Provider string            `json:"provider,omitempty"`
+	ID       string            `json:"id"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// same reports whether o and other are the same client.
+func (o Owner) same(other Owner) bool {
+	return o.Provider == other.Provider && o.ID == other.ID
+}
+
+// File is an uploaded or generated file.
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Status    string `json:"status"`
+}
+
+// RequestCounts tracks the requests of a batch.
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// BatchError describes why a batch failed validation.
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    *int   `json:"line"`
+}
+
+// BatchErrors lists the validation errors of a failed batch.
+type BatchErrors struct {
+	Object string       `json:"object"`
+	Data   []BatchError `json:"data"`
+}
+
+// Batch is a batch job.
+type Batch struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           *BatchErrors      `json:"errors"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     *string           `json:"output_file_id"`
+	ErrorFileID      *string           `json:"error_file_id"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     *int64            `json:"in_progress_at"`
+	ExpiresAt        int64             `json:"expires_at"`
+	FinalizingAt     *int64            `json:"finalizing_at"`
+	CompletedAt      *int64            `json:"completed_at"`
+	FailedAt         *int64            `json:"failed_at"`
+	ExpiredAt        *int64            `json:"expired_at"`
+	CancellingAt     *int64            `json:"cancelling_at"`
+	CancelledAt      *int64            `json:"cancelled_at"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// CreateParams are the fields of a batch creation request.
+type CreateParams struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+type fileRecord struct {
+	File
+	Owner Owner `json:"owner"`
+}
+
+type batchRecord struct {
+	Batch
+	Owner Owner `json:"owner"`
+}
+
+// Manager stores files and batches and runs the batches. It is safe for concurrent use.
+type Manager struct {
+	dir     string
+	limiter *limiter
+
+	mu           sync.Mutex
+	maxFileBytes int64
+	files        map[string]*fileRecord
+	batches      map[string]*batchRecord
+	running      map[string]*job
+	exec         Executor
+}
+
+// NewManager returns a manager keeping its state in dir.
+func NewManager(dir string) *Manager {
+	return &Manager{
+		dir:          dir,
+		limiter:      newLimiter(DefaultMaxConcurrency),
+		maxFileBytes: DefaultMaxFileBytes,
+		files:        make(map[string]*fileRecord),
+		batches:      make(map[string]*batchRecord),
+		running:      make(map[string]*job),
+	}
+}
+
+// SetLimits applies the configured concurrency and upload size; zero keeps the defaults.
+func (m *Manager) SetLimits(maxConcurrency int, maxFileBytes int64) {
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	if maxFileBytes <= 0 {
+		maxFileBytes = DefaultMaxFileBytes
+	}
+	m.limiter.setLimit(maxConcurrency)
+	m.mu.Lock()
+	m.maxFileBytes = maxFileBytes
+	m.mu.Unlock()
+}
+
+// Start loads the stored files and batches and resumes the unfinished batches with exec.
+func (m *Manager) Start(exec Executor) error {
+	for _, sub := range []string{"files", "batches"} {
+		if err := os.MkdirAll(filepath.Join(m.dir, sub), 0o700); err != nil {
+			return fmt.Errorf("batches: create %s dir: %w", sub, err)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exec = exec
+	if err := loadRecords(filepath.Join(m.dir, "files"), func(raw []byte) error {
+		var rec fileRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		m.files[rec.ID] = &rec
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := loadRecords(filepath.Join(m.dir, "batches"), func(raw []byte) error {
+		var rec batchRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		m.batches[rec.ID] = &rec
+		return nil
+	}); err != nil {
+		return err
+	}
+	for id, rec := range m.batches {
+		switch rec.Status {
+		case StatusValidating, StatusInProgress, StatusFinalizing, StatusCancelling:
+			m.launchLocked(id)
+		}
+	}
+	return nil
+}
+
+// Stop interrupts the running batches without finalizing them, so the next Start resumes them.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	jobs := make([]*job, 0, len(m.running))
+	for _, j := range m.running {
+		jobs = append(jobs, j)
+	}
+	m.mu.Unlock()
+	for _, j := range jobs {
+		j.cancel(errShutdown)
+		<-j.done
+	}
+}
+
+// CreateFile stores an uploaded batch input file.
+func (m *Manager) CreateFile(owner Owner, filename, purpose string, r io.Reader) (File, error) {
+	if purpose != PurposeBatch {
+		return File{}, fmt.Errorf("purpose must be %q", PurposeBatch)
+	}
+	m.mu.Lock()
+	limit := m.maxFileBytes
+	m.mu.Unlock()
+	id := newID("file-")
+	path := m.contentPath(id)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return File{}, fmt.Errorf("batches: create file: %w", err)
+	}
+	written, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil && written > limit {
+		err = fmt.Errorf("%w: files are limited to %d MB", ErrTooLarge, limit>>20)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return File{}, err
+	}
+	rec := &fileRecord{File: newFile(id, filepath.Base(filename), purpose, written), Owner: owner}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err = m.saveFileLocked(rec); err != nil {
+		_ = os.Remove(path)
+		return File{}, err
+	}
+	return rec.File, nil
+}
+
+// ListFiles returns the owner's files, newest first, optionally filtered by purpose.
+func (m *Manager) ListFiles(owner Owner, purpose string) []File {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make([]File, 0)
+	for _, rec := range m.files {
+		if rec.Owner.same(owner) && (purpose == "" || rec.Purpose == purpose) {
+			files = append(files, rec.File)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].CreatedAt != files[j].CreatedAt {
+			return files[i].CreatedAt > files[j].CreatedAt
+		}
+		return files[i].ID > files[j].ID
+	})
+	return files
+}
+
+// GetFile returns one of the owner's files.
+func (m *Manager) GetFile(owner Owner, id string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, err := m.fileLocked(owner, id)
+	if err != nil {
+		return File{}, err
+	}
+	return rec.File, nil
+}
+
+// OpenFile opens the content of one of the owner's files.
+func (m *Manager) OpenFile(owner Owner, id string) (File, *os.File, error) {
+	file, err := m.GetFile(owner, id)
+	if err != nil {
+		return File{}, nil, err
+	}
+	f, err := os.Open(m.contentPath(id))
+	if err != nil {
+		return File{}, nil, fmt.Errorf("batches: open file: %w", err)
+	}
+	return file, f, nil
+}
+
+// DeleteFile removes one of the owner's files unless an unfinished batch reads it.
+func (m *Manager) DeleteFile(owner Owner, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.fileLocked(owner, id); err != nil {
+		return err
+	}
+	for _, rec := range m.batches {
+		if rec.InputFileID == id && !finished(rec.Status) {
+			return fmt.Errorf("%w: batch %s still reads file %s", ErrInUse, rec.ID, id)
+		}
+	}
+	if err := os.Remove(m.contentPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("batches: delete file: %w", err)
+	}
+	if err := os.Remove(m.fileMetaPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("batches: delete file: %w", err)
+	}
+	delete(m.files, id)
+	return nil
+}
+
+// CreateBatch validates the request and queues the batch.
+func (m *Manager) CreateBatch(owner Owner, params CreateParams) (Batch, error) {
+	switch params.Endpoint {
+	case EndpointChatCompletions, EndpointCompletions, EndpointResponses, EndpointEmbeddings:
+	default:
+		return Batch{}, fmt.Errorf("unsupported endpoint %q", params.Endpoint)
+	}
+	if params.CompletionWindow != CompletionWindow {
+		return Batch{}, fmt.Errorf("completion_window must be %q", CompletionWindow)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	input, err := m.fileLocked(owner, params.InputFileID)
+	if err != nil {
+		return Batch{}, fmt.Errorf("input file %s: %w", params.InputFileID, err)
+	}
+	if input.Purpose != PurposeBatch {
+		return Batch{}, fmt.Errorf("input file %s must have purpose %q", input.ID, PurposeBatch)
+	}
+	now := time.Now()
+	rec := &batchRecord{
+		Batch: Batch{
+			ID:               newID("batch_"),
+			Object:           "batch",
+			Endpoint:         params.Endpoint,
+			InputFileID:      input.ID,
+			CompletionWindow: CompletionWindow,
+			Status:           StatusValidating,
+			CreatedAt:        now.Unix(),
+			ExpiresAt:        now.Add(24 * time.Hour).Unix(),
+			Metadata:         params.Metadata,
+		},
+		Owner: owner,
+	}
+	if err = m.saveBatchLocked(rec); err != nil {
+		return Batch{}, err
+	}
+	m.launchLocked(rec.ID)
+	return rec.Batch, nil
+}
+
+// GetBatch returns one of the owner's batches.
+func (m *Manager) GetBatch(owner Owner, id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, err := m.batchLocked(owner, id)
+	if err != nil {
+		return Batch{}, err
+	}
+	return rec.Batch, nil
+}
+
+// CancelBatch stops dispatching the batch's requests. The batch is cancelled, with the
+// results gathered so far, once the requests in flight have stopped.
+func (m *Manager) CancelBatch(owner Owner, id string) (Batch, error) {
+	m.mu.Lock()
+	rec, err := m.batchLocked(owner, id)
+	if err != nil {
+		m.mu.Unlock()
+		return Batch{}, err
+	}
+	if rec.Status != StatusValidating && rec.Status != StatusInProgress {
+		m.mu.Unlock()
+		return Batch{}, fmt.Errorf("%w: batch %s is %s", ErrInUse, id, rec.Status)
+	}
+	now := time.Now().Unix()
+	rec.Status, rec.CancellingAt = StatusCancelling, &now
+	err = m.saveBatchLocked(rec)
+	snapshot := rec.Batch
+	j := m.running[id]
+	m.mu.Unlock()
+	if err != nil {
+		return Batch{}, err
+	}
+	if j != nil {
+		j.cancel(errCancelled)
+	}
+	return snapshot, nil
+}
+
+// ListBatches returns the owner's batches, newest first, starting after the batch named by
+// after. The second result reports whether more batches follow.
+func (m *Manager) ListBatches(owner Owner, after string, limit int) ([]Batch, bool) {
+	m.mu.Lock()
+	all := make([]Batch, 0)
+	for _, rec := range m.batches {
+		if rec.Owner.same(owner) {
+			all = append(all, rec.Batch)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].CreatedAt != all[j].CreatedAt {
+			return all[i].CreatedAt > all[j].CreatedAt
+		}
+		return all[i].ID > all[j].ID
+	})
+	if after != "" {
+		for i, batch := range all {
+			if batch.ID == after {
+				all = all[i+1:]
+				break
+			}
+		}
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if len(all) > limit {
+		return all[:limit], true
+	}
+	return all, false
+}
+
+func (m *Manager) fileLocked(owner Owner, id string) (*fileRecord, error) {
+	rec, ok := m.files[id]
+	if !ok || !rec.Owner.same(owner) {
+		return nil, fmt.Errorf("file %s %w", id, ErrNotFound)
+	}
+	return rec, nil
+}
+
+func (m *Manager) batchLocked(owner Owner, id string) (*batchRecord, error) {
+	rec, ok := m.batches[id]
+	if !ok || !rec.Owner.same(owner) {
+		return nil, fmt.Errorf("batch %s %w", id, ErrNotFound)
+	}
+	return rec, nil
+}
+
+func (m *Manager) saveFileLocked(rec *fileRecord) error {
+	if err := writeJSONAtomic(m.fileMetaPath(rec.ID), rec); err != nil {
+		return err
+	}
+	m.files[rec.ID] = rec
+	return nil
+}
+
+func (m *Manager) saveBatchLocked(rec *batchRecord) error {
+	if err := writeJSONAtomic(m.batchPath(rec.ID, ".json"), rec); err != nil {
+		return err
+	}
+	m.batches[rec.ID] = rec
+	return nil
+}
+
+func (m *Manager) contentPath(id string) string {
+	return filepath.Join(m.dir, "files", id+".jsonl")
+}
+
+func (m *Manager) fileMetaPath(id string) string {
+	return filepath.Join(m.dir, "files", id+".json")
+}
+
+func (m *Manager) batchPath(id, suffix string) string {
+	return filepath.Join(m.dir, "batches", id+suffix)
+}
+
+func finished(status string) bool {
+	switch status {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+func newFile(id, filename, purpose string, size int64) File {
+	return File{
+		ID:        id,
+		Object:    "file",
+		Bytes:     size,
+		CreatedAt: time.Now().Unix(),
+		Filename:  filename,
+		Purpose:   purpose,
+		Status:    "processed",
+	}
+}
+
+func newID(prefix string) string {
+	raw := make([]byte, 12)
+	_, _ = rand.Read(raw)
+	return prefix + hex.EncodeToString(raw)
+}
+
+func loadRecords(dir string, load func([]byte) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("batches: read %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		raw, errRead := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if errRead != nil {
+			return fmt.Errorf("batches: read %s: %w", entry.Name(), errRead)
+		}
+		if errLoad := load(raw); errLoad != nil {
+			return fmt.Errorf("batches: decode %s: %w", entry.Name(), errLoad)
+		}
+	}
+	return nil
+}
+
+func writeJSONAtomic(path string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("batches: marshal %s: %w", filepath.Base(path), err)
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("batches: write %s: %w", filepath.Base(path), err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("batches: rename %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
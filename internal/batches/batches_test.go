@@ -0,0 +1,257 @@
+package batches
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+var testOwner = Owner{Provider: "config-inline", ID: "sha256:client"}
+
+func echoExecutor(_ context.Context, owner Owner, _ string, body []byte) Result {
+	if !owner.same(testOwner) {
+		return Result{Status: http.StatusUnauthorized, Body: []byte(`{"error":{"message":"wrong owner"}}`)}
+	}
+	if gjson.GetBytes(body, "model").String() == "missing" {
+		return Result{Status: http.StatusBadRequest, Body: []byte(`{"error":{"message":"unknown model"}}`)}
+	}
+	return Result{Status: http.StatusOK, Body: []byte(`{"object":"chat.completion","model":"` + gjson.GetBytes(body, "model").String() + `"}`)}
+}
+
+func uploadInput(t *testing.T, m *Manager, lines ...string) File {
+	t.Helper()
+	file, err := m.CreateFile(testOwner, "input.jsonl", PurposeBatch, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	return file
+}
+
+func waitForBatch(t *testing.T, m *Manager, id string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		batch, err := m.GetBatch(testOwner, id)
+		if err != nil {
+			t.Fatalf("GetBatch: %v", err)
+		}
+		if finished(batch.Status) {
+			return batch
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch %s still %s", id, batch.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readLines(t *testing.T, m *Manager, id string) []string {
+	t.Helper()
+	_, f, err := m.OpenFile(testOwner, id)
+	if err != nil {
+		t.Fatalf("OpenFile(%s): %v", id, err)
+	}
+	defer func() { _ = f.Close() }()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func TestBatchRunsRequests(t *testing.T) {
+	m := NewManager(t.TempDir())
+	if err := m.Start(echoExecutor); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer m.Stop()
+
+	input := uploadInput(t, m,
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"missing"}}`,
+		`{"custom_id":"c","method":"POST","url":"/v1/chat/completions","body":{"model":"gemini-2.5-pro"}}`,
+	)
+	batch, err := m.CreateBatch(testOwner, CreateParams{InputFileID: input.ID, Endpoint: EndpointChatCompletions, CompletionWindow: CompletionWindow})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	if _, err = m.GetBatch(Owner{Provider: "config-inline", ID: "sha256:other"}, batch.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("batch visible to another client: %v", err)
+	}
+
+	batch = waitForBatch(t, m, batch.ID)
+	if batch.Status != StatusCompleted {
+		t.Fatalf("status = %s, errors = %+v", batch.Status, batch.Errors)
+	}
+	if batch.RequestCounts != (RequestCounts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Fatalf("request counts = %+v", batch.RequestCounts)
+	}
+	if batch.OutputFileID == nil || batch.ErrorFileID == nil {
+		t.Fatalf("output file %v, error file %v", batch.OutputFileID, batch.ErrorFileID)
+	}
+	output := readLines(t, m, *batch.OutputFileID)
+	if len(output) != 2 {
+		t.Fatalf("output lines = %d", len(output))
+	}
+	for _, line := range output {
+		if gjson.Get(line, "response.status_code").Int() != http.StatusOK || gjson.Get(line, "response.body.object").String() != "chat.completion" {
+			t.Fatalf("unexpected output line %s", line)
+		}
+	}
+	errorLines := readLines(t, m, *batch.ErrorFileID)
+	if len(errorLines) != 1 || gjson.Get(errorLines[0], "custom_id").String() != "b" {
+		t.Fatalf("error lines = %v", errorLines)
+	}
+}
+
+func TestBatchValidation(t *testing.T) {
+	m := NewManager(t.TempDir())
+	if err := m.Start(echoExecutor); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer m.Stop()
+
+	input := uploadInput(t, m,
+		`{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"gpt-4o"}}`,
+		`not json`,
+	)
+	if _, err := m.CreateBatch(testOwner, CreateParams{InputFileID: input.ID, Endpoint: "/v1/moderations", CompletionWindow: CompletionWindow}); err == nil {
+		t.Fatal("expected unsupported endpoint to be rejected")
+	}
+	batch, err := m.CreateBatch(testOwner, CreateParams{InputFileID: input.ID, Endpoint: EndpointChatCompletions, CompletionWindow: CompletionWindow})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	batch = waitForBatch(t, m, batch.ID)
+	if batch.Status != StatusFailed || batch.Errors == nil || len(batch.Errors.Data) != 1 {
+		t.Fatalf("status = %s, errors = %+v", batch.Status, batch.Errors)
+	}
+	if e := batch.Errors.Data[0]; e.Code != "mismatched_endpoint" || e.Line == nil || *e.Line != 1 {
+		t.Fatalf("error = %+v", e)
+	}
+}
+
+func TestBatchFailsWhenOwnerRevoked(t *testing.T) {
+	m := NewManager(t.TempDir())
+	revoked := func(context.Context, Owner, string, []byte) Result { return Result{Revoked: true} }
+	if err := m.Start(revoked); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer m.Stop()
+
+	input := uploadInput(t, m,
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+	)
+	batch, err := m.CreateBatch(testOwner, CreateParams{InputFileID: input.ID, Endpoint: EndpointChatCompletions, CompletionWindow: CompletionWindow})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	batch = waitForBatch(t, m, batch.ID)
+	if batch.Status != StatusFailed || batch.FailedAt == nil || batch.Errors == nil || batch.Errors.Data[0].Code != "invalid_api_key" {
+		t.Fatalf("status = %s, errors = %+v", batch.Status, batch.Errors)
+	}
+	if batch.RequestCounts.Completed != 0 || batch.OutputFileID != nil {
+		t.Fatalf("expected no request to run, counts = %+v", batch.RequestCounts)
+	}
+}
+
+func TestBatchFailsWhenProgressCannotBeOpened(t *testing.T) {
+	dir := t.TempDir()
+	blocking := func(ctx context.Context, _ Owner, _ string, _ []byte) Result {
+		<-ctx.Done()
+		return Result{}
+	}
+	m := NewManager(dir)
+	if err := m.Start(blocking); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	input := uploadInput(t, m, `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`)
+	batch, err := m.CreateBatch(testOwner, CreateParams{InputFileID: input.ID, Endpoint: EndpointChatCompletions, CompletionWindow: CompletionWindow})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	m.Stop()
+
+	// A directory in place of the partial output file cannot be read back on resume.
+	partial := m.batchPath(batch.ID, ".output.partial")
+	if err = os.RemoveAll(partial); err != nil {
+		t.Fatalf("remove partial output: %v", err)
+	}
+	if err = os.Mkdir(partial, 0o700); err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	restarted := NewManager(dir)
+	if err = restarted.Start(echoExecutor); err != nil {
+		t.Fatalf("Start after restart: %v", err)
+	}
+	defer restarted.Stop()
+	batch = waitForBatch(t, restarted, batch.ID)
+	if batch.Status != StatusFailed || batch.FailedAt == nil || batch.Errors == nil || batch.Errors.Data[0].Code != "server_error" {
+		t.Fatalf("status = %s, errors = %+v", batch.Status, batch.Errors)
+	}
+	if batch.OutputFileID != nil {
+		t.Fatalf("expected no output file, got %s", *batch.OutputFileID)
+	}
+}
+
+func TestBatchResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	release := make(chan struct{})
+	blocking := func(ctx context.Context, owner Owner, endpoint string, body []byte) Result {
+		if gjson.GetBytes(body, "model").String() == "slow" {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return Result{}
+			}
+		}
+		return echoExecutor(ctx, owner, endpoint, body)
+	}
+	m := NewManager(dir)
+	if err := m.Start(blocking); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	input := uploadInput(t, m,
+		`{"custom_id":"fast","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"slow","method":"POST","url":"/v1/chat/completions","body":{"model":"slow"}}`,
+	)
+	batch, err := m.CreateBatch(testOwner, CreateParams{InputFileID: input.ID, Endpoint: EndpointChatCompletions, CompletionWindow: CompletionWindow})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current, _ := m.GetBatch(testOwner, batch.ID)
+		if current.Status == StatusInProgress {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch did not start: %s", current.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+
+	close(release)
+	restarted := NewManager(dir)
+	if err = restarted.Start(blocking); err != nil {
+		t.Fatalf("Start after restart: %v", err)
+	}
+	defer restarted.Stop()
+	batch = waitForBatch(t, restarted, batch.ID)
+	if batch.Status != StatusCompleted || batch.RequestCounts.Completed != 2 {
+		t.Fatalf("status = %s, counts = %+v", batch.Status, batch.RequestCounts)
+	}
+	if output := readLines(t, restarted, *batch.OutputFileID); len(output) != 2 {
+		t.Fatalf("output lines = %v", output)
+	}
+}
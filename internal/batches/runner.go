@@ -0,0 +1,541 @@
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxRequests is the largest number of requests a batch input file may hold.
+const MaxRequests = 50000
+
+// maxAttempts bounds how often a request failing with a transient upstream error is tried.
+// Waits for cooling-down credentials are not counted.
+const maxAttempts = 4
+
+// saveInterval throttles how often progress is persisted while a batch runs.
+const saveInterval = time.Second
+
+var (
+	errCancelled = errors.New("batch cancelled")
+	errShutdown  = errors.New("batch manager stopped")
+	errRevoked   = errors.New("batch owner revoked")
+)
+
+// Result is the response an endpoint gave to one batch request.
+type Result struct {
+	// Status is the HTTP status the endpoint answered with.
+	Status int
+	// Body is the JSON response or error body.
+	Body []byte
+	// RetryAfter is set when every credential is cooling down; the request is retried
+	// after it without counting as an attempt.
+	RetryAfter time.Duration
+	// Revoked reports that the owner may no longer make requests. The request is not run,
+	// and the batch fails without running the requests that are left.
+	Revoked bool
+}
+
+// Executor runs one batch request against endpoint on behalf of owner.
+type Executor func(ctx context.Context, owner Owner, endpoint string, body []byte) Result
+
+// job is a running batch.
+type job struct {
+	id     string
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	output    *os.File
+	errors    *os.File
+	completed map[string]bool
+	counts    RequestCounts
+	lastSaved time.Time
+	pauseTill time.Time
+}
+
+// finished reports whether the request already has a result.
+func (j *job) finished(customID string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.completed[customID]
+}
+
+// requestLine is one request of a batch input file.
+type requestLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// launchLocked starts the goroutine running the batch. m.mu must be held.
+func (m *Manager) launchLocked(id string) {
+	if _, ok := m.running[id]; ok || m.exec == nil {
+		return
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	j := &job{id: id, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	m.running[id] = j
+	go func() {
+		defer close(j.done)
+		defer func() {
+			m.mu.Lock()
+			delete(m.running, id)
+			m.mu.Unlock()
+		}()
+		m.run(j)
+	}()
+}
+
+// run executes the batch and finalizes it. A batch interrupted by Stop is left unfinished
+// so it resumes on the next start.
+func (m *Manager) run(j *job) {
+	m.mu.Lock()
+	rec := *m.batches[j.id]
+	m.mu.Unlock()
+
+	if rec.Status == StatusCancelling {
+		m.finalize(j, StatusCancelled, nil)
+		return
+	}
+	lines, batchErrors := m.readInput(rec)
+	if len(batchErrors) > 0 {
+		m.update(j.id, func(b *batchRecord) {
+			now := time.Now().Unix()
+			b.Status, b.FailedAt = StatusFailed, &now
+			b.Errors = &BatchErrors{Object: "list", Data: batchErrors}
+		})
+		return
+	}
+	if err := m.openProgress(j); err != nil {
+		log.Errorf("batch %s: %v", j.id, err)
+		m.update(j.id, func(b *batchRecord) {
+			b.Errors = &BatchErrors{Object: "list", Data: []BatchError{{Code: "server_error", Message: fmt.Sprintf("failed to open the batch progress: %v", err)}}}
+		})
+		m.finalize(j, StatusFailed, nil)
+		return
+	}
+	defer func() {
+		_ = j.output.Close()
+		_ = j.errors.Close()
+	}()
+	m.update(j.id, func(b *batchRecord) {
+		if b.InProgressAt == nil {
+			now := time.Now().Unix()
+			b.InProgressAt = &now
+		}
+		if b.Status == StatusValidating {
+			b.Status = StatusInProgress
+		}
+		b.RequestCounts = RequestCounts{Total: len(lines), Completed: j.counts.Completed, Failed: j.counts.Failed}
+	})
+	j.counts.Total = len(lines)
+
+	expires := time.Unix(rec.ExpiresAt, 0)
+	var wg sync.WaitGroup
+	for i := range lines {
+		line := &lines[i]
+		if j.finished(line.CustomID) || j.ctx.Err() != nil || !time.Now().Before(expires) {
+			continue
+		}
+		if m.limiter.acquire(j.ctx) != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer m.limiter.release()
+			if result, ok := m.execute(j, rec, line, expires); ok {
+				m.record(j, line, result)
+			}
+		}()
+	}
+	wg.Wait()
+
+	switch cause := context.Cause(j.ctx); {
+	case errors.Is(cause, errShutdown):
+		m.saveProgress(j, true)
+		return
+	case errors.Is(cause, errCancelled):
+		m.finalize(j, StatusCancelled, nil)
+	case errors.Is(cause, errRevoked):
+		m.update(j.id, func(b *batchRecord) {
+			b.Errors = &BatchErrors{Object: "list", Data: []BatchError{{Code: "invalid_api_key", Message: "the API key that created this batch is no longer valid"}}}
+		})
+		m.finalize(j, StatusFailed, nil)
+	case !time.Now().Before(expires):
+		var pending []*requestLine
+		for i := range lines {
+			if !j.finished(lines[i].CustomID) {
+				pending = append(pending, &lines[i])
+			}
+		}
+		m.finalize(j, StatusExpired, pending)
+	default:
+		m.finalize(j, StatusCompleted, nil)
+	}
+}
+
+// execute runs one request, waiting out credential cooldowns and retrying transient
+// failures. It reports false when the batch stopped before a result was obtained.
+func (m *Manager) execute(j *job, rec batchRecord, line *requestLine, expires time.Time) (Result, bool) {
+	for attempt := 1; ; {
+		if !j.waitPause() {
+			return Result{}, false
+		}
+		result := m.exec(j.ctx, rec.Owner, rec.Endpoint, line.Body)
+		if result.Revoked {
+			j.cancel(errRevoked)
+		}
+		if j.ctx.Err() != nil {
+			return Result{}, false
+		}
+		if !retryable(result.Status) || !time.Now().Before(expires) {
+			return result, true
+		}
+		if result.RetryAfter > 0 {
+			j.pauseFor(result.RetryAfter)
+			continue
+		}
+		if attempt >= maxAttempts {
+			return result, true
+		}
+		j.pauseFor(time.Duration(1<<attempt) * time.Second)
+		attempt++
+	}
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+// pauseFor holds every worker of the batch until d has passed, so a cooldown observed by
+// one request is respected by all of them.
+func (j *job) pauseFor(d time.Duration) {
+	j.mu.Lock()
+	if until := time.Now().Add(d); until.After(j.pauseTill) {
+		j.pauseTill = until
+	}
+	j.mu.Unlock()
+}
+
+// waitPause blocks while the batch is paused and reports false when it stopped meanwhile.
+func (j *job) waitPause() bool {
+	for {
+		j.mu.Lock()
+		wait := time.Until(j.pauseTill)
+		j.mu.Unlock()
+		if wait <= 0 {
+			return j.ctx.Err() == nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-j.ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// readInput parses and validates the batch input file.
+func (m *Manager) readInput(rec batchRecord) ([]requestLine, []BatchError) {
+	fail := func(code, message string, line int) []BatchError {
+		e := BatchError{Code: code, Message: message}
+		if line > 0 {
+			e.Line = &line
+		}
+		return []BatchError{e}
+	}
+	f, err := os.Open(m.contentPath(rec.InputFileID))
+	if err != nil {
+		return nil, fail("missing_input_file", "input file "+rec.InputFileID+" is not available", 0)
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []requestLine
+	seen := make(map[string]struct{})
+	reader := bufio.NewReader(f)
+	for number := 1; ; number++ {
+		raw, errRead := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 {
+			var line requestLine
+			if errJSON := json.Unmarshal(trimmed, &line); errJSON != nil {
+				return nil, fail("invalid_json_line", "line is not valid JSON", number)
+			}
+			switch {
+			case line.CustomID == "":
+				return nil, fail("missing_required_parameter", "custom_id is required", number)
+			case line.Method != http.MethodPost:
+				return nil, fail("invalid_method", "method must be POST", number)
+			case line.URL != rec.Endpoint:
+				return nil, fail("mismatched_endpoint", "url must match the batch endpoint "+rec.Endpoint, number)
+			case len(line.Body) == 0 || line.Body[0] != '{':
+				return nil, fail("invalid_request", "body must be a JSON object", number)
+			}
+			if _, dup := seen[line.CustomID]; dup {
+				return nil, fail("duplicate_custom_id", "custom_id "+line.CustomID+" is not unique", number)
+			}
+			seen[line.CustomID] = struct{}{}
+			lines = append(lines, line)
+			if len(lines) > MaxRequests {
+				return nil, fail("too_many_requests", fmt.Sprintf("batches are limited to %d requests", MaxRequests), number)
+			}
+		}
+		if errRead != nil {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fail("empty_file", "input file has no requests", 0)
+	}
+	return lines, nil
+}
+
+// openProgress opens the partial output and error files of the batch, dropping a line cut
+// short by a crash, and marks the requests that already have a result.
+func (m *Manager) openProgress(j *job) error {
+	j.completed = make(map[string]bool)
+	var err error
+	if j.output, err = openPartial(m.batchPath(j.id, ".output.partial"), func(customID string) {
+		j.completed[customID] = true
+		j.counts.Completed++
+	}); err != nil {
+		return err
+	}
+	if j.errors, err = openPartial(m.batchPath(j.id, ".errors.partial"), func(customID string) {
+		j.completed[customID] = true
+		j.counts.Failed++
+	}); err != nil {
+		_ = j.output.Close()
+		return err
+	}
+	return nil
+}
+
+func openPartial(path string, seen func(customID string)) (*os.File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var valid bytes.Buffer
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		var entry struct {
+			CustomID string `json:"custom_id"`
+		}
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		seen(entry.CustomID)
+		valid.Write(line)
+		valid.WriteByte('\n')
+	}
+	if len(raw) > 0 && valid.Len() != len(raw) {
+		if err = os.WriteFile(path, valid.Bytes(), 0o600); err != nil {
+			return nil, fmt.Errorf("rewrite %s: %w", path, err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// record appends the result of a request to the output or error file.
+func (m *Manager) record(j *job, line *requestLine, result Result) {
+	body := json.RawMessage(result.Body)
+	if !json.Valid(body) {
+		body, _ = json.Marshal(map[string]any{"error": map[string]any{"message": string(result.Body)}})
+	}
+	requestID := newID("req_")
+	entry, _ := json.Marshal(map[string]any{
+		"id":        newID("batch_req_"),
+		"custom_id": line.CustomID,
+		"response":  map[string]any{"status_code": result.Status, "request_id": requestID, "body": body},
+		"error":     nil,
+	})
+	success := result.Status >= 200 && result.Status < 300
+	j.mu.Lock()
+	j.completed[line.CustomID] = true
+	target := j.errors
+	if success {
+		target = j.output
+		j.counts.Completed++
+	} else {
+		j.counts.Failed++
+	}
+	if _, err := target.Write(append(entry, '\n')); err != nil {
+		log.Errorf("batch %s: write result of %s: %v", j.id, line.CustomID, err)
+	}
+	j.mu.Unlock()
+	m.saveProgress(j, false)
+}
+
+// saveProgress persists the request counts, at most once per saveInterval unless forced.
+func (m *Manager) saveProgress(j *job, force bool) {
+	j.mu.Lock()
+	if !force && time.Since(j.lastSaved) < saveInterval {
+		j.mu.Unlock()
+		return
+	}
+	j.lastSaved = time.Now()
+	counts := j.counts
+	j.mu.Unlock()
+	m.update(j.id, func(b *batchRecord) { b.RequestCounts = counts })
+}
+
+// finalize turns the partial files into output files and records the final status.
+// pending requests of an expired batch are reported as expired in the error file.
+func (m *Manager) finalize(j *job, status string, pending []*requestLine) {
+	m.update(j.id, func(b *batchRecord) {
+		if b.Status != StatusCancelling {
+			now := time.Now().Unix()
+			b.Status, b.FinalizingAt = StatusFinalizing, &now
+		}
+	})
+	if j.errors != nil {
+		for _, line := range pending {
+			entry, _ := json.Marshal(map[string]any{
+				"id":        newID("batch_req_"),
+				"custom_id": line.CustomID,
+				"response":  nil,
+				"error":     map[string]string{"code": "batch_expired", "message": "This request could not be executed before the completion window expired."},
+			})
+			_, _ = j.errors.Write(append(entry, '\n'))
+			j.counts.Failed++
+		}
+	}
+	outputID := m.promotePartial(j.id, ".output.partial")
+	errorID := m.promotePartial(j.id, ".errors.partial")
+	m.update(j.id, func(b *batchRecord) {
+		now := time.Now().Unix()
+		b.Status = status
+		b.OutputFileID, b.ErrorFileID = outputID, errorID
+		if j.counts.Total > 0 {
+			b.RequestCounts = j.counts
+		}
+		switch status {
+		case StatusCompleted:
+			b.CompletedAt = &now
+		case StatusExpired:
+			b.ExpiredAt = &now
+		case StatusCancelled:
+			b.CancelledAt = &now
+		case StatusFailed:
+			b.FailedAt = &now
+		}
+	})
+}
+
+// promotePartial turns a non-empty partial file into a batch_output file owned by the
+// batch owner and returns its ID.
+func (m *Manager) promotePartial(batchID, suffix string) *string {
+	path := m.batchPath(batchID, suffix)
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		_ = os.Remove(path)
+		return nil
+	}
+	id := newID("file-")
+	if err = os.Rename(path, m.contentPath(id)); err != nil {
+		log.Errorf("batch %s: move results: %v", batchID, err)
+		return nil
+	}
+	name := batchID + "_output.jsonl"
+	if suffix == ".errors.partial" {
+		name = batchID + "_error.jsonl"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec := &fileRecord{File: newFile(id, name, PurposeBatchOutput, info.Size()), Owner: m.batches[batchID].Owner}
+	if err = m.saveFileLocked(rec); err != nil {
+		log.Errorf("batch %s: %v", batchID, err)
+		return nil
+	}
+	return &id
+}
+
+// update applies fn to the stored batch and persists it.
+func (m *Manager) update(id string, fn func(*batchRecord)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.batches[id]
+	if !ok {
+		return
+	}
+	next := *rec
+	fn(&next)
+	if next.Status == StatusInProgress && rec.Status == StatusCancelling {
+		next.Status = StatusCancelling
+	}
+	if err := m.saveBatchLocked(&next); err != nil {
+		log.Errorf("batch %s: %v", id, err)
+	}
+}
+
+// limiter bounds the requests in flight across all batches. The limit can change while
+// requests wait.
+type limiter struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	changed  chan struct{}
+}
+
+func newLimiter(limit int) *limiter {
+	return &limiter{limit: limit, changed: make(chan struct{})}
+}
+
+func (l *limiter) setLimit(limit int) {
+	l.mu.Lock()
+	l.limit = limit
+	l.broadcastLocked()
+	l.mu.Unlock()
+}
+
+func (l *limiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.broadcastLocked()
+	l.mu.Unlock()
+}
+
+func (l *limiter) broadcastLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
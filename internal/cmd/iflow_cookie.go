@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/iflow"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypto"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
@@ -54,7 +55,7 @@ func DoIFlowCookieAuth(cfg *config.Config, options *LoginOptions) {
 	authFilePath := getAuthFilePath(cfg, "iflow", tokenData.Email)
 
 	// Save token to file
-	if err := tokenStorage.SaveTokenToFile(authFilePath); err != nil {
+	if err := authcrypto.SaveStorage(tokenStorage, authFilePath); err != nil {
 		fmt.Printf("Failed to save authentication: %v\n", err)
 		return
 	}
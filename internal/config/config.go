@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strings"
 	"syscall"
@@ -23,6 +25,12 @@ type Config struct {
 	// Port is the network port on which the API server will listen.
 	Port int `yaml:"port" json:"-"`
 
+	// TLS serves the API over HTTPS, optionally verifying client certificates.
+	TLS TLSConfig `yaml:"tls,omitempty" json:"-"`
+
+	// CORS controls which browser origins may call the API, management and websocket routes.
+	CORS CORSConfig `yaml:"cors,omitempty" json:"cors,omitempty"`
+
 	// AmpUpstreamURL defines the upstream Amp control plane used for non-provider calls.
 	AmpUpstreamURL string `yaml:"amp-upstream-url" json:"amp-upstream-url"`
 
@@ -64,6 +72,30 @@ type Config struct {
 	// RequestRetry defines the retry times when the request failed.
 	RequestRetry int `yaml:"request-retry" json:"request-retry"`
 
+	// RetryPolicy tunes which failures are retried and how long to back off between attempts.
+	RetryPolicy RetryPolicy `yaml:"retry-policy,omitempty" json:"retry-policy,omitempty"`
+
+	// StreamTTFTTimeoutSeconds fails over to the next credential when a stream produces no data in time (0 disables).
+	StreamTTFTTimeoutSeconds int `yaml:"stream-ttft-timeout-seconds,omitempty" json:"stream-ttft-timeout-seconds,omitempty"`
+
+	// Hedging races slow non-streaming requests against a second credential to cut tail latency.
+	Hedging HedgingConfig `yaml:"hedging,omitempty" json:"hedging,omitempty"`
+
+	// Concurrency limits parallel requests per credential and queues the overflow.
+	Concurrency ConcurrencyConfig `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// ModelFallbacks maps a requested model to alternate models tried in order when it cannot be served.
+	ModelFallbacks map[string][]string `yaml:"model-fallbacks,omitempty" json:"model-fallbacks,omitempty"`
+
+	// Routing selects the credential selection strategy globally and per provider.
+	Routing RoutingConfig `yaml:"routing,omitempty" json:"routing,omitempty"`
+
+	// Guardrails deny or redact prompt content before it is forwarded upstream.
+	Guardrails []GuardrailRule `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+
+	// Batches tunes the emulated OpenAI Batch API behind /v1/files and /v1/batches.
+	Batches BatchConfig `yaml:"batches,omitempty" json:"batches,omitempty"`
+
 	// ClaudeKey defines a list of Claude API key configurations as specified in the YAML configuration file.
 	ClaudeKey []ClaudeKey `yaml:"claude-api-key" json:"claude-api-key"`
 
@@ -78,6 +110,9 @@ type Config struct {
 
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
+
+	// secretRefs maps resolved secrets back to the references they were loaded from.
+	secretRefs map[string]string
 }
 
 // RemoteManagement holds management API configuration under 'remote-management'.
@@ -88,6 +123,55 @@ type RemoteManagement struct {
 	SecretKey string `yaml:"secret-key"`
 	// DisableControlPanel skips serving and syncing the bundled management UI when true.
 	DisableControlPanel bool `yaml:"disable-control-panel"`
+	// Keys lists additional named management keys, each limited to a role.
+	Keys []ManagementKey `yaml:"keys,omitempty"`
+}
+
+// Management roles, from least to most privileged. The legacy secret-key acts as admin.
+const (
+	ManagementRoleViewer   = "viewer"
+	ManagementRoleOperator = "operator"
+	ManagementRoleAdmin    = "admin"
+)
+
+// ManagementKey is a named management key. Keys are revoked individually by disabling or
+// removing them.
+type ManagementKey struct {
+	// Name identifies the key in logs and management responses.
+	Name string `yaml:"name" json:"name"`
+	// Key is the secret (plaintext or bcrypt hashed); plaintext values are hashed on load.
+	Key string `yaml:"key" json:"-"`
+	// Role is one of viewer, operator or admin.
+	Role string `yaml:"role" json:"role"`
+	// Disabled revokes the key without removing it.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled"`
+}
+
+// ManagementRoleRank orders roles by privilege; unknown roles rank below viewer.
+func ManagementRoleRank(role string) int {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case ManagementRoleViewer:
+		return 1
+	case ManagementRoleOperator:
+		return 2
+	case ManagementRoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// HasKeys reports whether any management key is configured, which enables the management API.
+func (r RemoteManagement) HasKeys() bool {
+	if r.SecretKey != "" {
+		return true
+	}
+	for _, key := range r.Keys {
+		if key.Key != "" && !key.Disabled {
+			return true
+		}
+	}
+	return false
 }
 
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
@@ -100,6 +184,272 @@ type QuotaExceeded struct {
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
 }
 
+// RetryPolicy configures how failed requests are retried when request-retry is positive.
+// Zero values fall back to the built-in defaults.
+type RetryPolicy struct {
+	// StatusCodes lists upstream HTTP status codes that trigger a retry (default 403, 408, 500, 502, 503, 504).
+	StatusCodes []int `yaml:"status-codes,omitempty" json:"status-codes,omitempty"`
+
+	// BaseDelayMS is the initial backoff in milliseconds; it doubles on each retry.
+	BaseDelayMS int `yaml:"base-delay-ms,omitempty" json:"base-delay-ms,omitempty"`
+
+	// MaxDelayMS caps a single backoff interval in milliseconds.
+	MaxDelayMS int `yaml:"max-delay-ms,omitempty" json:"max-delay-ms,omitempty"`
+
+	// MaxElapsedSeconds bounds the total time a request may spend across all retries.
+	MaxElapsedSeconds int `yaml:"max-elapsed-seconds,omitempty" json:"max-elapsed-seconds,omitempty"`
+}
+
+// TLSConfig configures the HTTPS listener. Certificate, key and client CA files are reloaded
+// when they change on disk.
+type TLSConfig struct {
+	// Cert is the PEM certificate chain served to clients.
+	Cert string `yaml:"cert,omitempty" json:"cert,omitempty"`
+
+	// Key is the PEM private key of Cert.
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+
+	// ClientCA is a PEM bundle of CAs trusted to sign client certificates. When set, client
+	// certificates are verified and can authenticate requests through mtls-auth.
+	ClientCA string `yaml:"client-ca,omitempty" json:"client-ca,omitempty"`
+
+	// RequireClientCert rejects TLS handshakes without a valid client certificate. When false,
+	// clients may still authenticate with keys instead.
+	RequireClientCert bool `yaml:"require-client-cert,omitempty" json:"require-client-cert,omitempty"`
+}
+
+// Enabled reports whether a certificate is configured.
+func (t TLSConfig) Enabled() bool {
+	return strings.TrimSpace(t.Cert) != "" && strings.TrimSpace(t.Key) != ""
+}
+
+// CORSConfig holds a separate origin policy for each route family. An API policy without allowed
+// origins keeps the permissive default of answering every origin with "*". The management and
+// websocket policies default to same-origin: they send no CORS headers, and the websocket relay
+// only accepts clients without an Origin header and loopback origins.
+type CORSConfig struct {
+	// API applies to the provider-compatible routes such as /v1 and /v1beta.
+	API CORSPolicy `yaml:"api,omitempty" json:"api,omitempty"`
+
+	// Management applies to /v0/management.
+	Management CORSPolicy `yaml:"management,omitempty" json:"management,omitempty"`
+
+	// Websocket restricts the origins allowed to open the websocket relay (/v1/ws). The AI Studio
+	// bridge connects from "https://*.scf.usercontent.goog", which must be listed to use it.
+	Websocket CORSPolicy `yaml:"websocket,omitempty" json:"websocket,omitempty"`
+}
+
+// CORSPolicy describes the cross-origin requests a route family accepts.
+type CORSPolicy struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com"), wildcard subdomains
+	// ("https://*.example.com") or "*". Empty allows every origin.
+	AllowedOrigins []string `yaml:"allowed-origins,omitempty" json:"allowed-origins,omitempty"`
+
+	// AllowedMethods defaults to GET, POST, PUT, PATCH, DELETE and OPTIONS.
+	AllowedMethods []string `yaml:"allowed-methods,omitempty" json:"allowed-methods,omitempty"`
+
+	// AllowedHeaders defaults to "*".
+	AllowedHeaders []string `yaml:"allowed-headers,omitempty" json:"allowed-headers,omitempty"`
+
+	// AllowCredentials lets browsers send cookies and client certificates. It only takes effect
+	// with an explicit origin list, since credentials are never allowed for "*".
+	AllowCredentials bool `yaml:"allow-credentials,omitempty" json:"allow-credentials,omitempty"`
+
+	// MaxAgeSeconds lets browsers cache preflight results (0 leaves the browser default).
+	MaxAgeSeconds int `yaml:"max-age-seconds,omitempty" json:"max-age-seconds,omitempty"`
+}
+
+// Restricted reports whether the policy limits origins instead of allowing every one.
+func (p CORSPolicy) Restricted() bool {
+	for _, origin := range p.AllowedOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return false
+		}
+	}
+	return len(p.AllowedOrigins) > 0
+}
+
+// AllowsRequest reports whether a request with the given Origin header may be served.
+// Requests without an Origin header (non-browser clients) and from loopback origins, such as
+// the bundled control panel opened on localhost, are always allowed. The Host header is not
+// consulted: a DNS rebinding page controls it and would match its own origin, so the public
+// origin the panel is served from has to be listed like any other.
+func (p CORSPolicy) AllowsRequest(origin string) bool {
+	origin = strings.TrimSpace(origin)
+	if origin == "" || isLoopbackOrigin(origin) {
+		return true
+	}
+	return p.AllowsOrigin(origin)
+}
+
+// AllowsWebsocket reports whether a websocket upgrade from origin may proceed. Browsers do not
+// apply CORS to websocket upgrades, so a policy without allowed origins does not mean every
+// origin here: only clients without an Origin header and loopback origins are accepted.
+func (p CORSPolicy) AllowsWebsocket(origin string) bool {
+	if len(p.AllowedOrigins) == 0 {
+		origin = strings.TrimSpace(origin)
+		return origin == "" || isLoopbackOrigin(origin)
+	}
+	return p.AllowsRequest(origin)
+}
+
+func isLoopbackOrigin(origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	host := parsed.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// AllowsOrigin reports whether origin matches the policy. Matching ignores case and a
+// trailing slash; "https://*.example.com" matches any subdomain but not the apex domain.
+func (p CORSPolicy) AllowsOrigin(origin string) bool {
+	if !p.Restricted() {
+		return true
+	}
+	origin = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range p.AllowedOrigins {
+		allowed = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(allowed)), "/")
+		if allowed == origin {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+		prefix := scheme + "://"
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, "."+host) && len(origin) > len(prefix)+len(host)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Guardrail actions.
+const (
+	GuardrailActionDeny   = "deny"
+	GuardrailActionRedact = "redact"
+)
+
+// GuardrailRule matches prompt text by regular expression, keyword or built-in detector and
+// either rejects the request or masks the matches. Rules apply to every client key and model
+// unless scoped.
+type GuardrailRule struct {
+	// Name identifies the rule in logs and error messages.
+	Name string `yaml:"name" json:"name"`
+
+	// Action is "deny" or "redact".
+	Action string `yaml:"action" json:"action"`
+
+	// Patterns are regular expressions (Go RE2 syntax).
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Keywords match case-insensitively as plain substrings.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+
+	// Detectors names built-in patterns: email, api-key, card-number, ssn, ipv4.
+	Detectors []string `yaml:"detectors,omitempty" json:"detectors,omitempty"`
+
+	// Replacement masks redacted matches (default "[REDACTED]").
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+
+	// Message is returned to the client when a deny rule matches.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// APIKeys limits the rule to these client keys.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"-"`
+
+	// Models limits the rule to models matching these wildcard patterns.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// BatchConfig configures the batches the proxy runs itself for the OpenAI Batch API.
+type BatchConfig struct {
+	// Dir holds uploaded files and batch state; defaults to "batches" beside the logs directory.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MaxConcurrency bounds the batch requests in flight across all batches (default 4).
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+
+	// MaxFileSizeMB bounds uploaded batch input files (default 200).
+	MaxFileSizeMB int `yaml:"max-file-size-mb,omitempty" json:"max-file-size-mb,omitempty"`
+}
+
+// HedgingConfig configures hedged non-streaming requests. Once the primary attempt has run
+// longer than the model's latency percentile, a second attempt is sent to another credential
+// or provider; the first success wins and the other attempt is canceled.
+type HedgingConfig struct {
+	// Enabled turns hedging on.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Percentile of recent successful latencies after which a hedge is sent (default 95).
+	Percentile float64 `yaml:"percentile,omitempty" json:"percentile,omitempty"`
+
+	// MinSamples is the number of latencies a model needs before it is hedged (default 20).
+	MinSamples int `yaml:"min-samples,omitempty" json:"min-samples,omitempty"`
+
+	// MinDelayMS is the lower bound for the hedge delay in milliseconds.
+	MinDelayMS int `yaml:"min-delay-ms,omitempty" json:"min-delay-ms,omitempty"`
+
+	// MaxRatio caps hedges as a fraction of requests, bounding extra quota burn (default 0.05).
+	MaxRatio float64 `yaml:"max-ratio,omitempty" json:"max-ratio,omitempty"`
+}
+
+// ConcurrencyConfig bounds parallel requests per credential. Individual keys may set their own
+// max-concurrency; otherwise the provider default and then DefaultMax apply (0 is unlimited).
+// Requests that find every credential busy wait in a FIFO queue per provider.
+type ConcurrencyConfig struct {
+	// DefaultMax is the limit for credentials without a key or provider specific one.
+	DefaultMax int `yaml:"default-max,omitempty" json:"default-max,omitempty"`
+
+	// Providers sets the default limit per provider (e.g. claude: 2, codex: 1).
+	Providers map[string]int `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// QueueSize bounds the waiting requests per provider (default 100).
+	QueueSize int `yaml:"queue-size,omitempty" json:"queue-size,omitempty"`
+
+	// QueueTimeoutSeconds is the longest a request waits for a free credential (default 30).
+	QueueTimeoutSeconds int `yaml:"queue-timeout-seconds,omitempty" json:"queue-timeout-seconds,omitempty"`
+}
+
+// RoutingConfig chooses how a credential is picked among those able to serve a request.
+// Supported strategies: round-robin (default), weighted, priority, least-in-flight, fill-first, power-of-two.
+type RoutingConfig struct {
+	// Strategy is the default selection strategy for every provider.
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// Providers overrides the strategy for individual providers, keyed by provider name (e.g. gemini-cli).
+	Providers map[string]string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// SessionAffinity pins a conversation to one credential so upstream prompt caches are reused.
+	SessionAffinity SessionAffinityConfig `yaml:"session-affinity,omitempty" json:"session-affinity,omitempty"`
+}
+
+// SessionAffinityConfig configures sticky credential selection. The affinity key comes from the
+// configured header, Claude metadata.user_id, OpenAI prompt_cache_key/user, or a hash of the
+// system prompt plus the first message.
+type SessionAffinityConfig struct {
+	// Enabled turns sticky selection on.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Header optionally names a request header carrying an explicit session identifier.
+	Header string `yaml:"header,omitempty" json:"header,omitempty"`
+
+	// TTLSeconds is how long an idle session stays pinned (default 3600).
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries bounds the number of pinned sessions (default 10000).
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
 // PayloadConfig defines default and override parameter rules applied to provider payloads.
 type PayloadConfig struct {
 	// Default defines rules that only set parameters when they are missing in the payload.
@@ -142,6 +492,15 @@ type ClaudeKey struct {
 
 	// Headers optionally adds extra HTTP headers for requests sent with this key.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Weight is the relative traffic share of this key under the weighted routing strategy.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// Priority orders keys under the priority routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// MaxConcurrency caps parallel requests on this key, overriding the provider default.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
 }
 
 // ClaudeModel describes a mapping between an alias and the actual upstream model name.
@@ -168,6 +527,15 @@ type CodexKey struct {
 
 	// Headers optionally adds extra HTTP headers for requests sent with this key.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Weight is the relative traffic share of this key under the weighted routing strategy.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// Priority orders keys under the priority routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// MaxConcurrency caps parallel requests on this key, overriding the provider default.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
 }
 
 // GeminiKey represents the configuration for a Gemini API key,
@@ -184,6 +552,15 @@ type GeminiKey struct {
 
 	// Headers optionally adds extra HTTP headers for requests sent with this key.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Weight is the relative traffic share of this key under the weighted routing strategy.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// Priority orders keys under the priority routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// MaxConcurrency caps parallel requests on this key, overriding the provider default.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
 }
 
 // OpenAICompatibility represents the configuration for OpenAI API compatibility
@@ -216,6 +593,15 @@ type OpenAICompatibilityAPIKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Weight is the relative traffic share of this key under the weighted routing strategy.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// Priority orders keys under the priority routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// MaxConcurrency caps parallel requests on this key, overriding the provider default.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
 }
 
 // OpenAICompatibilityModel represents a model configuration for OpenAI compatibility,
@@ -226,11 +612,14 @@ type OpenAICompatibilityModel struct {
 
 	// Alias is the model name alias that clients will use to reference this model.
 	Alias string `yaml:"alias" json:"alias"`
+
+	// Kind is "embedding" for models served through /v1/embeddings; empty means a chat model.
+	Kind string `yaml:"kind,omitempty" json:"kind,omitempty"`
 }
 
 // LoadConfig reads a YAML configuration file from the given path,
-// unmarshals it into a Config struct, applies environment variable overrides,
-// and returns it.
+// unmarshals it into a Config struct, resolves env:, file: and exec: secret
+// references, and returns it.
 //
 // Parameters:
 //   - configFile: The path to the YAML configuration file
@@ -242,7 +631,8 @@ func LoadConfig(configFile string) (*Config, error) {
 	return LoadConfigOptional(configFile, false)
 }
 
-// LoadConfigOptional reads YAML from configFile.
+// LoadConfigOptional reads YAML from configFile and resolves secret references such as
+// env:NAME, file:/path or exec:command in API key fields.
 // If optional is true and the file is missing, it returns an empty Config.
 // If optional is true and the file is empty or invalid, it returns an empty Config.
 func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
@@ -292,6 +682,34 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
 	}
 
+	// Hash named management keys the same way and persist them so plaintext does not linger.
+	hashedManagementKeys := false
+	for i := range cfg.RemoteManagement.Keys {
+		key := &cfg.RemoteManagement.Keys[i]
+		key.Name = strings.TrimSpace(key.Name)
+		key.Role = strings.ToLower(strings.TrimSpace(key.Role))
+		if key.Role == "" {
+			key.Role = ManagementRoleViewer
+		}
+		if key.Key == "" || looksLikeBcrypt(key.Key) {
+			continue
+		}
+		hashed, errHash := hashSecret(key.Key)
+		if errHash != nil {
+			return nil, fmt.Errorf("failed to hash management key %q: %w", key.Name, errHash)
+		}
+		key.Key = hashed
+		hashedManagementKeys = true
+	}
+	if hashedManagementKeys {
+		_ = SaveConfigPreserveComments(configFile, &cfg)
+	}
+
+	// Resolve env:, file: and exec: secret references before keys are normalized.
+	if err = cfg.ResolveSecretRefs(); err != nil {
+		return nil, err
+	}
+
 	// Sync request authentication providers with inline API keys for backwards compatibility.
 	syncInlineAccessProvider(&cfg)
 
@@ -522,10 +940,9 @@ func sanitizeConfigForPersist(cfg *Config) *Config {
 	if cfg == nil {
 		return nil
 	}
-	clone := *cfg
-	clone.SDKConfig = cfg.SDKConfig
+	clone := cfg.WithSecretRefs()
 	clone.SDKConfig.Access = config.AccessConfig{}
-	return &clone
+	return clone
 }
 
 // SaveConfigPreserveCommentsUpdateNestedScalar updates a nested scalar key path like ["a","b"]
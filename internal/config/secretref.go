@@ -0,0 +1,241 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// Secret reference prefixes accepted wherever the config holds an API key, so the key itself
+// never has to be written into config.yaml.
+const (
+	SecretRefEnv  = "env:"
+	SecretRefFile = "file:"
+	SecretRefExec = "exec:"
+)
+
+// secretRefExecTimeout bounds how long an exec: reference may run.
+const secretRefExecTimeout = 10 * time.Second
+
+// ErrSecretRefNotAllowed reports a secret reference that did not come from the config file on
+// disk. References are resolved only when the operator's config file is loaded, so callers of
+// the management API cannot make the proxy read files, environment variables or run commands.
+var ErrSecretRefNotAllowed = errors.New("secret references can only be set in the config file")
+
+// IsSecretRef reports whether value is a secret reference rather than a literal secret.
+func IsSecretRef(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, SecretRefEnv) || strings.HasPrefix(value, SecretRefFile) || strings.HasPrefix(value, SecretRefExec)
+}
+
+// ResolveSecretRef returns the secret named by ref:
+//   - env:NAME reads the environment variable NAME
+//   - file:/path reads the file, without its trailing newline
+//   - exec:command runs the command through the shell and uses its trimmed output
+func ResolveSecretRef(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	var (
+		value string
+		err   error
+	)
+	switch {
+	case strings.HasPrefix(ref, SecretRefEnv):
+		name := strings.TrimSpace(strings.TrimPrefix(ref, SecretRefEnv))
+		var ok bool
+		if value, ok = os.LookupEnv(name); !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+	case strings.HasPrefix(ref, SecretRefFile):
+		var data []byte
+		if data, err = os.ReadFile(strings.TrimSpace(strings.TrimPrefix(ref, SecretRefFile))); err != nil {
+			return "", err
+		}
+		value = string(data)
+	case strings.HasPrefix(ref, SecretRefExec):
+		if value, err = runSecretCommand(strings.TrimSpace(strings.TrimPrefix(ref, SecretRefExec))); err != nil {
+			return "", err
+		}
+	default:
+		return ref, nil
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("secret is empty")
+	}
+	return value, nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	if command == "" {
+		return "", fmt.Errorf("command is empty")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretRefExecTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// secretFields returns every config value that may hold a secret or a reference to one.
+func (cfg *Config) secretFields() []*string {
+	fields := []*string{&cfg.AmpUpstreamAPIKey}
+	for i := range cfg.APIKeys {
+		fields = append(fields, &cfg.APIKeys[i])
+	}
+	for i := range cfg.APIKeyPolicies {
+		fields = append(fields, &cfg.APIKeyPolicies[i].APIKey)
+	}
+	for i := range cfg.GlAPIKey {
+		fields = append(fields, &cfg.GlAPIKey[i])
+	}
+	for i := range cfg.GeminiKey {
+		fields = append(fields, &cfg.GeminiKey[i].APIKey)
+	}
+	for i := range cfg.ClaudeKey {
+		fields = append(fields, &cfg.ClaudeKey[i].APIKey)
+	}
+	for i := range cfg.CodexKey {
+		fields = append(fields, &cfg.CodexKey[i].APIKey)
+	}
+	for i := range cfg.OpenAICompatibility {
+		for j := range cfg.OpenAICompatibility[i].APIKeyEntries {
+			fields = append(fields, &cfg.OpenAICompatibility[i].APIKeyEntries[j].APIKey)
+		}
+	}
+	for i := range cfg.Guardrails {
+		for j := range cfg.Guardrails[i].APIKeys {
+			fields = append(fields, &cfg.Guardrails[i].APIKeys[j])
+		}
+	}
+	return fields
+}
+
+// ResolveSecretRefs replaces secret references with their values and remembers each reference,
+// so persisted and served copies of the config show the reference instead of the secret.
+// Values resolved earlier stay mapped to their reference.
+func (cfg *Config) ResolveSecretRefs() error {
+	if cfg == nil {
+		return nil
+	}
+	refs := make(map[string]string, len(cfg.secretRefs))
+	for value, ref := range cfg.secretRefs {
+		refs[value] = ref
+	}
+	for _, field := range cfg.secretFields() {
+		ref := strings.TrimSpace(*field)
+		if !IsSecretRef(ref) {
+			continue
+		}
+		value, err := ResolveSecretRef(ref)
+		if err != nil {
+			return fmt.Errorf("resolve secret %s: %w", ref, err)
+		}
+		*field = value
+		refs[value] = ref
+	}
+	cfg.secretRefs = refs
+	return nil
+}
+
+// CheckSecretRefs fails with ErrSecretRefNotAllowed when cfg holds a secret reference that
+// known was not loaded with. Known references may be sent back unchanged, as management
+// clients are shown them in place of the secrets.
+func (cfg *Config) CheckSecretRefs(known *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	var unknown []string
+	for _, field := range cfg.secretFields() {
+		ref := strings.TrimSpace(*field)
+		if IsSecretRef(ref) && known.ResolveSecretValue(ref) == ref {
+			unknown = append(unknown, ref)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrSecretRefNotAllowed, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// AdoptSecretRefs replaces references this config was loaded with by their values, so entries
+// sent back through the management API keep their secret and are persisted as references.
+// Any other reference fails with ErrSecretRefNotAllowed and leaves the config unchanged.
+func (cfg *Config) AdoptSecretRefs() error {
+	if err := cfg.CheckSecretRefs(cfg); err != nil || cfg == nil {
+		return err
+	}
+	for _, field := range cfg.secretFields() {
+		if IsSecretRef(*field) {
+			*field = cfg.ResolveSecretValue(*field)
+		}
+	}
+	return nil
+}
+
+// ResolveSecretValue maps a reference that was resolved for this config to its value, so
+// management clients can address entries by the reference they were shown.
+func (cfg *Config) ResolveSecretValue(value string) string {
+	if cfg == nil || !IsSecretRef(value) {
+		return value
+	}
+	value = strings.TrimSpace(value)
+	for resolved, ref := range cfg.secretRefs {
+		if ref == value {
+			return resolved
+		}
+	}
+	return value
+}
+
+// WithSecretRefs returns a copy of the config in which resolved secrets are replaced by the
+// references they came from. The copy shares everything except the secret-bearing slices.
+func (cfg *Config) WithSecretRefs() *Config {
+	if cfg == nil {
+		return nil
+	}
+	clone := *cfg
+	if len(cfg.secretRefs) == 0 {
+		return &clone
+	}
+	clone.APIKeys = append([]string(nil), cfg.APIKeys...)
+	clone.APIKeyPolicies = append([]config.APIKeyPolicy(nil), cfg.APIKeyPolicies...)
+	clone.GlAPIKey = append([]string(nil), cfg.GlAPIKey...)
+	clone.GeminiKey = append([]GeminiKey(nil), cfg.GeminiKey...)
+	clone.ClaudeKey = append([]ClaudeKey(nil), cfg.ClaudeKey...)
+	clone.CodexKey = append([]CodexKey(nil), cfg.CodexKey...)
+	clone.OpenAICompatibility = append([]OpenAICompatibility(nil), cfg.OpenAICompatibility...)
+	for i := range clone.OpenAICompatibility {
+		clone.OpenAICompatibility[i].APIKeyEntries = append([]OpenAICompatibilityAPIKey(nil), cfg.OpenAICompatibility[i].APIKeyEntries...)
+	}
+	clone.Guardrails = append([]GuardrailRule(nil), cfg.Guardrails...)
+	for i := range clone.Guardrails {
+		clone.Guardrails[i].APIKeys = append([]string(nil), cfg.Guardrails[i].APIKeys...)
+	}
+	for _, field := range clone.secretFields() {
+		if ref, ok := cfg.secretRefs[*field]; ok {
+			*field = ref
+		}
+	}
+	return &clone
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretRefsResolveAndPersistAsRefs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLIPROXY_TEST_CLAUDE_KEY", "sk-from-env")
+	keyFile := filepath.Join(dir, "gemini")
+	if err := os.WriteFile(keyFile, []byte("AIza-from-file\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	configFile := filepath.Join(dir, "config.yaml")
+	content := "api-keys:\n  - \"plain-key\"\n" +
+		"claude-api-key:\n  - api-key: \"env:CLIPROXY_TEST_CLAUDE_KEY\"\n" +
+		"gemini-api-key:\n  - api-key: \"file:" + keyFile + "\"\n"
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if got := cfg.ClaudeKey[0].APIKey; got != "sk-from-env" {
+		t.Fatalf("expected the env reference to resolve, got %q", got)
+	}
+	if got := cfg.GeminiKey[0].APIKey; got != "AIza-from-file" {
+		t.Fatalf("expected the file reference to resolve, got %q", got)
+	}
+	if got := cfg.ResolveSecretValue("env:CLIPROXY_TEST_CLAUDE_KEY"); got != "sk-from-env" {
+		t.Fatalf("expected the reference to map back to its value, got %q", got)
+	}
+	view := cfg.WithSecretRefs()
+	if view.ClaudeKey[0].APIKey != "env:CLIPROXY_TEST_CLAUDE_KEY" || cfg.ClaudeKey[0].APIKey != "sk-from-env" {
+		t.Fatal("expected the view to show the reference without touching the runtime config")
+	}
+
+	if err = SaveConfigPreserveComments(configFile, cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	saved, _ := os.ReadFile(configFile)
+	if strings.Contains(string(saved), "sk-from-env") || strings.Contains(string(saved), "AIza-from-file") {
+		t.Fatalf("expected resolved secrets not to be written, got:\n%s", saved)
+	}
+	if !strings.Contains(string(saved), "env:CLIPROXY_TEST_CLAUDE_KEY") {
+		t.Fatalf("expected the reference to be kept, got:\n%s", saved)
+	}
+
+	t.Setenv("CLIPROXY_TEST_CLAUDE_KEY", "")
+	if err = os.WriteFile(configFile, []byte("claude-api-key:\n  - api-key: \"env:CLIPROXY_TEST_MISSING\"\n"), 0o600); err != nil {
+		t.Fatalf("rewrite config: %v", err)
+	}
+	if _, err = LoadConfig(configFile); err == nil {
+		t.Fatal("expected an unresolvable reference to fail the load")
+	}
+}
+
+func TestSecretRefsFromManagementAreRefused(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLIPROXY_TEST_CODEX_KEY", "sk-from-env")
+	configFile := filepath.Join(dir, "config.yaml")
+	content := "codex-api-key:\n  - api-key: \"env:CLIPROXY_TEST_CODEX_KEY\"\n    base-url: \"https://example.com\"\n"
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	// A reference the client was shown may be sent back and keeps its secret.
+	cfg.CodexKey[0].APIKey = "env:CLIPROXY_TEST_CODEX_KEY"
+	if err = cfg.AdoptSecretRefs(); err != nil {
+		t.Fatalf("adopt known reference: %v", err)
+	}
+	if got := cfg.CodexKey[0].APIKey; got != "sk-from-env" {
+		t.Fatalf("expected the known reference to map to its value, got %q", got)
+	}
+
+	marker := filepath.Join(dir, "marker")
+	for _, ref := range []string{"exec:touch " + marker, "file:" + configFile, "env:HOME"} {
+		cfg.APIKeys = []string{ref}
+		if err = cfg.AdoptSecretRefs(); !errors.Is(err, ErrSecretRefNotAllowed) {
+			t.Fatalf("%s: expected ErrSecretRefNotAllowed, got %v", ref, err)
+		}
+		if cfg.APIKeys[0] != ref {
+			t.Fatalf("%s: expected the rejected reference to stay unresolved, got %q", ref, cfg.APIKeys[0])
+		}
+	}
+	if _, err = os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("expected the exec reference not to run")
+	}
+
+	uploaded := &Config{GlAPIKey: []string{"exec:id"}}
+	if err = uploaded.CheckSecretRefs(cfg); !errors.Is(err, ErrSecretRefNotAllowed) {
+		t.Fatalf("expected an uploaded config with a new reference to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Package embeddings converts embedding requests and responses between the OpenAI and
+// Gemini client formats and the Gemini, Vertex AI and OpenAI-compatible embedding APIs.
+package embeddings
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Encoding formats accepted in OpenAI embedding requests.
+const (
+	EncodingFloat  = "float"
+	EncodingBase64 = "base64"
+)
+
+// Request is the provider-neutral form of an embedding request.
+type Request struct {
+	// Inputs are the texts to embed, in order.
+	Inputs []string
+	// Dimensions truncates the output vectors when positive.
+	Dimensions int
+	// EncodingFormat is "float" (default) or "base64".
+	EncodingFormat string
+	// TaskType is the optional Gemini task type, such as RETRIEVAL_QUERY.
+	TaskType string
+	// Batch records that a Gemini client called batchEmbedContents rather than embedContent,
+	// so the response keeps the shape the client expects.
+	Batch bool
+}
+
+// ParseOpenAI reads an OpenAI /v1/embeddings request. Token array inputs cannot be sent to
+// Gemini and are rejected.
+func ParseOpenAI(raw []byte) (Request, error) {
+	if !gjson.ValidBytes(raw) {
+		return Request{}, fmt.Errorf("invalid JSON body")
+	}
+	root := gjson.ParseBytes(raw)
+	req := Request{
+		Dimensions:     int(root.Get("dimensions").Int()),
+		EncodingFormat: strings.ToLower(strings.TrimSpace(root.Get("encoding_format").String())),
+		TaskType:       strings.TrimSpace(root.Get("task_type").String()),
+	}
+	switch req.EncodingFormat {
+	case "":
+		req.EncodingFormat = EncodingFloat
+	case EncodingFloat, EncodingBase64:
+	default:
+		return Request{}, fmt.Errorf("unsupported encoding_format %q", req.EncodingFormat)
+	}
+	input := root.Get("input")
+	switch {
+	case input.Type == gjson.String:
+		req.Inputs = []string{input.String()}
+	case input.IsArray():
+		for _, item := range input.Array() {
+			if item.Type != gjson.String {
+				return Request{}, fmt.Errorf("input must be a string or an array of strings")
+			}
+			req.Inputs = append(req.Inputs, item.String())
+		}
+	}
+	if len(req.Inputs) == 0 {
+		return Request{}, fmt.Errorf("input is required")
+	}
+	return req, nil
+}
+
+// ParseGemini reads a Gemini embedContent or batchEmbedContents request. The parts of each
+// content are joined into one input, and the task type and dimensionality of the first
+// item that sets them apply to the whole request.
+func ParseGemini(raw []byte) (Request, error) {
+	if !gjson.ValidBytes(raw) {
+		return Request{}, fmt.Errorf("invalid JSON body")
+	}
+	root := gjson.ParseBytes(raw)
+	req := Request{EncodingFormat: EncodingFloat}
+	items := []gjson.Result{root}
+	if requests := root.Get("requests"); requests.Exists() {
+		if !requests.IsArray() {
+			return Request{}, fmt.Errorf("requests must be an array")
+		}
+		items = requests.Array()
+		req.Batch = true
+	}
+	for _, item := range items {
+		parts := item.Get("content.parts")
+		if !parts.IsArray() {
+			return Request{}, fmt.Errorf("content.parts is required")
+		}
+		texts := make([]string, 0, len(parts.Array()))
+		for _, part := range parts.Array() {
+			if text := part.Get("text"); text.Exists() {
+				texts = append(texts, text.String())
+			}
+		}
+		req.Inputs = append(req.Inputs, strings.Join(texts, "\n"))
+		if req.TaskType == "" {
+			req.TaskType = strings.TrimSpace(item.Get("taskType").String())
+		}
+		if req.Dimensions == 0 {
+			req.Dimensions = int(item.Get("outputDimensionality").Int())
+		}
+	}
+	if len(req.Inputs) == 0 {
+		return Request{}, fmt.Errorf("requests must not be empty")
+	}
+	return req, nil
+}
+
+// WithGeminiModel addresses every item of a Gemini batchEmbedContents request to model, so a
+// native request can be forwarded after alias resolution. Other payloads are returned as is.
+func WithGeminiModel(raw []byte, model string) []byte {
+	count := int(gjson.GetBytes(raw, "requests.#").Int())
+	for i := 0; i < count; i++ {
+		if updated, err := sjson.SetBytes(raw, fmt.Sprintf("requests.%d.model", i), "models/"+model); err == nil {
+			raw = updated
+		}
+	}
+	return raw
+}
+
+// OpenAI builds an OpenAI /v1/embeddings request body for model.
+func (r Request) OpenAI(model string) []byte {
+	body := map[string]any{"model": model, "input": r.Inputs, "encoding_format": EncodingFloat}
+	if r.Dimensions > 0 {
+		body["dimensions"] = r.Dimensions
+	}
+	out, _ := json.Marshal(body)
+	return out
+}
+
+// GeminiBatch builds a batchEmbedContents request body for model.
+func (r Request) GeminiBatch(model string) []byte {
+	type part struct {
+		Text string `json:"text"`
+	}
+	type content struct {
+		Parts []part `json:"parts"`
+	}
+	type item struct {
+		Model                string  `json:"model"`
+		Content              content `json:"content"`
+		TaskType             string  `json:"taskType,omitempty"`
+		OutputDimensionality int     `json:"outputDimensionality,omitempty"`
+	}
+	body := struct {
+		Requests []item `json:"requests"`
+	}{Requests: make([]item, 0, len(r.Inputs))}
+	for _, text := range r.Inputs {
+		body.Requests = append(body.Requests, item{
+			Model:                "models/" + model,
+			Content:              content{Parts: []part{{Text: text}}},
+			TaskType:             r.TaskType,
+			OutputDimensionality: r.Dimensions,
+		})
+	}
+	out, _ := json.Marshal(body)
+	return out
+}
+
+// VertexPredict builds a Vertex AI predict request body embedding inputs.
+func (r Request) VertexPredict(inputs []string) []byte {
+	type instance struct {
+		Content  string `json:"content"`
+		TaskType string `json:"task_type,omitempty"`
+	}
+	body := struct {
+		Instances  []instance     `json:"instances"`
+		Parameters map[string]any `json:"parameters,omitempty"`
+	}{Instances: make([]instance, 0, len(inputs))}
+	for _, text := range inputs {
+		body.Instances = append(body.Instances, instance{Content: text, TaskType: r.TaskType})
+	}
+	if r.Dimensions > 0 {
+		body.Parameters = map[string]any{"outputDimensionality": r.Dimensions}
+	}
+	out, _ := json.Marshal(body)
+	return out
+}
+
+// ParseGeminiBatch returns the vectors of a batchEmbedContents response.
+func ParseGeminiBatch(raw []byte) ([][]float64, error) {
+	embeddings := gjson.GetBytes(raw, "embeddings")
+	if !embeddings.IsArray() {
+		return nil, fmt.Errorf("upstream response has no embeddings")
+	}
+	vectors := make([][]float64, 0, len(embeddings.Array()))
+	for _, embedding := range embeddings.Array() {
+		vectors = append(vectors, floats(embedding.Get("values")))
+	}
+	return vectors, nil
+}
+
+// ParseVertexPredict returns the vectors of a Vertex AI predict response and the input
+// tokens it reports.
+func ParseVertexPredict(raw []byte) ([][]float64, int64, error) {
+	predictions := gjson.GetBytes(raw, "predictions")
+	if !predictions.IsArray() {
+		return nil, 0, fmt.Errorf("upstream response has no predictions")
+	}
+	var tokens int64
+	vectors := make([][]float64, 0, len(predictions.Array()))
+	for _, prediction := range predictions.Array() {
+		vectors = append(vectors, floats(prediction.Get("embeddings.values")))
+		tokens += prediction.Get("embeddings.statistics.token_count").Int()
+	}
+	return vectors, tokens, nil
+}
+
+// ParseOpenAIResponse returns the vectors of an OpenAI embeddings response, ordered by index,
+// and the prompt tokens it reports. Only float encoded vectors are understood.
+func ParseOpenAIResponse(raw []byte) ([][]float64, int64, error) {
+	data := gjson.GetBytes(raw, "data")
+	if !data.IsArray() {
+		return nil, 0, fmt.Errorf("upstream response has no data")
+	}
+	items := data.Array()
+	vectors := make([][]float64, len(items))
+	for i, item := range items {
+		index := i
+		if idx := item.Get("index"); idx.Exists() && int(idx.Int()) >= 0 && int(idx.Int()) < len(items) {
+			index = int(idx.Int())
+		}
+		vectors[index] = floats(item.Get("embedding"))
+	}
+	return vectors, gjson.GetBytes(raw, "usage.prompt_tokens").Int(), nil
+}
+
+func floats(values gjson.Result) []float64 {
+	items := values.Array()
+	out := make([]float64, len(items))
+	for i, v := range items {
+		out[i] = v.Float()
+	}
+	return out
+}
+
+// OpenAIResponse renders vectors as an OpenAI embeddings response.
+func OpenAIResponse(model string, vectors [][]float64, encodingFormat string, promptTokens int64) []byte {
+	type datum struct {
+		Object    string `json:"object"`
+		Index     int    `json:"index"`
+		Embedding any    `json:"embedding"`
+	}
+	data := make([]datum, 0, len(vectors))
+	for i, vector := range vectors {
+		var embedding any = vector
+		if encodingFormat == EncodingBase64 {
+			embedding = encodeBase64(vector)
+		}
+		data = append(data, datum{Object: "embedding", Index: i, Embedding: embedding})
+	}
+	out, _ := json.Marshal(map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  model,
+		"usage":  map[string]int64{"prompt_tokens": promptTokens, "total_tokens": promptTokens},
+	})
+	return out
+}
+
+// GeminiResponse renders vectors as the response of the Gemini method the request came from.
+func (r Request) GeminiResponse(vectors [][]float64) []byte {
+	type embedding struct {
+		Values []float64 `json:"values"`
+	}
+	if !r.Batch {
+		var values []float64
+		if len(vectors) > 0 {
+			values = vectors[0]
+		}
+		out, _ := json.Marshal(map[string]any{"embedding": embedding{Values: values}})
+		return out
+	}
+	items := make([]embedding, 0, len(vectors))
+	for _, vector := range vectors {
+		items = append(items, embedding{Values: vector})
+	}
+	out, _ := json.Marshal(map[string]any{"embeddings": items})
+	return out
+}
+
+// encodeBase64 packs a vector as little-endian float32 values, as OpenAI does.
+func encodeBase64(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,88 @@
+package embeddings
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIToGeminiRoundTrip(t *testing.T) {
+	req, err := ParseOpenAI([]byte(`{"model":"gemini-embedding-001","input":["alpha","beta"],"dimensions":256}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	body := req.GeminiBatch("gemini-embedding-001")
+	if got := gjson.GetBytes(body, "requests.#").Int(); got != 2 {
+		t.Fatalf("expected one request per input, got %d", got)
+	}
+	if gjson.GetBytes(body, "requests.1.content.parts.0.text").String() != "beta" ||
+		gjson.GetBytes(body, "requests.0.model").String() != "models/gemini-embedding-001" ||
+		gjson.GetBytes(body, "requests.0.outputDimensionality").Int() != 256 {
+		t.Fatalf("unexpected batch body %s", body)
+	}
+
+	vectors, err := ParseGeminiBatch([]byte(`{"embeddings":[{"values":[0.5,-1]},{"values":[0.25,2]}]}`))
+	if err != nil {
+		t.Fatalf("parse response: %v", err)
+	}
+	out := OpenAIResponse("gemini-embedding-001", vectors, EncodingFloat, 3)
+	if gjson.GetBytes(out, "data.1.index").Int() != 1 || gjson.GetBytes(out, "data.1.embedding.1").Float() != 2 {
+		t.Fatalf("unexpected response %s", out)
+	}
+	if gjson.GetBytes(out, "usage.prompt_tokens").Int() != 3 {
+		t.Fatalf("expected usage to be reported, got %s", out)
+	}
+
+	encoded := gjson.GetBytes(OpenAIResponse("m", vectors, EncodingBase64, 0), "data.0.embedding").String()
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 8 {
+		t.Fatalf("expected two packed float32 values, got %q: %v", encoded, err)
+	}
+	if math.Float32frombits(binary.LittleEndian.Uint32(raw[4:])) != -1 {
+		t.Fatal("expected little-endian float32 encoding")
+	}
+
+	if _, err = ParseOpenAI([]byte(`{"model":"m","input":[[1,2,3]]}`)); err == nil {
+		t.Fatal("expected token array inputs to be rejected")
+	}
+}
+
+func TestGeminiRequestShapes(t *testing.T) {
+	single, err := ParseGemini([]byte(`{"content":{"parts":[{"text":"hello"},{"text":"world"}]},"taskType":"RETRIEVAL_QUERY"}`))
+	if err != nil {
+		t.Fatalf("parse embedContent: %v", err)
+	}
+	if single.Batch || len(single.Inputs) != 1 || single.Inputs[0] != "hello\nworld" || single.TaskType != "RETRIEVAL_QUERY" {
+		t.Fatalf("unexpected embedContent request %+v", single)
+	}
+	if got := gjson.GetBytes(single.GeminiResponse([][]float64{{1, 2}}), "embedding.values.1").Float(); got != 2 {
+		t.Fatalf("expected embedContent response shape, got %v", got)
+	}
+
+	raw := []byte(`{"requests":[{"model":"models/alias","content":{"parts":[{"text":"a"}]}},{"model":"models/alias","content":{"parts":[{"text":"b"}]},"outputDimensionality":8}]}`)
+	batch, err := ParseGemini(raw)
+	if err != nil {
+		t.Fatalf("parse batchEmbedContents: %v", err)
+	}
+	if !batch.Batch || len(batch.Inputs) != 2 || batch.Dimensions != 8 {
+		t.Fatalf("unexpected batch request %+v", batch)
+	}
+	if got := gjson.GetBytes(batch.GeminiResponse([][]float64{{1}, {2}}), "embeddings.#").Int(); got != 2 {
+		t.Fatalf("expected batch response shape, got %d embeddings", got)
+	}
+	if got := gjson.GetBytes(WithGeminiModel(raw, "text-embedding-004"), "requests.1.model").String(); got != "models/text-embedding-004" {
+		t.Fatalf("expected items to be readdressed, got %q", got)
+	}
+
+	vectors, tokens, err := ParseOpenAIResponse([]byte(`{"data":[{"index":1,"embedding":[3]},{"index":0,"embedding":[4]}],"usage":{"prompt_tokens":5}}`))
+	if err != nil || tokens != 5 || vectors[0][0] != 4 || vectors[1][0] != 3 {
+		t.Fatalf("unexpected OpenAI response parse %v %d %v", vectors, tokens, err)
+	}
+
+	if _, err = ParseGemini([]byte(`{"requests":[]}`)); err == nil {
+		t.Fatal("expected empty batch to be rejected")
+	}
+}
@@ -0,0 +1,334 @@
+// Package guardrails inspects inbound prompts before they are translated and forwarded.
+// Deny rules reject requests whose message text matches, and redaction rules mask matches
+// such as email addresses or API keys in place. Rules work on the client payload directly,
+// so they behave the same for every dialect.
+package guardrails
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// defaultReplacement masks redacted matches when a rule does not set its own replacement.
+const defaultReplacement = "[REDACTED]"
+
+// textKeys are the JSON fields that carry message text in the OpenAI, Claude and Gemini
+// request formats. Strings nested in arrays under these fields are text as well.
+var textKeys = map[string]struct{}{
+	"content":      {},
+	"text":         {},
+	"input":        {},
+	"instructions": {},
+	"system":       {},
+	"prompt":       {},
+}
+
+// matcher finds candidate matches; validate, when set, filters out false positives.
+type matcher struct {
+	re       *regexp.Regexp
+	validate func(string) bool
+}
+
+var detectors = map[string]matcher{
+	"email":       {re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	"api-key":     {re: regexp.MustCompile(`\b(?:sk-(?:ant-|proj-)?[A-Za-z0-9_\-]{20,}|AIza[0-9A-Za-z_\-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9\-]{10,}|AKIA[0-9A-Z]{16})\b`)},
+	"card-number": {re: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`), validate: luhnValid},
+	"ssn":         {re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	"ipv4":        {re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+}
+
+type rule struct {
+	name        string
+	deny        bool
+	message     string
+	replacement string
+	matchers    []matcher
+	apiKeys     map[string]struct{}
+	models      []string
+}
+
+func (r *rule) applies(apiKey string, models []string) bool {
+	if len(r.apiKeys) > 0 {
+		if _, ok := r.apiKeys[apiKey]; !ok {
+			return false
+		}
+	}
+	if len(r.models) == 0 {
+		return true
+	}
+	for _, pattern := range r.models {
+		for _, model := range models {
+			if util.MatchModelPattern(pattern, model) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *rule) matches(text string) bool {
+	for _, m := range r.matchers {
+		if m.validate == nil {
+			if m.re.MatchString(text) {
+				return true
+			}
+			continue
+		}
+		for _, candidate := range m.re.FindAllString(text, -1) {
+			if m.validate(candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *rule) redact(text string) (string, int) {
+	count := 0
+	for _, m := range r.matchers {
+		validate := m.validate
+		text = m.re.ReplaceAllStringFunc(text, func(match string) string {
+			if validate != nil && !validate(match) {
+				return match
+			}
+			count++
+			return r.replacement
+		})
+	}
+	return text, count
+}
+
+func compile(cfg config.GuardrailRule, index int) (*rule, error) {
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		name = "rule-" + strconv.Itoa(index+1)
+	}
+	r := &rule{name: name, message: strings.TrimSpace(cfg.Message), replacement: cfg.Replacement}
+	switch strings.ToLower(strings.TrimSpace(cfg.Action)) {
+	case config.GuardrailActionDeny:
+		r.deny = true
+	case config.GuardrailActionRedact:
+	default:
+		return nil, fmt.Errorf("guardrail %s: action must be deny or redact, got %q", name, cfg.Action)
+	}
+	if r.replacement == "" {
+		r.replacement = defaultReplacement
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail %s: %w", name, err)
+		}
+		r.matchers = append(r.matchers, matcher{re: re})
+	}
+	for _, keyword := range cfg.Keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			r.matchers = append(r.matchers, matcher{re: regexp.MustCompile(`(?i)` + regexp.QuoteMeta(keyword))})
+		}
+	}
+	for _, detector := range cfg.Detectors {
+		m, ok := detectors[strings.ToLower(strings.TrimSpace(detector))]
+		if !ok {
+			return nil, fmt.Errorf("guardrail %s: unknown detector %q", name, detector)
+		}
+		r.matchers = append(r.matchers, m)
+	}
+	if len(r.matchers) == 0 {
+		return nil, fmt.Errorf("guardrail %s: no patterns, keywords or detectors", name)
+	}
+	if len(cfg.APIKeys) > 0 {
+		r.apiKeys = make(map[string]struct{}, len(cfg.APIKeys))
+		for _, key := range cfg.APIKeys {
+			r.apiKeys[strings.TrimSpace(key)] = struct{}{}
+		}
+	}
+	r.models = cfg.Models
+	return r, nil
+}
+
+// Outcome is the result of applying the guardrails to one request.
+type Outcome struct {
+	// Payload is the request body to forward, with redactions applied.
+	Payload []byte
+	// Denied names the deny rule that matched; the request must not be forwarded.
+	Denied string
+	// Message is the deny rule's message for the client.
+	Message string
+	// Redactions counts the masked matches per rule.
+	Redactions map[string]int
+}
+
+// Engine holds the compiled rules. It is safe for concurrent use.
+type Engine struct {
+	mu    sync.RWMutex
+	rules []*rule
+}
+
+var defaultEngine = &Engine{}
+
+// Default returns the process-wide engine configured from the guardrails config section.
+func Default() *Engine { return defaultEngine }
+
+// Update replaces the rules. When any rule is invalid the previous rules stay in place.
+func (e *Engine) Update(rules []config.GuardrailRule) error {
+	compiled := make([]*rule, 0, len(rules))
+	for i := range rules {
+		r, err := compile(rules[i], i)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, r)
+	}
+	e.mu.Lock()
+	e.rules = compiled
+	e.mu.Unlock()
+	return nil
+}
+
+// Apply runs the rules that apply to the client key and any of the models against the
+// message text in payload. Deny rules are evaluated before anything is redacted.
+func (e *Engine) Apply(payload []byte, apiKey string, models ...string) Outcome {
+	outcome := Outcome{Payload: payload}
+	e.mu.RLock()
+	var active []*rule
+	for _, r := range e.rules {
+		if r.applies(apiKey, models) {
+			active = append(active, r)
+		}
+	}
+	e.mu.RUnlock()
+	if len(active) == 0 || !gjson.ValidBytes(payload) {
+		return outcome
+	}
+
+	var fields []textField
+	collectText(gjson.ParseBytes(payload), "", false, &fields)
+	for _, r := range active {
+		if !r.deny {
+			continue
+		}
+		for _, field := range fields {
+			if r.matches(field.value) {
+				outcome.Denied = r.name
+				outcome.Message = r.message
+				return outcome
+			}
+		}
+	}
+
+	out := payload
+	for _, field := range fields {
+		value := field.value
+		changed := false
+		for _, r := range active {
+			if r.deny {
+				continue
+			}
+			redacted, count := r.redact(value)
+			if count == 0 {
+				continue
+			}
+			if outcome.Redactions == nil {
+				outcome.Redactions = make(map[string]int)
+			}
+			outcome.Redactions[r.name] += count
+			value, changed = redacted, true
+		}
+		if !changed {
+			continue
+		}
+		if updated, err := sjson.SetBytes(out, field.path, value); err == nil {
+			out = updated
+		}
+	}
+	outcome.Payload = out
+	return outcome
+}
+
+// Summary formats redaction counts as "name=count" pairs for logging.
+func (o Outcome) Summary() string {
+	names := make([]string, 0, len(o.Redactions))
+	for name := range o.Redactions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+strconv.Itoa(o.Redactions[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type textField struct {
+	path  string
+	value string
+}
+
+func collectText(node gjson.Result, path string, inText bool, fields *[]textField) {
+	switch {
+	case node.IsObject():
+		node.ForEach(func(key, value gjson.Result) bool {
+			_, isText := textKeys[key.String()]
+			collectText(value, joinPath(path, escapePathKey(key.String())), isText, fields)
+			return true
+		})
+	case node.IsArray():
+		index := 0
+		node.ForEach(func(_, value gjson.Result) bool {
+			collectText(value, joinPath(path, strconv.Itoa(index)), inText, fields)
+			index++
+			return true
+		})
+	case inText && node.Type == gjson.String:
+		*fields = append(*fields, textField{path: path, value: node.String()})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// escapePathKey escapes the characters gjson and sjson treat as path syntax.
+func escapePathKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', '!', '=', '<', '>', '%', ':':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func luhnValid(candidate string) bool {
+	sum, digits, double := 0, 0, false
+	for i := len(candidate) - 1; i >= 0; i-- {
+		c := candidate[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
@@ -0,0 +1,53 @@
+package guardrails
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestDenyAndRedactAcrossDialects(t *testing.T) {
+	engine := &Engine{}
+	err := engine.Update([]config.GuardrailRule{
+		{Name: "codenames", Action: "deny", Keywords: []string{"Project Nightingale"}, Models: []string{"claude-*"}},
+		{Name: "pii", Action: "redact", Detectors: []string{"email", "card-number"}},
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	claude := []byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":[{"type":"text","text":"status of project nightingale?"}]}]}`)
+	if outcome := engine.Apply(claude, "key-a", "claude-sonnet-4-5"); outcome.Denied != "codenames" {
+		t.Fatalf("expected the keyword to deny the claude request, got %+v", outcome)
+	}
+	if outcome := engine.Apply(claude, "key-a", "gpt-5"); outcome.Denied != "" {
+		t.Fatal("expected the deny rule to be scoped to claude models")
+	}
+
+	openai := []byte(`{"model":"gpt-5","messages":[{"role":"user","content":"mail dev@example.com, card 4111 1111 1111 1111, order 1234 5678 9012 3456"}]}`)
+	outcome := engine.Apply(openai, "key-a", "gpt-5")
+	content := gjson.GetBytes(outcome.Payload, "messages.0.content").String()
+	if content != "mail [REDACTED], card [REDACTED], order 1234 5678 9012 3456" {
+		t.Fatalf("unexpected redaction %q", content)
+	}
+	if outcome.Redactions["pii"] != 2 {
+		t.Fatalf("expected two redactions, got %v", outcome.Redactions)
+	}
+
+	gemini := []byte(`{"contents":[{"role":"user","parts":[{"text":"reach me at a.b@corp.io"}]}],"generationConfig":{"temperature":0.2}}`)
+	outcome = engine.Apply(gemini, "key-a", "gemini-2.5-pro")
+	if got := gjson.GetBytes(outcome.Payload, "contents.0.parts.0.text").String(); got != "reach me at [REDACTED]" {
+		t.Fatalf("unexpected gemini redaction %q", got)
+	}
+	if gjson.GetBytes(outcome.Payload, "generationConfig.temperature").Float() != 0.2 {
+		t.Fatal("expected non-text fields to be left alone")
+	}
+
+	if err = engine.Update([]config.GuardrailRule{{Name: "bad", Action: "deny", Patterns: []string{"("}}}); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+	if outcome = engine.Apply(claude, "key-a", "claude-sonnet-4-5"); outcome.Denied != "codenames" {
+		t.Fatal("expected the previous rules to stay active after a failed update")
+	}
+}
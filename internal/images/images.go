@@ -0,0 +1,220 @@
+// Package images converts OpenAI image generation and edit requests into Gemini
+// generateContent requests for image models, and the inline image parts of the Gemini
+// responses back into OpenAI image results.
+package images
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Response formats accepted in OpenAI image requests.
+const (
+	FormatB64JSON = "b64_json"
+	FormatURL     = "url"
+)
+
+// MaxImages is the largest n accepted per request, matching the OpenAI limit.
+const MaxImages = 10
+
+// aspectRatios are the aspect ratios Gemini image models accept.
+var aspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"}
+
+// Image is an input image of an edit request.
+type Image struct {
+	MimeType string
+	Data     []byte
+}
+
+// Request is the provider-neutral form of an image request.
+type Request struct {
+	// Model is the requested model; empty when the client did not name one.
+	Model string
+	// Prompt describes the image to generate or the edit to make.
+	Prompt string
+	// N is the number of images to return.
+	N int
+	// AspectRatio is the Gemini aspect ratio derived from the OpenAI size, or empty.
+	AspectRatio string
+	// ResponseFormat is "b64_json" (default) or "url".
+	ResponseFormat string
+	// Images are the input images of an edit request, in order.
+	Images []Image
+	// Mask is the optional edit mask; transparent areas mark where to edit.
+	Mask *Image
+}
+
+// ParseOpenAI reads an OpenAI /v1/images/generations request.
+func ParseOpenAI(raw []byte) (Request, error) {
+	if !gjson.ValidBytes(raw) {
+		return Request{}, fmt.Errorf("invalid JSON body")
+	}
+	root := gjson.ParseBytes(raw)
+	n := 1
+	if v := root.Get("n"); v.Exists() {
+		n = int(v.Int())
+	}
+	return NewRequest(root.Get("model").String(), root.Get("prompt").String(), n, root.Get("size").String(), root.Get("response_format").String())
+}
+
+// NewRequest validates the common fields of generation and edit requests.
+func NewRequest(model, prompt string, n int, size, responseFormat string) (Request, error) {
+	req := Request{
+		Model:          strings.TrimSpace(model),
+		Prompt:         strings.TrimSpace(prompt),
+		N:              n,
+		ResponseFormat: strings.ToLower(strings.TrimSpace(responseFormat)),
+	}
+	if req.Prompt == "" {
+		return Request{}, fmt.Errorf("prompt is required")
+	}
+	if req.N < 1 || req.N > MaxImages {
+		return Request{}, fmt.Errorf("n must be between 1 and %d", MaxImages)
+	}
+	switch req.ResponseFormat {
+	case "":
+		req.ResponseFormat = FormatB64JSON
+	case FormatB64JSON, FormatURL:
+	default:
+		return Request{}, fmt.Errorf("unsupported response_format %q", responseFormat)
+	}
+	ratio, err := AspectRatio(size)
+	if err != nil {
+		return Request{}, err
+	}
+	req.AspectRatio = ratio
+	return req, nil
+}
+
+// AspectRatio maps an OpenAI size such as "1792x1024" to the closest Gemini aspect ratio.
+// Sizes may also be given as a ratio such as "16:9". Empty and "auto" leave the choice to
+// the model.
+func AspectRatio(size string) (string, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if size == "" || size == "auto" {
+		return "", nil
+	}
+	sep := "x"
+	if strings.Contains(size, ":") {
+		sep = ":"
+	}
+	w, h, ok := strings.Cut(size, sep)
+	width, errW := strconv.Atoi(strings.TrimSpace(w))
+	height, errH := strconv.Atoi(strings.TrimSpace(h))
+	if !ok || errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return "", fmt.Errorf("invalid size %q, expected WIDTHxHEIGHT", size)
+	}
+	target := float64(width) / float64(height)
+	best, bestDiff := "", math.Inf(1)
+	for _, candidate := range aspectRatios {
+		a, b, _ := strings.Cut(candidate, ":")
+		num, _ := strconv.Atoi(a)
+		den, _ := strconv.Atoi(b)
+		if diff := math.Abs(math.Log(target * float64(den) / float64(num))); diff < bestDiff {
+			best, bestDiff = candidate, diff
+		}
+	}
+	return best, nil
+}
+
+// Gemini builds a generateContent request body asking for one image.
+func (r Request) Gemini() []byte {
+	type inlineData struct {
+		MimeType string `json:"mimeType"`
+		Data     string `json:"data"`
+	}
+	type part struct {
+		Text       string      `json:"text,omitempty"`
+		InlineData *inlineData `json:"inlineData,omitempty"`
+	}
+	parts := make([]part, 0, len(r.Images)+3)
+	for _, image := range r.Images {
+		parts = append(parts, part{InlineData: &inlineData{MimeType: image.MimeType, Data: base64.StdEncoding.EncodeToString(image.Data)}})
+	}
+	if r.Mask != nil {
+		parts = append(parts,
+			part{Text: "The next image is a mask. Only change the areas where the mask is transparent and keep the rest of the image unchanged."},
+			part{InlineData: &inlineData{MimeType: r.Mask.MimeType, Data: base64.StdEncoding.EncodeToString(r.Mask.Data)}},
+		)
+	}
+	parts = append(parts, part{Text: r.Prompt})
+
+	generationConfig := map[string]any{"responseModalities": []string{"TEXT", "IMAGE"}}
+	if r.AspectRatio != "" {
+		generationConfig["imageConfig"] = map[string]string{"aspectRatio": r.AspectRatio}
+	}
+	out, _ := json.Marshal(map[string]any{
+		"contents":         []map[string]any{{"role": "user", "parts": parts}},
+		"generationConfig": generationConfig,
+	})
+	return out
+}
+
+// Result is an image returned by the model.
+type Result struct {
+	MimeType string
+	// B64 is the base64 encoded image data.
+	B64 string
+}
+
+// ParseGemini returns the inline images of a generateContent response and the text the
+// model returned alongside them.
+func ParseGemini(raw []byte) ([]Result, string) {
+	var (
+		results []Result
+		text    strings.Builder
+	)
+	for _, candidate := range gjson.GetBytes(raw, "candidates").Array() {
+		for _, part := range candidate.Get("content.parts").Array() {
+			if part.Get("thought").Bool() {
+				continue
+			}
+			inline := part.Get("inlineData")
+			if !inline.Exists() {
+				inline = part.Get("inline_data")
+			}
+			if data := inline.Get("data").String(); data != "" {
+				mimeType := inline.Get("mimeType").String()
+				if mimeType == "" {
+					mimeType = inline.Get("mime_type").String()
+				}
+				results = append(results, Result{MimeType: mimeType, B64: data})
+				continue
+			}
+			text.WriteString(part.Get("text").String())
+		}
+	}
+	return results, strings.TrimSpace(text.String())
+}
+
+// OpenAIResponse renders results as an OpenAI images response. The proxy does not host
+// files, so "url" results are data URLs.
+func OpenAIResponse(created int64, results []Result, responseFormat, revisedPrompt string) []byte {
+	type datum struct {
+		B64JSON       string `json:"b64_json,omitempty"`
+		URL           string `json:"url,omitempty"`
+		RevisedPrompt string `json:"revised_prompt,omitempty"`
+	}
+	data := make([]datum, 0, len(results))
+	for _, result := range results {
+		d := datum{RevisedPrompt: revisedPrompt}
+		if responseFormat == FormatURL {
+			mimeType := result.MimeType
+			if mimeType == "" {
+				mimeType = "image/png"
+			}
+			d.URL = "data:" + mimeType + ";base64," + result.B64
+		} else {
+			d.B64JSON = result.B64
+		}
+		data = append(data, d)
+	}
+	out, _ := json.Marshal(map[string]any{"created": created, "data": data})
+	return out
+}
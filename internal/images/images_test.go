@@ -0,0 +1,58 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestAspectRatioMapsOpenAISizes(t *testing.T) {
+	cases := map[string]string{
+		"":          "",
+		"auto":      "",
+		"1024x1024": "1:1",
+		"1792x1024": "16:9",
+		"1024x1792": "9:16",
+		"1536x1024": "3:2",
+		"4:3":       "4:3",
+	}
+	for size, want := range cases {
+		got, err := AspectRatio(size)
+		if err != nil || got != want {
+			t.Errorf("AspectRatio(%q) = %q, %v; want %q", size, got, err, want)
+		}
+	}
+	if _, err := AspectRatio("large"); err == nil {
+		t.Error("expected invalid size to be rejected")
+	}
+}
+
+func TestGenerationRoundTrip(t *testing.T) {
+	req, err := ParseOpenAI([]byte(`{"prompt":"a red fox","n":2,"size":"1792x1024","response_format":"url"}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	req.Images = []Image{{MimeType: "image/png", Data: []byte("png")}}
+	body := req.Gemini()
+	if gjson.GetBytes(body, "contents.0.parts.0.inlineData.mimeType").String() != "image/png" ||
+		gjson.GetBytes(body, "contents.0.parts.1.text").String() != "a red fox" ||
+		gjson.GetBytes(body, "generationConfig.imageConfig.aspectRatio").String() != "16:9" {
+		t.Fatalf("unexpected Gemini body %s", body)
+	}
+
+	results, text := ParseGemini([]byte(`{"candidates":[{"content":{"parts":[{"text":"Here is a fox."},{"inlineData":{"mimeType":"image/jpeg","data":"Zm94"}}]}}]}`))
+	if len(results) != 1 || results[0].B64 != "Zm94" || text != "Here is a fox." {
+		t.Fatalf("unexpected parse %+v %q", results, text)
+	}
+	out := OpenAIResponse(1, results, req.ResponseFormat, text)
+	if got := gjson.GetBytes(out, "data.0.url").String(); got != "data:image/jpeg;base64,Zm94" {
+		t.Fatalf("unexpected url %q", got)
+	}
+	if got := gjson.GetBytes(OpenAIResponse(1, results, FormatB64JSON, ""), "data.0.b64_json").String(); got != "Zm94" {
+		t.Fatalf("unexpected b64_json %q", got)
+	}
+
+	if _, err = ParseOpenAI([]byte(`{"prompt":"x","n":11}`)); err == nil {
+		t.Fatal("expected n above the limit to be rejected")
+	}
+}
@@ -3,6 +3,12 @@
 // more specific domain packages. It includes a comprehensive MIME type mapping for file operations.
 package misc
 
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
 // MimeTypes is a comprehensive map of file extensions to their corresponding MIME types.
 // This map is used to determine the Content-Type header for file uploads and other
 // operations where the MIME type needs to be identified from a file extension.
@@ -741,3 +747,23 @@ var MimeTypes = map[string]string{
 	"smv":         "video/x-smv",
 	"ice":         "x-conference/x-cooltalk",
 }
+
+// MaxInlineDataBytes is the largest uploaded file forwarded upstream as inline data.
+// Gemini rejects requests with larger inline parts.
+const MaxInlineDataBytes = 20 << 20
+
+// DetectMimeType returns the MIME type of an uploaded file: the declared type unless it is
+// missing or generic, then the type registered for the file extension, and finally the type
+// sniffed from the content.
+func DetectMimeType(filename, declared string, data []byte) string {
+	mimeType, _, _ := strings.Cut(declared, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if mimeType != "" && mimeType != "application/octet-stream" {
+		return mimeType
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if known, ok := MimeTypes[ext]; ok {
+		return known
+	}
+	return http.DetectContentType(data)
+}
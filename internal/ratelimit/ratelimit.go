@@ -0,0 +1,316 @@
+// Package ratelimit enforces per-client-key request and token budgets. Requests are counted
+// when they are admitted; tokens are reconciled afterwards from the usage record stream.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Limit reasons reported in Decision.Reason.
+const (
+	ReasonRequestsPerMinute = "requests_per_minute"
+	ReasonTokensPerMinute   = "tokens_per_minute"
+	ReasonDailyTokens       = "daily_tokens"
+	ReasonMonthlyTokens     = "monthly_tokens"
+)
+
+var defaultLimiter = NewLimiter()
+
+func init() {
+	coreusage.RegisterPlugin(&usagePlugin{limiter: defaultLimiter})
+}
+
+// Default returns the process-wide limiter fed by the usage record stream.
+func Default() *Limiter { return defaultLimiter }
+
+// Limits are the budgets of one client key. Zero values are unlimited.
+type Limits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int64
+	DailyTokens       int64
+	MonthlyTokens     int64
+}
+
+// Enabled reports whether any budget is set.
+func (l Limits) Enabled() bool {
+	return l.RequestsPerMinute > 0 || l.TokensPerMinute > 0 || l.DailyTokens > 0 || l.MonthlyTokens > 0
+}
+
+// Decision is the outcome of admitting a request.
+type Decision struct {
+	// Allowed reports whether the request may be dispatched.
+	Allowed bool
+	// Reason names the exhausted budget when the request is rejected.
+	Reason string
+	// RetryAfter is how long until the exhausted budget resets.
+	RetryAfter time.Duration
+	// Headers carries the rate limit headers for the response.
+	Headers http.Header
+}
+
+// counters are the usage windows of one client key.
+type counters struct {
+	Minute       time.Time `json:"minute"`
+	Requests     int       `json:"requests"`
+	MinuteTokens int64     `json:"minute_tokens"`
+	Day          string    `json:"day"`
+	DayTokens    int64     `json:"day_tokens"`
+	Month        string    `json:"month"`
+	MonthTokens  int64     `json:"month_tokens"`
+}
+
+// roll resets the windows that ended before now.
+func (c *counters) roll(now time.Time) {
+	now = now.UTC()
+	if minute := now.Truncate(time.Minute); !c.Minute.Equal(minute) {
+		c.Minute = minute
+		c.Requests = 0
+		c.MinuteTokens = 0
+	}
+	if day := now.Format(time.DateOnly); c.Day != day {
+		c.Day = day
+		c.DayTokens = 0
+	}
+	if month := now.Format("2006-01"); c.Month != month {
+		c.Month = month
+		c.MonthTokens = 0
+	}
+}
+
+func (c *counters) empty() bool {
+	return c.Requests == 0 && c.MinuteTokens == 0 && c.DayTokens == 0 && c.MonthTokens == 0
+}
+
+// CounterStore persists the counters so budgets survive restarts.
+type CounterStore interface {
+	LoadRateLimits(ctx context.Context) ([]byte, error)
+	SaveRateLimits(ctx context.Context, data []byte) error
+}
+
+// Limiter tracks usage per client key. Keys are hashed so persisted counters never contain
+// client secrets.
+type Limiter struct {
+	mu       sync.Mutex
+	counters map[string]*counters
+	dirty    bool
+	store    CounterStore
+	cancel   context.CancelFunc
+}
+
+// NewLimiter constructs an empty limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{counters: make(map[string]*counters)}
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (l *Limiter) countersLocked(key string, now time.Time) *counters {
+	id := hashKey(key)
+	c, ok := l.counters[id]
+	if !ok {
+		c = &counters{}
+		l.counters[id] = c
+	}
+	c.roll(now)
+	return c
+}
+
+// Allow admits a request for the key against its limits and counts it on success.
+func (l *Limiter) Allow(key string, limits Limits, now time.Time) Decision {
+	if key == "" || !limits.Enabled() {
+		return Decision{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.countersLocked(key, now)
+	minuteReset := c.Minute.Add(time.Minute).Sub(now)
+	decision := Decision{Allowed: true, Headers: make(http.Header)}
+	if limits.RequestsPerMinute > 0 {
+		remaining := limits.RequestsPerMinute - c.Requests
+		if remaining <= 0 {
+			decision = reject(decision, ReasonRequestsPerMinute, minuteReset)
+		}
+		decision.Headers.Set("X-RateLimit-Limit-Requests", strconv.Itoa(limits.RequestsPerMinute))
+		decision.Headers.Set("X-RateLimit-Remaining-Requests", strconv.Itoa(max(remaining-1, 0)))
+		decision.Headers.Set("X-RateLimit-Reset-Requests", formatReset(minuteReset))
+	}
+	if limits.TokensPerMinute > 0 {
+		remaining := limits.TokensPerMinute - c.MinuteTokens
+		if remaining <= 0 && decision.Allowed {
+			decision = reject(decision, ReasonTokensPerMinute, minuteReset)
+		}
+		decision.Headers.Set("X-RateLimit-Limit-Tokens", strconv.FormatInt(limits.TokensPerMinute, 10))
+		decision.Headers.Set("X-RateLimit-Remaining-Tokens", strconv.FormatInt(max(remaining, 0), 10))
+		decision.Headers.Set("X-RateLimit-Reset-Tokens", formatReset(minuteReset))
+	}
+	if limits.DailyTokens > 0 && c.DayTokens >= limits.DailyTokens && decision.Allowed {
+		day, _ := time.Parse(time.DateOnly, c.Day)
+		decision = reject(decision, ReasonDailyTokens, day.AddDate(0, 0, 1).Sub(now))
+	}
+	if limits.MonthlyTokens > 0 && c.MonthTokens >= limits.MonthlyTokens && decision.Allowed {
+		month, _ := time.Parse("2006-01", c.Month)
+		decision = reject(decision, ReasonMonthlyTokens, month.AddDate(0, 1, 0).Sub(now))
+	}
+	if !decision.Allowed {
+		decision.Headers.Set("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+		return decision
+	}
+	c.Requests++
+	l.dirty = true
+	return decision
+}
+
+func reject(decision Decision, reason string, retryAfter time.Duration) Decision {
+	decision.Allowed = false
+	decision.Reason = reason
+	decision.RetryAfter = max(retryAfter, time.Second)
+	return decision
+}
+
+// formatReset renders a reset interval the way OpenAI does, e.g. "12s".
+func formatReset(d time.Duration) string {
+	return fmt.Sprintf("%ds", int(math.Ceil(d.Seconds())))
+}
+
+// AddTokens charges tokens consumed by a completed request to the key's current windows.
+func (l *Limiter) AddTokens(key string, tokens int64, now time.Time) {
+	if key == "" || tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.countersLocked(key, now)
+	c.MinuteTokens += tokens
+	c.DayTokens += tokens
+	c.MonthTokens += tokens
+	l.dirty = true
+}
+
+// Start loads persisted counters from the store and saves them every interval until Stop.
+func (l *Limiter) Start(ctx context.Context, store CounterStore, interval time.Duration) {
+	if store == nil {
+		return
+	}
+	if data, err := store.LoadRateLimits(ctx); err != nil {
+		log.Warnf("failed to load client rate limit counters: %v", err)
+	} else if len(data) > 0 {
+		loaded := make(map[string]*counters)
+		if errUnmarshal := json.Unmarshal(data, &loaded); errUnmarshal != nil {
+			log.Warnf("failed to decode client rate limit counters: %v", errUnmarshal)
+		} else {
+			l.restore(loaded, time.Now())
+		}
+	}
+	l.Stop()
+	loopCtx, cancel := context.WithCancel(ctx)
+	l.mu.Lock()
+	l.store = store
+	l.cancel = cancel
+	l.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-loopCtx.Done():
+				return
+			case <-ticker.C:
+				if err := l.Flush(loopCtx); err != nil {
+					log.Warnf("failed to persist client rate limit counters: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the periodic persistence loop.
+func (l *Limiter) Stop() {
+	l.mu.Lock()
+	cancel := l.cancel
+	l.cancel = nil
+	l.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// restore merges persisted counters, keeping only windows that are still open.
+func (l *Limiter) restore(loaded map[string]*counters, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, saved := range loaded {
+		if saved == nil {
+			continue
+		}
+		c := *saved
+		c.roll(now)
+		if !c.empty() {
+			l.counters[id] = &c
+		}
+	}
+}
+
+// Flush saves the counters to the store when they changed since the last save.
+func (l *Limiter) Flush(ctx context.Context) error {
+	l.mu.Lock()
+	store := l.store
+	if store == nil || !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	for id, c := range l.counters {
+		c.roll(now)
+		if c.empty() {
+			delete(l.counters, id)
+		}
+	}
+	raw, err := json.Marshal(l.counters)
+	l.dirty = false
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(raw) <= 2 {
+		raw = nil
+	}
+	if err = store.SaveRateLimits(ctx, raw); err != nil {
+		l.mu.Lock()
+		l.dirty = true
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// usagePlugin reconciles consumed tokens from usage records.
+type usagePlugin struct {
+	limiter *Limiter
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *usagePlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if p == nil || p.limiter == nil || record.APIKey == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens <= 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	p.limiter.AddTokens(record.APIKey, tokens, time.Now())
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type memoryCounterStore struct {
+	data []byte
+}
+
+func (s *memoryCounterStore) LoadRateLimits(context.Context) ([]byte, error) { return s.data, nil }
+
+func (s *memoryCounterStore) SaveRateLimits(_ context.Context, data []byte) error {
+	s.data = append([]byte(nil), data...)
+	return nil
+}
+
+func TestAllowRejectsOnceRequestBudgetIsSpent(t *testing.T) {
+	l := NewLimiter()
+	now := time.Date(2026, 3, 1, 10, 0, 15, 0, time.UTC)
+	limits := Limits{RequestsPerMinute: 2}
+	for i := 0; i < 2; i++ {
+		if d := l.Allow("client", limits, now); !d.Allowed {
+			t.Fatalf("request %d rejected: %s", i, d.Reason)
+		}
+	}
+	d := l.Allow("client", limits, now)
+	if d.Allowed || d.Reason != ReasonRequestsPerMinute {
+		t.Fatalf("expected a requests-per-minute rejection, got %+v", d)
+	}
+	if got := d.Headers.Get("Retry-After"); got != "45" {
+		t.Fatalf("expected Retry-After 45, got %q", got)
+	}
+	if got := d.Headers.Get("X-RateLimit-Remaining-Requests"); got != "0" {
+		t.Fatalf("expected no remaining requests, got %q", got)
+	}
+	if d = l.Allow("client", limits, now.Add(time.Minute)); !d.Allowed {
+		t.Fatal("expected the next minute to admit the request")
+	}
+}
+
+func TestAllowChargesReconciledTokens(t *testing.T) {
+	l := NewLimiter()
+	now := time.Date(2026, 3, 31, 23, 59, 0, 0, time.UTC)
+	limits := Limits{TokensPerMinute: 1000, DailyTokens: 1500}
+	if d := l.Allow("client", limits, now); !d.Allowed {
+		t.Fatalf("first request rejected: %s", d.Reason)
+	}
+	l.AddTokens("client", 1000, now)
+	if d := l.Allow("client", limits, now); d.Allowed || d.Reason != ReasonTokensPerMinute {
+		t.Fatalf("expected a tokens-per-minute rejection, got %+v", d)
+	}
+	if d := l.Allow("other", limits, now); !d.Allowed {
+		t.Fatal("expected other keys to keep their own budget")
+	}
+	l.AddTokens("client", 600, now.Add(30*time.Second))
+	if d := l.Allow("client", limits, now.Add(time.Minute)); !d.Allowed {
+		t.Fatalf("expected the new day to reset the budgets, got %s", d.Reason)
+	}
+	l.AddTokens("client", 1500, now.Add(time.Minute))
+	if d := l.Allow("client", limits, now.Add(3*time.Minute)); d.Allowed || d.Reason != ReasonDailyTokens {
+		t.Fatalf("expected a daily budget rejection, got %+v", d)
+	}
+}
+
+func TestCountersSurviveRestart(t *testing.T) {
+	store := &memoryCounterStore{}
+	now := time.Now()
+	limits := Limits{DailyTokens: 100}
+
+	first := NewLimiter()
+	first.Start(context.Background(), store, time.Hour)
+	first.Allow("client", limits, now)
+	first.AddTokens("client", 100, now)
+	first.Stop()
+	if err := first.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(store.data) == 0 {
+		t.Fatal("expected counters to be persisted")
+	}
+
+	second := NewLimiter()
+	second.Start(context.Background(), store, time.Hour)
+	defer second.Stop()
+	if d := second.Allow("client", limits, time.Now()); d.Allowed || d.Reason != ReasonDailyTokens {
+		t.Fatalf("expected the restored daily budget to be spent, got %+v", d)
+	}
+}
@@ -81,7 +81,7 @@ func GetGeminiModels() []*ModelInfo {
 			Description:                "Stable release (June 17th, 2025) of Gemini 2.5 Pro",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 		{
@@ -96,7 +96,7 @@ func GetGeminiModels() []*ModelInfo {
 			Description:                "Stable version of Gemini 2.5 Flash, our mid-size multimodal model that supports up to 1 million tokens, released in June of 2025.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -111,7 +111,7 @@ func GetGeminiModels() []*ModelInfo {
 			Description:                "Our smallest and most cost effective model, built for at scale usage.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -126,9 +126,37 @@ func GetGeminiModels() []*ModelInfo {
 			Description:                "Gemini 3 Pro Preview",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752451200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Gemini text embedding model with up to 3072 output dimensions",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+			Kind:                       ModelKindEmbedding,
+		},
+		{
+			ID:                         "text-embedding-004",
+			Object:                     "model",
+			Created:                    1715558400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-004",
+			Version:                    "004",
+			DisplayName:                "Text Embedding 004",
+			Description:                "Text embedding model with 768 output dimensions",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+			Kind:                       ModelKindEmbedding,
+		},
 	}
 }
 
@@ -146,7 +174,7 @@ func GetGeminiVertexModels() []*ModelInfo {
 			Description:                "Stable release (June 17th, 2025) of Gemini 2.5 Pro",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 		{
@@ -161,7 +189,7 @@ func GetGeminiVertexModels() []*ModelInfo {
 			Description:                "Stable version of Gemini 2.5 Flash, our mid-size multimodal model that supports up to 1 million tokens, released in June of 2025.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -176,7 +204,7 @@ func GetGeminiVertexModels() []*ModelInfo {
 			Description:                "Our smallest and most cost effective model, built for at scale usage.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -191,7 +219,7 @@ func GetGeminiVertexModels() []*ModelInfo {
 			Description:                "Gemini 3 Pro Preview",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 		{
@@ -206,7 +234,35 @@ func GetGeminiVertexModels() []*ModelInfo {
 			Description:                "Gemini 3 Pro Image Preview",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
+		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752451200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Gemini text embedding model with up to 3072 output dimensions",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+			Kind:                       ModelKindEmbedding,
+		},
+		{
+			ID:                         "text-embedding-005",
+			Object:                     "model",
+			Created:                    1731974400,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-005",
+			Version:                    "005",
+			DisplayName:                "Text Embedding 005",
+			Description:                "Text embedding model with 768 output dimensions",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+			Kind:                       ModelKindEmbedding,
 		},
 	}
 }
@@ -226,7 +282,7 @@ func GetGeminiCLIModels() []*ModelInfo {
 			Description:                "Stable release (June 17th, 2025) of Gemini 2.5 Pro",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 		{
@@ -241,7 +297,7 @@ func GetGeminiCLIModels() []*ModelInfo {
 			Description:                "Stable version of Gemini 2.5 Flash, our mid-size multimodal model that supports up to 1 million tokens, released in June of 2025.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -256,7 +312,7 @@ func GetGeminiCLIModels() []*ModelInfo {
 			Description:                "Our smallest and most cost effective model, built for at scale usage.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -271,7 +327,7 @@ func GetGeminiCLIModels() []*ModelInfo {
 			Description:                "Gemini 3 Pro Preview",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 	}
@@ -292,7 +348,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "Stable release (June 17th, 2025) of Gemini 2.5 Pro",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 		{
@@ -307,7 +363,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "Stable version of Gemini 2.5 Flash, our mid-size multimodal model that supports up to 1 million tokens, released in June of 2025.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -322,7 +378,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "Our smallest and most cost effective model, built for at scale usage.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -337,7 +393,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "Gemini 3 Pro Preview",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 		{
@@ -352,7 +408,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "Latest release of Gemini Pro",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true},
 		},
 		{
@@ -367,7 +423,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "Latest release of Gemini Flash",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 0, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -382,7 +438,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "Latest release of Gemini Flash-Lite",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			Thinking:                   &ThinkingSupport{Min: 512, Max: 24576, ZeroAllowed: true, DynamicAllowed: true},
 		},
 		{
@@ -397,7 +453,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "State-of-the-art image generation and editing model.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           8192,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			// image models don't support thinkingConfig; leave Thinking nil
 		},
 		{
@@ -412,7 +468,7 @@ func GetAIStudioModels() []*ModelInfo {
 			Description:                "State-of-the-art image generation and editing model.",
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           8192,
-			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent"},
 			// image models don't support thinkingConfig; leave Thinking nil
 		},
 	}
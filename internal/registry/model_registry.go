@@ -50,6 +50,17 @@ type ModelInfo struct {
 	// Thinking holds provider-specific reasoning/thinking budget capabilities.
 	// This is optional and currently used for Gemini thinking budget normalization.
 	Thinking *ThinkingSupport `json:"thinking,omitempty"`
+
+	// Kind separates non-chat models such as embedding models; empty means a chat model.
+	Kind string `json:"kind,omitempty"`
+}
+
+// ModelKindEmbedding marks models served through the embeddings endpoints.
+const ModelKindEmbedding = "embedding"
+
+// IsEmbedding reports whether the model produces embeddings rather than chat completions.
+func (m *ModelInfo) IsEmbedding() bool {
+	return m != nil && m.Kind == ModelKindEmbedding
 }
 
 // ThinkingSupport describes a model family's supported internal reasoning budget range.
@@ -743,9 +754,16 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if len(model.SupportedParameters) > 0 {
 			result["supported_parameters"] = model.SupportedParameters
 		}
+		if model.Kind != "" {
+			result["kind"] = model.Kind
+		}
 		return result
 
 	case "claude":
+		// Claude clients only know chat models.
+		if model.IsEmbedding() {
+			return nil
+		}
 		result := map[string]any{
 			"id":       model.ID,
 			"object":   "model",
@@ -804,6 +822,9 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if model.Created != 0 {
 			result["created"] = model.Created
 		}
+		if model.Kind != "" {
+			result["kind"] = model.Kind
+		}
 		return result
 	}
 }
@@ -858,8 +879,11 @@ func (r *ModelRegistry) GetFirstAvailableModel(handlerType string) (string, erro
 		return createdI > createdJ
 	})
 
-	// Find the first model with available clients
+	// Find the first chat model with available clients
 	for _, model := range models {
+		if kind, _ := model["kind"].(string); kind == ModelKindEmbedding {
+			continue
+		}
 		if modelID, ok := model["id"].(string); ok {
 			if count := r.GetModelCount(modelID); count > 0 {
 				return modelID, nil
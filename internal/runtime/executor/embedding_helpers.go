@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embeddings"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// postEmbeddingRequest sends an embedding request upstream with request logging and returns
+// the response body. Custom headers configured for the auth are applied after header, and
+// non-2xx responses are returned as statusErr.
+func postEmbeddingRequest(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, provider, url string, header http.Header, body []byte) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		httpReq.Header[key] = values
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	var attrs map[string]string
+	if auth != nil {
+		attrs = auth.Attributes
+	}
+	util.ApplyCustomHeadersFromAttrs(httpReq, attrs)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  provider,
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("%s executor: close embedding response body error: %v", provider, errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, err
+	}
+	appendAPIResponseChunk(ctx, cfg, data)
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), data))
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(data)}
+	}
+	return data, nil
+}
+
+// parseEmbeddingRequest reads an OpenAI or Gemini embedding request, depending on the
+// client format, reporting bad input as a 400.
+func parseEmbeddingRequest(from sdktranslator.Format, payload []byte) (embeddings.Request, error) {
+	parse := embeddings.ParseOpenAI
+	if from == sdktranslator.FormatGemini {
+		parse = embeddings.ParseGemini
+	}
+	parsed, err := parse(payload)
+	if err != nil {
+		return parsed, statusErr{code: http.StatusBadRequest, msg: err.Error()}
+	}
+	return parsed, nil
+}
+
+// embeddingResponse renders vectors in the client format.
+func embeddingResponse(from sdktranslator.Format, parsed embeddings.Request, model string, vectors [][]float64, tokens int64) []byte {
+	if from == sdktranslator.FormatGemini {
+		return parsed.GeminiResponse(vectors)
+	}
+	return embeddings.OpenAIResponse(model, vectors, parsed.EncodingFormat, tokens)
+}
+
+// estimateEmbeddingTokens approximates the input tokens of providers that do not report usage.
+func estimateEmbeddingTokens(model string, inputs []string) int64 {
+	enc, err := tokenizerForModel(model)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, input := range inputs {
+		if count, errCount := enc.Count(input); errCount == nil {
+			total += int64(count)
+		}
+	}
+	return total
+}
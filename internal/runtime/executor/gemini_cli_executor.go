@@ -83,7 +83,7 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 	}
 
 	projectID := resolveGeminiProjectID(auth)
-	models := cliPreviewFallbackOrder(req.Model)
+	models := cliPreviewFallbackOrder(e.cfg, req.Model)
 	if len(models) == 0 || models[0] != req.Model {
 		models = append([]string{req.Model}, models...)
 	}
@@ -219,7 +219,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 
 	projectID := resolveGeminiProjectID(auth)
 
-	models := cliPreviewFallbackOrder(req.Model)
+	models := cliPreviewFallbackOrder(e.cfg, req.Model)
 	if len(models) == 0 || models[0] != req.Model {
 		models = append([]string{req.Model}, models...)
 	}
@@ -390,7 +390,7 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini-cli")
 
-	models := cliPreviewFallbackOrder(req.Model)
+	models := cliPreviewFallbackOrder(e.cfg, req.Model)
 	if len(models) == 0 || models[0] != req.Model {
 		models = append([]string{req.Model}, models...)
 	}
@@ -683,7 +683,15 @@ func geminiCLIClientMetadata() string {
 }
 
 // cliPreviewFallbackOrder returns preview model candidates for a base model.
-func cliPreviewFallbackOrder(model string) []string {
+// A model-fallbacks entry for the model replaces these built-in candidates.
+func cliPreviewFallbackOrder(cfg *config.Config, model string) []string {
+	if cfg != nil {
+		for configured := range cfg.ModelFallbacks {
+			if strings.EqualFold(strings.TrimSpace(configured), model) {
+				return nil
+			}
+		}
+	}
 	switch model {
 	case "gemini-2.5-pro":
 		return []string{
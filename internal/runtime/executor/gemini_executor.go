@@ -14,9 +14,11 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embeddings"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -277,6 +279,53 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	return stream, nil
 }
 
+// Embed computes embeddings through batchEmbedContents. Gemini clients are forwarded to the
+// method they called unchanged. The Gemini API reports no token usage for embeddings, so the
+// recorded input tokens are estimated.
+func (e *GeminiExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	parsed, err := parseEmbeddingRequest(opts.SourceFormat, req.Payload)
+	if err != nil {
+		return resp, err
+	}
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("x-goog-api-key", apiKey)
+	} else if bearer != "" {
+		header.Set("Authorization", "Bearer "+bearer)
+	}
+	native := opts.SourceFormat == sdktranslator.FormatGemini
+	method, body := "batchEmbedContents", parsed.GeminiBatch(req.Model)
+	if native {
+		body = embeddings.WithGeminiModel(bytes.Clone(req.Payload), req.Model)
+		if !parsed.Batch {
+			method = "embedContent"
+		}
+	}
+	url := fmt.Sprintf("%s/%s/models/%s:%s", resolveGeminiBaseURL(auth), glAPIVersion, req.Model, method)
+	data, err := postEmbeddingRequest(ctx, e.cfg, auth, e.Identifier(), url, header, body)
+	if err != nil {
+		return resp, err
+	}
+	tokens := estimateEmbeddingTokens(req.Model, parsed.Inputs)
+	payload := data
+	if !native {
+		vectors, errParse := embeddings.ParseGeminiBatch(data)
+		if errParse != nil {
+			return resp, statusErr{code: http.StatusBadGateway, msg: errParse.Error()}
+		}
+		payload = embeddings.OpenAIResponse(req.Model, vectors, parsed.EncodingFormat, tokens)
+	}
+	reporter.publish(ctx, usage.Detail{InputTokens: tokens, TotalTokens: tokens})
+	reporter.ensurePublished(ctx)
+	resp = cliproxyexecutor.Response{Payload: payload}
+	return resp, nil
+}
+
 func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	apiKey, bearer := geminiCreds(auth)
 
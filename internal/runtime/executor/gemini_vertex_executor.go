@@ -15,9 +15,11 @@ import (
 
 	vertexauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/vertex"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embeddings"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -29,6 +31,9 @@ import (
 const (
 	// vertexAPIVersion aligns with current public Vertex Generative AI API.
 	vertexAPIVersion = "v1"
+
+	// vertexEmbeddingBatchSize is the most instances sent in one text embedding predict call.
+	vertexEmbeddingBatchSize = 250
 )
 
 // GeminiVertexExecutor sends requests to Vertex AI Gemini endpoints using service account credentials.
@@ -149,6 +154,57 @@ func (e *GeminiVertexExecutor) Execute(ctx context.Context, auth *cliproxyauth.A
 	return resp, nil
 }
 
+// Embed computes embeddings through the Vertex AI predict endpoint. Gemini embedding models
+// accept a single instance per call, so their inputs are sent one at a time.
+func (e *GeminiVertexExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	projectID, location, saJSON, errCreds := vertexCreds(auth)
+	if errCreds != nil {
+		return resp, errCreds
+	}
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	parsed, err := parseEmbeddingRequest(opts.SourceFormat, req.Payload)
+	if err != nil {
+		return resp, err
+	}
+	token, errTok := vertexAccessToken(ctx, e.cfg, auth, saJSON)
+	if errTok != nil {
+		log.Errorf("vertex executor: access token error: %v", errTok)
+		return resp, statusErr{code: 500, msg: "internal server error"}
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	url := fmt.Sprintf("%s/%s/projects/%s/locations/%s/publishers/google/models/%s:predict", vertexBaseURL(location), vertexAPIVersion, projectID, location, req.Model)
+
+	batchSize := vertexEmbeddingBatchSize
+	if strings.HasPrefix(req.Model, "gemini-embedding") {
+		batchSize = 1
+	}
+	var (
+		vectors [][]float64
+		tokens  int64
+	)
+	for start := 0; start < len(parsed.Inputs); start += batchSize {
+		end := min(start+batchSize, len(parsed.Inputs))
+		data, errPost := postEmbeddingRequest(ctx, e.cfg, auth, e.Identifier(), url, header, parsed.VertexPredict(parsed.Inputs[start:end]))
+		if errPost != nil {
+			return resp, errPost
+		}
+		batch, batchTokens, errParse := embeddings.ParseVertexPredict(data)
+		if errParse != nil {
+			return resp, statusErr{code: http.StatusBadGateway, msg: errParse.Error()}
+		}
+		vectors = append(vectors, batch...)
+		tokens += batchTokens
+	}
+	reporter.publish(ctx, usage.Detail{InputTokens: tokens, TotalTokens: tokens})
+	reporter.ensurePublished(ctx)
+	resp = cliproxyexecutor.Response{Payload: embeddingResponse(opts.SourceFormat, parsed, req.Model, vectors, tokens)}
+	return resp, nil
+}
+
 // ExecuteStream handles SSE streaming for Vertex.
 func (e *GeminiVertexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
 	projectID, location, saJSON, errCreds := vertexCreds(auth)
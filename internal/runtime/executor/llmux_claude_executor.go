@@ -274,11 +274,8 @@ func extractUserEmail(auth *cliproxyauth.Auth) string {
 	}
 
 	// Try to get from auth metadata
-	if auth.AccountInfo != nil {
-		accountType, accountValue := auth.AccountInfo()
-		if accountType == "email" {
-			return accountValue
-		}
+	if accountType, accountValue := auth.AccountInfo(); accountType == "email" {
+		return accountValue
 	}
 
 	return ""
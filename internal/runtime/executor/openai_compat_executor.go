@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embeddings"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -235,6 +236,51 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 	return stream, nil
 }
 
+// Embed forwards an embedding request to the provider's /embeddings endpoint. Gemini
+// requests are converted to the OpenAI format and back.
+func (e *OpenAICompatExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	baseURL, apiKey := e.resolveCredentials(auth)
+	if baseURL == "" {
+		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
+		return
+	}
+	body := bytes.Clone(req.Payload)
+	var parsed embeddings.Request
+	if opts.SourceFormat == sdktranslator.FormatGemini {
+		if parsed, err = parseEmbeddingRequest(opts.SourceFormat, body); err != nil {
+			return resp, err
+		}
+		body = parsed.OpenAI(req.Model)
+	}
+	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
+		body = e.overrideModel(body, modelOverride)
+	}
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+	header.Set("User-Agent", "cli-proxy-openai-compat")
+	url := strings.TrimSuffix(baseURL, "/") + "/embeddings"
+	data, err := postEmbeddingRequest(ctx, e.cfg, auth, e.Identifier(), url, header, body)
+	if err != nil {
+		return resp, err
+	}
+	reporter.publish(ctx, parseOpenAIUsage(data))
+	reporter.ensurePublished(ctx)
+	if opts.SourceFormat == sdktranslator.FormatGemini {
+		vectors, _, errParse := embeddings.ParseOpenAIResponse(data)
+		if errParse != nil {
+			return resp, statusErr{code: http.StatusBadGateway, msg: errParse.Error()}
+		}
+		return cliproxyexecutor.Response{Payload: parsed.GeminiResponse(vectors)}, nil
+	}
+	resp = cliproxyexecutor.Response{Payload: e.overrideModel(data, req.Model)}
+	return resp, nil
+}
+
 func (e *OpenAICompatExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
@@ -22,6 +22,7 @@ type usageReporter struct {
 	authIndex   uint64
 	apiKey      string
 	source      string
+	affinity    string
 	requestedAt time.Time
 	once        sync.Once
 }
@@ -34,6 +35,7 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
 		source:      resolveUsageSource(auth, apiKey),
+		affinity:    cliproxyauth.SessionAffinityFromContext(ctx),
 	}
 	if auth != nil {
 		reporter.authID = auth.ID
@@ -72,6 +74,7 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed {
 		return
 	}
+	canceled := failed && cliproxyauth.HedgeCanceledFromContext(ctx)
 	r.once.Do(func() {
 		usage.PublishRecord(ctx, usage.Record{
 			Provider:    r.provider,
@@ -81,7 +84,11 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
-			Failed:      failed,
+			Failed:      failed && !canceled,
+			Affinity:    r.affinity,
+			Hedge:       cliproxyauth.HedgeRoleFromContext(ctx),
+			Canceled:    canceled,
+			QueueWait:   cliproxyauth.QueueWaitFromContext(ctx),
 			Detail:      detail,
 		})
 	})
@@ -105,6 +112,9 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
 			Failed:      false,
+			Affinity:    r.affinity,
+			Hedge:       cliproxyauth.HedgeRoleFromContext(ctx),
+			QueueWait:   cliproxyauth.QueueWaitFromContext(ctx),
 			Detail:      usage.Detail{},
 		})
 	})
@@ -114,6 +124,9 @@ func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
 	}
+	if identity, ok := cliproxyauth.ClientIdentityFromContext(ctx); ok {
+		return identity.APIKey
+	}
 	ginCtx, ok := ctx.Value("gin").(*gin.Context)
 	if !ok || ginCtx == nil {
 		return ""
@@ -18,6 +18,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypto"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
@@ -241,7 +242,7 @@ func (s *GitTokenStore) Save(_ context.Context, auth *cliproxyauth.Auth) (string
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = authcrypto.SaveStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -249,19 +250,8 @@ func (s *GitTokenStore) Save(_ context.Context, auth *cliproxyauth.Auth) (string
 		if errMarshal != nil {
 			return "", fmt.Errorf("auth filestore: marshal metadata failed: %w", errMarshal)
 		}
-		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
-			}
-		} else if !os.IsNotExist(errRead) {
-			return "", fmt.Errorf("auth filestore: read existing failed: %w", errRead)
-		}
-		tmp := path + ".tmp"
-		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
-			return "", fmt.Errorf("auth filestore: write temp failed: %w", errWrite)
-		}
-		if errRename := os.Rename(tmp, path); errRename != nil {
-			return "", fmt.Errorf("auth filestore: rename failed: %w", errRename)
+		if errWrite := authcrypto.WriteFile(path, raw); errWrite != nil {
+			return "", fmt.Errorf("auth filestore: write failed: %w", errWrite)
 		}
 	default:
 		return "", fmt.Errorf("auth filestore: nothing to persist for %s", auth.ID)
@@ -301,6 +291,7 @@ func (s *GitTokenStore) List(_ context.Context) ([]*cliproxyauth.Auth, error) {
 		return nil, fmt.Errorf("auth filestore: directory not configured")
 	}
 	entries := make([]*cliproxyauth.Auth, 0)
+	var migrated []string
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
@@ -311,10 +302,15 @@ func (s *GitTokenStore) List(_ context.Context) ([]*cliproxyauth.Auth, error) {
 		if !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
 			return nil
 		}
-		auth, err := s.readAuthFile(path, dir)
+		auth, resealed, err := s.readAuthFile(path, dir)
 		if err != nil {
 			return nil
 		}
+		if resealed {
+			if rel, errRel := s.relativeToRepo(path); errRel == nil {
+				migrated = append(migrated, rel)
+			}
+		}
 		if auth != nil {
 			entries = append(entries, auth)
 		}
@@ -323,6 +319,14 @@ func (s *GitTokenStore) List(_ context.Context) ([]*cliproxyauth.Auth, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(migrated) > 0 {
+		s.mu.Lock()
+		errCommit := s.commitAndPushLocked("Encrypt auth records at rest", migrated...)
+		s.mu.Unlock()
+		if errCommit != nil {
+			return nil, errCommit
+		}
+	}
 	return entries, nil
 }
 
@@ -405,17 +409,19 @@ func (s *GitTokenStore) resolveDeletePath(id string) (string, error) {
 	return filepath.Join(dir, id), nil
 }
 
-func (s *GitTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+// readAuthFile loads the auth at path; resealed reports whether the file was rewritten with
+// the primary encryption key and needs to be committed.
+func (s *GitTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, bool, error) {
+	data, resealed, err := authcrypto.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
+		return nil, false, fmt.Errorf("read file: %w", err)
 	}
 	if len(data) == 0 {
-		return nil, nil
+		return nil, resealed, nil
 	}
 	metadata := make(map[string]any)
 	if err = json.Unmarshal(data, &metadata); err != nil {
-		return nil, fmt.Errorf("unmarshal auth json: %w", err)
+		return nil, resealed, fmt.Errorf("unmarshal auth json: %w", err)
 	}
 	provider, _ := metadata["type"].(string)
 	if provider == "" {
@@ -423,7 +429,7 @@ func (s *GitTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 	}
 	info, err := os.Stat(path)
 	if err != nil {
-		return nil, fmt.Errorf("stat file: %w", err)
+		return nil, resealed, fmt.Errorf("stat file: %w", err)
 	}
 	id := s.idFor(path, baseDir)
 	auth := &cliproxyauth.Auth{
@@ -442,7 +448,7 @@ func (s *GitTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 	if email, ok := metadata["email"].(string); ok && email != "" {
 		auth.Attributes["email"] = email
 	}
-	return auth, nil
+	return auth, resealed, nil
 }
 
 func (s *GitTokenStore) idFor(path, baseDir string) string {
@@ -686,64 +692,169 @@ func ensureEmptyFile(path string) error {
 	return nil
 }
 
-func jsonEqual(a, b []byte) bool {
-	var objA any
-	var objB any
-	if err := json.Unmarshal(a, &objA); err != nil {
-		return false
+// Sidecar files hold runtime data next to the auth files. They have no .json suffix so they
+// are never mistaken for auth records.
+const gitVirtualKeysFileName = ".virtual-keys"
+
+// Local files hold runtime data inside the .git directory. Cooldowns and rate limit
+// counters change too often to be committed and pushed on every flush, so they survive
+// restarts of this checkout but are not shared through the remote.
+const (
+	gitAuthStateFileName  = "cliproxy-auth-state.json"
+	gitRateLimitsFileName = "cliproxy-rate-limits.json"
+)
+
+// localPath returns the path of a local runtime file inside the .git directory.
+func (s *GitTokenStore) localPath(name string) (string, error) {
+	if err := s.EnsureRepository(); err != nil {
+		return "", err
+	}
+	repoDir := s.repoDirSnapshot()
+	if repoDir == "" {
+		return "", fmt.Errorf("git token store: repository path not configured")
+	}
+	return filepath.Join(repoDir, ".git", name), nil
+}
+
+// readLocal returns the content of a local runtime file, or nil when it does not exist.
+func (s *GitTokenStore) readLocal(name string) ([]byte, error) {
+	path, err := s.localPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("git token store: read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// writeLocal atomically replaces a local runtime file; empty data removes it.
+func (s *GitTokenStore) writeLocal(name string, data []byte) error {
+	path, err := s.localPath(name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(data) == 0 {
+		if errRemove := os.Remove(path); errRemove != nil && !errors.Is(errRemove, fs.ErrNotExist) {
+			return fmt.Errorf("git token store: delete %s: %w", name, errRemove)
+		}
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("git token store: write %s: %w", name, err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("git token store: rename %s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadState reads the persisted runtime availability state of the auths.
+func (s *GitTokenStore) LoadState(_ context.Context) (map[string]*cliproxyauth.AuthState, error) {
+	data, err := s.readLocal(gitAuthStateFileName)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]*cliproxyauth.AuthState)
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err = json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("git token store: decode state: %w", err)
+	}
+	return states, nil
+}
+
+// SaveState replaces the persisted runtime availability state. It is written locally and
+// never committed.
+func (s *GitTokenStore) SaveState(_ context.Context, states map[string]*cliproxyauth.AuthState) error {
+	if len(states) == 0 {
+		return s.writeLocal(gitAuthStateFileName, nil)
 	}
-	if err := json.Unmarshal(b, &objB); err != nil {
-		return false
+	raw, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("git token store: marshal state: %w", err)
 	}
-	return deepEqualJSON(objA, objB)
+	return s.writeLocal(gitAuthStateFileName, raw)
+}
+
+// LoadRateLimits reads the persisted client rate limit counters.
+func (s *GitTokenStore) LoadRateLimits(_ context.Context) ([]byte, error) {
+	return s.readLocal(gitRateLimitsFileName)
+}
+
+// SaveRateLimits replaces the persisted client rate limit counters. They are written
+// locally and never committed.
+func (s *GitTokenStore) SaveRateLimits(_ context.Context, data []byte) error {
+	return s.writeLocal(gitRateLimitsFileName, data)
 }
 
-func deepEqualJSON(a, b any) bool {
-	switch valA := a.(type) {
-	case map[string]any:
-		valB, ok := b.(map[string]any)
-		if !ok || len(valA) != len(valB) {
-			return false
+// LoadVirtualKeys reads the persisted virtual client keys.
+func (s *GitTokenStore) LoadVirtualKeys(_ context.Context) ([]byte, error) {
+	return s.readSidecar(gitVirtualKeysFileName)
+}
+
+// SaveVirtualKeys replaces the persisted virtual client keys and commits them.
+func (s *GitTokenStore) SaveVirtualKeys(_ context.Context, data []byte) error {
+	return s.writeSidecar(gitVirtualKeysFileName, data, "Update virtual client keys")
+}
+
+// readSidecar returns the content of a sidecar file, or nil when it does not exist.
+func (s *GitTokenStore) readSidecar(name string) ([]byte, error) {
+	if err := s.EnsureRepository(); err != nil {
+		return nil, err
+	}
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return nil, fmt.Errorf("git token store: directory not configured")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
 		}
-		for key, subA := range valA {
-			subB, ok1 := valB[key]
-			if !ok1 || !deepEqualJSON(subA, subB) {
-				return false
+		return nil, fmt.Errorf("git token store: read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// writeSidecar replaces a sidecar file, removing it for empty data, and commits the change.
+func (s *GitTokenStore) writeSidecar(name string, data []byte, message string) error {
+	if err := s.EnsureRepository(); err != nil {
+		return err
+	}
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return fmt.Errorf("git token store: directory not configured")
+	}
+	path := filepath.Join(dir, name)
+	relPath, err := s.relativeToRepo(path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(data) == 0 {
+		if errRemove := os.Remove(path); errRemove != nil {
+			if errors.Is(errRemove, fs.ErrNotExist) {
+				return nil
 			}
+			return fmt.Errorf("git token store: delete %s: %w", name, errRemove)
 		}
-		return true
-	case []any:
-		sliceB, ok := b.([]any)
-		if !ok || len(valA) != len(sliceB) {
-			return false
+	} else {
+		tmp := path + ".tmp"
+		if errWrite := os.WriteFile(tmp, data, 0o600); errWrite != nil {
+			return fmt.Errorf("git token store: write %s: %w", name, errWrite)
 		}
-		for i := range valA {
-			if !deepEqualJSON(valA[i], sliceB[i]) {
-				return false
-			}
+		if errRename := os.Rename(tmp, path); errRename != nil {
+			return fmt.Errorf("git token store: rename %s: %w", name, errRename)
 		}
-		return true
-	case float64:
-		valB, ok := b.(float64)
-		if !ok {
-			return false
-		}
-		return valA == valB
-	case string:
-		valB, ok := b.(string)
-		if !ok {
-			return false
-		}
-		return valA == valB
-	case bool:
-		valB, ok := b.(bool)
-		if !ok {
-			return false
-		}
-		return valA == valB
-	case nil:
-		return b == nil
-	default:
-		return false
 	}
+	return s.commitAndPushLocked(message, relPath)
 }
@@ -17,6 +17,7 @@ import (
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypto"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -25,6 +26,9 @@ import (
 const (
 	objectStoreConfigKey  = "config/config.yaml"
 	objectStoreAuthPrefix = "auths"
+	objectStoreStateKey   = "state/auth-state.json"
+	objectStoreLimitsKey  = "state/rate-limits.json"
+	objectStoreKeysKey    = "state/virtual-keys.json"
 )
 
 // ObjectStoreConfig captures configuration for the object storage-backed token store.
@@ -184,7 +188,7 @@ func (s *ObjectTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (s
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = authcrypto.SaveStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -192,19 +196,8 @@ func (s *ObjectTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (s
 		if errMarshal != nil {
 			return "", fmt.Errorf("object store: marshal metadata: %w", errMarshal)
 		}
-		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
-			}
-		} else if errRead != nil && !errors.Is(errRead, fs.ErrNotExist) {
-			return "", fmt.Errorf("object store: read existing metadata: %w", errRead)
-		}
-		tmp := path + ".tmp"
-		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
-			return "", fmt.Errorf("object store: write temp auth file: %w", errWrite)
-		}
-		if errRename := os.Rename(tmp, path); errRename != nil {
-			return "", fmt.Errorf("object store: rename auth file: %w", errRename)
+		if errWrite := authcrypto.WriteFile(path, raw); errWrite != nil {
+			return "", fmt.Errorf("object store: write auth file: %w", errWrite)
 		}
 	default:
 		return "", fmt.Errorf("object store: nothing to persist for %s", auth.ID)
@@ -226,7 +219,7 @@ func (s *ObjectTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (s
 }
 
 // List enumerates auth JSON files from the mirrored workspace.
-func (s *ObjectTokenStore) List(_ context.Context) ([]*cliproxyauth.Auth, error) {
+func (s *ObjectTokenStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error) {
 	dir := strings.TrimSpace(s.AuthDir())
 	if dir == "" {
 		return nil, fmt.Errorf("object store: auth directory not configured")
@@ -242,11 +235,16 @@ func (s *ObjectTokenStore) List(_ context.Context) ([]*cliproxyauth.Auth, error)
 		if !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
 			return nil
 		}
-		auth, err := s.readAuthFile(path, dir)
+		auth, resealed, err := s.readAuthFile(path, dir)
 		if err != nil {
 			log.WithError(err).Warnf("object store: skip auth %s", path)
 			return nil
 		}
+		if resealed {
+			if errUpload := s.uploadAuth(ctx, path); errUpload != nil {
+				log.WithError(errUpload).Warnf("object store: upload re-encrypted auth %s", path)
+			}
+		}
 		if auth != nil {
 			entries = append(entries, auth)
 		}
@@ -324,6 +322,78 @@ func (s *ObjectTokenStore) PersistConfig(ctx context.Context) error {
 	return s.putObject(ctx, objectStoreConfigKey, data, "application/x-yaml")
 }
 
+// LoadState returns the persisted runtime state of the auths, keyed by auth ID.
+func (s *ObjectTokenStore) LoadState(ctx context.Context) (map[string]*cliproxyauth.AuthState, error) {
+	data, err := s.getObject(ctx, objectStoreStateKey)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	states := make(map[string]*cliproxyauth.AuthState)
+	if err = json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("object store: decode auth state: %w", err)
+	}
+	return states, nil
+}
+
+// SaveState replaces the persisted runtime state of the auths.
+func (s *ObjectTokenStore) SaveState(ctx context.Context, states map[string]*cliproxyauth.AuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(states) == 0 {
+		return s.deleteObject(ctx, objectStoreStateKey)
+	}
+	raw, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("object store: encode auth state: %w", err)
+	}
+	return s.putObject(ctx, objectStoreStateKey, raw, "application/json")
+}
+
+// LoadRateLimits returns the persisted client rate limit counters.
+func (s *ObjectTokenStore) LoadRateLimits(ctx context.Context) ([]byte, error) {
+	return s.getObject(ctx, objectStoreLimitsKey)
+}
+
+// SaveRateLimits replaces the persisted client rate limit counters.
+func (s *ObjectTokenStore) SaveRateLimits(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.putObject(ctx, objectStoreLimitsKey, data, "application/json")
+}
+
+// LoadVirtualKeys returns the persisted virtual client keys.
+func (s *ObjectTokenStore) LoadVirtualKeys(ctx context.Context) ([]byte, error) {
+	return s.getObject(ctx, objectStoreKeysKey)
+}
+
+// SaveVirtualKeys replaces the persisted virtual client keys.
+func (s *ObjectTokenStore) SaveVirtualKeys(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.putObject(ctx, objectStoreKeysKey, data, "application/json")
+}
+
+// getObject downloads an object, returning nil when it does not exist.
+func (s *ObjectTokenStore) getObject(ctx context.Context, key string) ([]byte, error) {
+	fullKey := s.prefixedKey(key)
+	reader, err := s.client.GetObject(ctx, s.cfg.Bucket, fullKey, minio.GetObjectOptions{})
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("object store: fetch object %s: %w", fullKey, err)
+	}
+	defer func() { _ = reader.Close() }()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("object store: read object %s: %w", fullKey, err)
+	}
+	return data, nil
+}
+
 func (s *ObjectTokenStore) ensureBucket(ctx context.Context) error {
 	exists, err := s.client.BucketExists(ctx, s.cfg.Bucket)
 	if err != nil {
@@ -552,17 +622,19 @@ func (s *ObjectTokenStore) resolveDeletePath(id string) (string, error) {
 	return filepath.Join(s.authDir, clean), nil
 }
 
-func (s *ObjectTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+// readAuthFile loads the auth at path; resealed reports whether the file was rewritten with
+// the primary encryption key and needs to be uploaded.
+func (s *ObjectTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, bool, error) {
+	data, resealed, err := authcrypto.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
+		return nil, false, fmt.Errorf("read file: %w", err)
 	}
 	if len(data) == 0 {
-		return nil, nil
+		return nil, resealed, nil
 	}
 	metadata := make(map[string]any)
 	if err = json.Unmarshal(data, &metadata); err != nil {
-		return nil, fmt.Errorf("unmarshal auth json: %w", err)
+		return nil, resealed, fmt.Errorf("unmarshal auth json: %w", err)
 	}
 	provider := strings.TrimSpace(valueAsString(metadata["type"]))
 	if provider == "" {
@@ -570,7 +642,7 @@ func (s *ObjectTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Aut
 	}
 	info, err := os.Stat(path)
 	if err != nil {
-		return nil, fmt.Errorf("stat auth file: %w", err)
+		return nil, resealed, fmt.Errorf("stat auth file: %w", err)
 	}
 	rel, errRel := filepath.Rel(baseDir, path)
 	if errRel != nil {
@@ -594,7 +666,7 @@ func (s *ObjectTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Aut
 		LastRefreshedAt:  time.Time{},
 		NextRefreshAfter: time.Time{},
 	}
-	return auth, nil
+	return auth, resealed, nil
 }
 
 func normalizeLineEndingsBytes(data []byte) []byte {
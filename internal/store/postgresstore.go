@@ -13,7 +13,9 @@ import (
 	"sync"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypto"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -22,7 +24,12 @@ import (
 const (
 	defaultConfigTable = "config_store"
 	defaultAuthTable   = "auth_store"
+	defaultStateTable  = "auth_state"
 	defaultConfigKey   = "config"
+	rateLimitsKey      = "rate-limits"
+	virtualKeysKey     = "virtual-keys"
+	// maxNotifyPayload keeps state notifications below the 8000 byte NOTIFY limit.
+	maxNotifyPayload = 7900
 )
 
 // PostgresStoreConfig captures configuration required to initialize a Postgres-backed store.
@@ -31,7 +38,11 @@ type PostgresStoreConfig struct {
 	Schema      string
 	ConfigTable string
 	AuthTable   string
+	StateTable  string
 	SpoolDir    string
+	// SharedState broadcasts cooldown and quota changes to every replica using the same
+	// database through LISTEN/NOTIFY.
+	SharedState bool
 }
 
 // PostgresStore persists configuration and authentication metadata using PostgreSQL as backend
@@ -43,6 +54,11 @@ type PostgresStore struct {
 	configPath string
 	authDir    string
 	mu         sync.Mutex
+	// instanceID tags state notifications so a replica ignores its own messages.
+	instanceID string
+	// stateIDs are the auth state rows this replica wrote in its last SaveState.
+	stateMu  sync.Mutex
+	stateIDs map[string]struct{}
 }
 
 // NewPostgresStore establishes a connection to PostgreSQL and prepares the local workspace.
@@ -58,6 +74,9 @@ func NewPostgresStore(ctx context.Context, cfg PostgresStoreConfig) (*PostgresSt
 	if cfg.AuthTable == "" {
 		cfg.AuthTable = defaultAuthTable
 	}
+	if cfg.StateTable == "" {
+		cfg.StateTable = defaultStateTable
+	}
 
 	spoolRoot := strings.TrimSpace(cfg.SpoolDir)
 	if spoolRoot == "" {
@@ -95,6 +114,7 @@ func NewPostgresStore(ctx context.Context, cfg PostgresStoreConfig) (*PostgresSt
 		spoolRoot:  absSpool,
 		configPath: filepath.Join(configDir, "config.yaml"),
 		authDir:    authDir,
+		instanceID: uuid.NewString(),
 	}
 	return store, nil
 }
@@ -140,6 +160,16 @@ func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
 	`, authTable)); err != nil {
 		return fmt.Errorf("postgres store: create auth table: %w", err)
 	}
+	stateTable := s.fullTableName(s.cfg.StateTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content JSONB NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, stateTable)); err != nil {
+		return fmt.Errorf("postgres store: create state table: %w", err)
+	}
 	return nil
 }
 
@@ -214,7 +244,7 @@ func (s *PostgresStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (stri
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = authcrypto.SaveStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -222,19 +252,8 @@ func (s *PostgresStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (stri
 		if errMarshal != nil {
 			return "", fmt.Errorf("postgres store: marshal metadata: %w", errMarshal)
 		}
-		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
-			}
-		} else if errRead != nil && !errors.Is(errRead, fs.ErrNotExist) {
-			return "", fmt.Errorf("postgres store: read existing metadata: %w", errRead)
-		}
-		tmp := path + ".tmp"
-		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
-			return "", fmt.Errorf("postgres store: write temp auth file: %w", errWrite)
-		}
-		if errRename := os.Rename(tmp, path); errRename != nil {
-			return "", fmt.Errorf("postgres store: rename auth file: %w", errRename)
+		if errWrite := authcrypto.WriteFile(path, raw); errWrite != nil {
+			return "", fmt.Errorf("postgres store: write auth file: %w", errWrite)
 		}
 	default:
 		return "", fmt.Errorf("postgres store: nothing to persist for %s", auth.ID)
@@ -284,8 +303,16 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 			log.WithError(errPath).Warnf("postgres store: skipping auth %s outside spool", id)
 			continue
 		}
+		plain, stale, errOpen := authcrypto.Open([]byte(payload))
+		if errOpen != nil {
+			log.WithError(errOpen).Warnf("postgres store: skipping auth %s that cannot be decrypted", id)
+			continue
+		}
+		if stale {
+			s.resealAuth(ctx, id, path, plain)
+		}
 		metadata := make(map[string]any)
-		if err = json.Unmarshal([]byte(payload), &metadata); err != nil {
+		if err = json.Unmarshal(plain, &metadata); err != nil {
 			log.WithError(err).Warnf("postgres store: skipping auth %s with invalid json", id)
 			continue
 		}
@@ -376,6 +403,232 @@ func (s *PostgresStore) PersistAuthFiles(ctx context.Context, _ string, paths ..
 	return nil
 }
 
+// LoadState returns the persisted runtime state of the auths, keyed by auth ID.
+func (s *PostgresStore) LoadState(ctx context.Context) (map[string]*cliproxyauth.AuthState, error) {
+	query := fmt.Sprintf("SELECT id, content FROM %s", s.fullTableName(s.cfg.StateTable))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("postgres store: load auth state: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[string]*cliproxyauth.AuthState)
+	for rows.Next() {
+		var (
+			id      string
+			payload string
+		)
+		if err = rows.Scan(&id, &payload); err != nil {
+			return nil, fmt.Errorf("postgres store: scan auth state: %w", err)
+		}
+		state := &cliproxyauth.AuthState{}
+		if err = json.Unmarshal([]byte(payload), state); err != nil {
+			log.WithError(err).Warnf("postgres store: skipping auth state %s with invalid json", id)
+			continue
+		}
+		states[id] = state
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres store: iterate auth state: %w", err)
+	}
+	return states, nil
+}
+
+// SaveState upserts the persisted runtime state of the auths. Each auth is stored in its
+// own row so other replicas sharing the database can read individual entries, and only
+// rows this replica wrote before are removed when their state clears.
+func (s *PostgresStore) SaveState(ctx context.Context, states map[string]*cliproxyauth.AuthState) error {
+	table := s.fullTableName(s.cfg.StateTable)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres store: begin state transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ids := make(map[string]struct{}, len(states))
+	upsert := fmt.Sprintf(`
+		INSERT INTO %s (id, content, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (id)
+		DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+	`, table)
+	for id, state := range states {
+		if state == nil {
+			continue
+		}
+		raw, errMarshal := json.Marshal(state)
+		if errMarshal != nil {
+			return fmt.Errorf("postgres store: encode auth state %s: %w", id, errMarshal)
+		}
+		if _, err = tx.ExecContext(ctx, upsert, id, string(raw)); err != nil {
+			return fmt.Errorf("postgres store: upsert auth state %s: %w", id, err)
+		}
+		ids[id] = struct{}{}
+	}
+	// Only rows this replica wrote are pruned; rows of other replicas sharing the table
+	// are left alone.
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	cleared := make([]string, 0)
+	for id := range s.stateIDs {
+		if _, ok := ids[id]; !ok {
+			cleared = append(cleared, id)
+		}
+	}
+	if len(cleared) > 0 {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1)", table), cleared); err != nil {
+			return fmt.Errorf("postgres store: prune auth state: %w", err)
+		}
+	}
+	// Rows left behind by replicas that went away hold long expired windows.
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE updated_at < NOW() - INTERVAL '7 days'", table)); err != nil {
+		return fmt.Errorf("postgres store: prune stale auth state: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("postgres store: commit auth state: %w", err)
+	}
+	s.stateIDs = ids
+	return nil
+}
+
+// LoadRateLimits returns the persisted client rate limit counters.
+func (s *PostgresStore) LoadRateLimits(ctx context.Context) ([]byte, error) {
+	return s.loadConfigRow(ctx, rateLimitsKey)
+}
+
+// SaveRateLimits replaces the persisted client rate limit counters.
+func (s *PostgresStore) SaveRateLimits(ctx context.Context, data []byte) error {
+	return s.saveConfigRow(ctx, rateLimitsKey, data)
+}
+
+// LoadVirtualKeys returns the persisted virtual client keys.
+func (s *PostgresStore) LoadVirtualKeys(ctx context.Context) ([]byte, error) {
+	return s.loadConfigRow(ctx, virtualKeysKey)
+}
+
+// SaveVirtualKeys replaces the persisted virtual client keys.
+func (s *PostgresStore) SaveVirtualKeys(ctx context.Context, data []byte) error {
+	return s.saveConfigRow(ctx, virtualKeysKey, data)
+}
+
+// loadConfigRow returns the content of a config table row, or nil when it does not exist.
+func (s *PostgresStore) loadConfigRow(ctx context.Context, id string) ([]byte, error) {
+	query := fmt.Sprintf("SELECT content FROM %s WHERE id = $1", s.fullTableName(s.cfg.ConfigTable))
+	var content string
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&content); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres store: load %s: %w", id, err)
+	}
+	return []byte(content), nil
+}
+
+// saveConfigRow upserts a config table row under its own id; empty data deletes it.
+func (s *PostgresStore) saveConfigRow(ctx context.Context, id string, data []byte) error {
+	table := s.fullTableName(s.cfg.ConfigTable)
+	if len(data) == 0 {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", table), id); err != nil {
+			return fmt.Errorf("postgres store: delete %s: %w", id, err)
+		}
+		return nil
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, content, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (id)
+		DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+	`, table)
+	if _, err := s.db.ExecContext(ctx, query, id, string(data)); err != nil {
+		return fmt.Errorf("postgres store: upsert %s: %w", id, err)
+	}
+	return nil
+}
+
+// stateNotification is the NOTIFY payload carrying state published by one replica.
+type stateNotification struct {
+	Origin string                  `json:"origin"`
+	ID     string                  `json:"id"`
+	State  *cliproxyauth.AuthState `json:"state"`
+}
+
+// StateSharingEnabled reports whether runtime state is broadcast to other replicas.
+func (s *PostgresStore) StateSharingEnabled() bool {
+	return s != nil && s.cfg.SharedState
+}
+
+// PublishState notifies the other replicas listening on the state channel.
+func (s *PostgresStore) PublishState(ctx context.Context, authID string, state *cliproxyauth.AuthState) error {
+	payload, err := json.Marshal(stateNotification{Origin: s.instanceID, ID: authID, State: state})
+	if err != nil {
+		return fmt.Errorf("postgres store: encode state notification: %w", err)
+	}
+	if len(payload) > maxNotifyPayload {
+		return fmt.Errorf("postgres store: state notification for %s exceeds %d bytes", authID, maxNotifyPayload)
+	}
+	if _, err = s.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", s.stateChannel(), string(payload)); err != nil {
+		return fmt.Errorf("postgres store: notify auth state: %w", err)
+	}
+	return nil
+}
+
+// ListenState holds a dedicated connection listening on the state channel and hands state
+// published by other replicas to handle until ctx is done or the connection fails.
+func (s *PostgresStore) ListenState(ctx context.Context, handle func(authID string, state *cliproxyauth.AuthState)) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres store: acquire listen connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("postgres store: unexpected driver connection %T", driverConn)
+		}
+		pgConn := stdConn.Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+quoteIdentifier(s.stateChannel())); err != nil {
+			return fmt.Errorf("postgres store: listen for auth state: %w", err)
+		}
+		defer func() {
+			unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, _ = pgConn.Exec(unlistenCtx, "UNLISTEN *")
+		}()
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("postgres store: wait for auth state: %w", err)
+			}
+			var msg stateNotification
+			if err = json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+				log.WithError(err).Warn("postgres store: skipping malformed state notification")
+				continue
+			}
+			if msg.Origin == s.instanceID || msg.ID == "" || msg.State == nil {
+				continue
+			}
+			handle(msg.ID, msg.State)
+		}
+	})
+}
+
+// stateChannel names the NOTIFY channel, scoped to the schema and state table so separate
+// deployments sharing a database do not hear each other.
+func (s *PostgresStore) stateChannel() string {
+	channel := s.cfg.StateTable
+	if schema := strings.TrimSpace(s.cfg.Schema); schema != "" {
+		channel = schema + "." + channel
+	}
+	if len(channel) > 63 {
+		channel = channel[:63]
+	}
+	return channel
+}
+
 // PersistConfig mirrors the local configuration file to PostgreSQL.
 func (s *PostgresStore) PersistConfig(ctx context.Context) error {
 	s.mu.Lock()
@@ -514,6 +767,23 @@ func (s *PostgresStore) persistAuth(ctx context.Context, relID string, data []by
 	return nil
 }
 
+// resealAuth stores a plaintext record, or one sealed with a previous key, under the primary
+// encryption key in the database and the local spool.
+func (s *PostgresStore) resealAuth(ctx context.Context, relID, path string, plain []byte) {
+	sealed, err := authcrypto.Seal(plain)
+	if err != nil {
+		log.WithError(err).Warnf("postgres store: re-encrypt auth %s", relID)
+		return
+	}
+	if err = s.persistAuth(ctx, relID, sealed); err != nil {
+		log.WithError(err).Warnf("postgres store: re-encrypt auth %s", relID)
+		return
+	}
+	if err = os.WriteFile(path, sealed, 0o600); err != nil {
+		log.WithError(err).Warnf("postgres store: write re-encrypted auth %s", path)
+	}
+}
+
 func (s *PostgresStore) deleteAuthRecord(ctx context.Context, relID string) error {
 	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.fullTableName(s.cfg.AuthTable))
 	if _, err := s.db.ExecContext(ctx, query, relID); err != nil {
@@ -0,0 +1,218 @@
+// Package transcription turns Whisper-style transcription requests into Gemini
+// generateContent requests carrying the audio inline, and renders the transcript the model
+// returns as json, text, srt or vtt.
+package transcription
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Response formats accepted in transcription requests.
+const (
+	FormatJSON        = "json"
+	FormatVerboseJSON = "verbose_json"
+	FormatText        = "text"
+	FormatSRT         = "srt"
+	FormatVTT         = "vtt"
+)
+
+// geminiAudioTypes maps the MIME types detected for common audio uploads to the names
+// Gemini accepts.
+var geminiAudioTypes = map[string]string{
+	"audio/mpeg":   "audio/mp3",
+	"audio/x-wav":  "audio/wav",
+	"audio/wave":   "audio/wav",
+	"audio/x-aac":  "audio/aac",
+	"audio/x-aiff": "audio/aiff",
+	"audio/x-flac": "audio/flac",
+	"video/webm":   "audio/webm",
+	"video/mp4":    "audio/mp4",
+}
+
+// segmentSchema asks the model for timestamped segments, which every format can be built from.
+var segmentSchema = map[string]any{
+	"type": "ARRAY",
+	"items": map[string]any{
+		"type": "OBJECT",
+		"properties": map[string]any{
+			"start": map[string]any{"type": "NUMBER", "description": "Segment start in seconds from the beginning of the audio."},
+			"end":   map[string]any{"type": "NUMBER", "description": "Segment end in seconds from the beginning of the audio."},
+			"text":  map[string]any{"type": "STRING"},
+		},
+		"required": []string{"start", "end", "text"},
+	},
+}
+
+// Request is a transcription request.
+type Request struct {
+	// Audio is the uploaded audio file and MimeType its detected type.
+	Audio    []byte
+	MimeType string
+	// Language is the optional ISO-639-1 language of the audio.
+	Language string
+	// Prompt is optional context such as spellings of names, as in the Whisper API.
+	Prompt string
+	// Instructions are extra operator instructions from the config.
+	Instructions string
+	// ResponseFormat is one of the Format constants; defaults to json.
+	ResponseFormat string
+	// Temperature is the sampling temperature, when set.
+	Temperature *float64
+}
+
+// NormalizeFormat validates a response_format value.
+func NormalizeFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatVerboseJSON, FormatText, FormatSRT, FormatVTT:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported response_format %q, expected json, text, srt, verbose_json or vtt", format)
+	}
+}
+
+// Gemini builds a generateContent request body transcribing the audio.
+func (r Request) Gemini() []byte {
+	instruction := "Transcribe the attached audio verbatim. Return the transcript as consecutive segments of " +
+		"one or two sentences with start and end times in seconds. Do not translate, summarize or add commentary."
+	if r.Language != "" {
+		instruction += " The audio is in language " + r.Language + "; write the transcript in that language."
+	}
+	if r.Prompt != "" {
+		instruction += " Context for names and spelling: " + r.Prompt
+	}
+	if r.Instructions != "" {
+		instruction += " " + r.Instructions
+	}
+	mimeType := r.MimeType
+	if mapped, ok := geminiAudioTypes[mimeType]; ok {
+		mimeType = mapped
+	}
+	generationConfig := map[string]any{
+		"responseMimeType": "application/json",
+		"responseSchema":   segmentSchema,
+	}
+	if r.Temperature != nil {
+		generationConfig["temperature"] = *r.Temperature
+	}
+	out, _ := json.Marshal(map[string]any{
+		"contents": []map[string]any{{
+			"role": "user",
+			"parts": []map[string]any{
+				{"inlineData": map[string]string{"mimeType": mimeType, "data": base64.StdEncoding.EncodeToString(r.Audio)}},
+				{"text": instruction},
+			},
+		}},
+		"generationConfig": generationConfig,
+	})
+	return out
+}
+
+// Segment is a timed piece of the transcript.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// ParseGemini returns the transcript segments of a generateContent response. A model that
+// ignored the schema yields its whole text as one untimed segment.
+func ParseGemini(raw []byte) []Segment {
+	var text strings.Builder
+	for _, part := range gjson.GetBytes(raw, "candidates.0.content.parts").Array() {
+		if !part.Get("thought").Bool() {
+			text.WriteString(part.Get("text").String())
+		}
+	}
+	out := strings.TrimSpace(text.String())
+	if out == "" {
+		return nil
+	}
+	var segments []Segment
+	if err := json.Unmarshal([]byte(stripCodeFence(out)), &segments); err != nil {
+		return []Segment{{Text: out}}
+	}
+	kept := segments[:0]
+	for _, segment := range segments {
+		if segment.Text = strings.TrimSpace(segment.Text); segment.Text != "" {
+			kept = append(kept, segment)
+		}
+	}
+	return kept
+}
+
+func stripCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+// Render formats segments in the response format and returns the matching content type.
+func Render(format, language string, segments []Segment) (string, []byte) {
+	texts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		texts = append(texts, segment.Text)
+	}
+	text := strings.Join(texts, " ")
+	switch format {
+	case FormatText:
+		return "text/plain; charset=utf-8", []byte(text + "\n")
+	case FormatSRT:
+		var b strings.Builder
+		for i, segment := range segments {
+			fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(segment.Start, ","), timestamp(segment.End, ","), segment.Text)
+		}
+		return "text/plain; charset=utf-8", []byte(b.String())
+	case FormatVTT:
+		var b strings.Builder
+		b.WriteString("WEBVTT\n\n")
+		for _, segment := range segments {
+			fmt.Fprintf(&b, "%s --> %s\n%s\n\n", timestamp(segment.Start, "."), timestamp(segment.End, "."), segment.Text)
+		}
+		return "text/plain; charset=utf-8", []byte(b.String())
+	case FormatVerboseJSON:
+		type verboseSegment struct {
+			ID int `json:"id"`
+			Segment
+		}
+		items := make([]verboseSegment, 0, len(segments))
+		var duration float64
+		for i, segment := range segments {
+			items = append(items, verboseSegment{ID: i, Segment: segment})
+			duration = math.Max(duration, segment.End)
+		}
+		out, _ := json.Marshal(map[string]any{
+			"task":     "transcribe",
+			"language": language,
+			"duration": duration,
+			"text":     text,
+			"segments": items,
+		})
+		return "application/json", out
+	default:
+		out, _ := json.Marshal(map[string]string{"text": text})
+		return "application/json", out
+	}
+}
+
+// timestamp formats seconds as HH:MM:SS followed by sep and milliseconds.
+func timestamp(seconds float64, sep string) string {
+	if seconds < 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		seconds = 0
+	}
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
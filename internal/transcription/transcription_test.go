@@ -0,0 +1,53 @@
+package transcription
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestGeminiRequestCarriesAudioInline(t *testing.T) {
+	body := Request{Audio: []byte("RIFF"), MimeType: "audio/x-wav", Language: "de"}.Gemini()
+	if got := gjson.GetBytes(body, "contents.0.parts.0.inlineData.mimeType").String(); got != "audio/wav" {
+		t.Fatalf("expected Gemini audio type, got %q", got)
+	}
+	if !strings.Contains(gjson.GetBytes(body, "contents.0.parts.1.text").String(), "language de") {
+		t.Fatalf("expected language in instruction, got %s", body)
+	}
+	if gjson.GetBytes(body, "generationConfig.responseSchema.type").String() != "ARRAY" {
+		t.Fatalf("expected segment schema, got %s", body)
+	}
+}
+
+func TestRenderFormats(t *testing.T) {
+	segments := ParseGemini([]byte(`{"candidates":[{"content":{"parts":[{"text":"[{\"start\":0,\"end\":1.5,\"text\":\"Hello.\"},{\"start\":1.5,\"end\":3723.25,\"text\":\"World.\"}]"}]}}]}`))
+	if len(segments) != 2 {
+		t.Fatalf("expected two segments, got %+v", segments)
+	}
+
+	_, out := Render(FormatJSON, "", segments)
+	if gjson.GetBytes(out, "text").String() != "Hello. World." {
+		t.Fatalf("unexpected json %s", out)
+	}
+	_, out = Render(FormatSRT, "", segments)
+	if !strings.Contains(string(out), "2\n00:00:01,500 --> 01:02:03,250\nWorld.") {
+		t.Fatalf("unexpected srt %q", out)
+	}
+	_, out = Render(FormatVTT, "", segments)
+	if !strings.HasPrefix(string(out), "WEBVTT\n\n00:00:00.000 --> 00:00:01.500\nHello.") {
+		t.Fatalf("unexpected vtt %q", out)
+	}
+	contentType, out := Render(FormatText, "", segments)
+	if contentType != "text/plain; charset=utf-8" || string(out) != "Hello. World.\n" {
+		t.Fatalf("unexpected text %q %q", contentType, out)
+	}
+
+	untimed := ParseGemini([]byte(`{"candidates":[{"content":{"parts":[{"text":"just text"}]}}]}`))
+	if len(untimed) != 1 || untimed[0].Text != "just text" {
+		t.Fatalf("expected plain text fallback, got %+v", untimed)
+	}
+	if _, err := NormalizeFormat("mp3"); err == nil {
+		t.Fatal("expected unknown format to be rejected")
+	}
+}
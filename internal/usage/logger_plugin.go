@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 )
 
@@ -64,6 +65,16 @@ type RequestStatistics struct {
 	failureCount  int64
 	totalTokens   int64
 
+	affinityHits   int64
+	affinityMisses int64
+
+	hedgedRequests int64
+	hedgeWins      int64
+	hedgeCanceled  int64
+
+	queuedRequests int64
+	queueWait      time.Duration
+
 	apis map[string]*apiStats
 
 	requestsByDay  map[string]int64
@@ -93,6 +104,9 @@ type RequestDetail struct {
 	AuthIndex uint64     `json:"auth_index"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
+	Affinity  string     `json:"affinity,omitempty"`
+	Hedge     string     `json:"hedge,omitempty"`
+	QueueWait float64    `json:"queue_wait_ms,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -111,6 +125,16 @@ type StatisticsSnapshot struct {
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
 
+	AffinityHits   int64 `json:"affinity_hits"`
+	AffinityMisses int64 `json:"affinity_misses"`
+
+	HedgedRequests int64 `json:"hedged_requests"`
+	HedgeWins      int64 `json:"hedge_wins"`
+	HedgeCanceled  int64 `json:"hedge_canceled"`
+
+	QueuedRequests int64   `json:"queued_requests"`
+	QueueWaitMS    float64 `json:"queue_wait_ms"`
+
 	APIs map[string]APISnapshot `json:"apis"`
 
 	RequestsByDay  map[string]int64 `json:"requests_by_day"`
@@ -182,13 +206,37 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if record.Hedge == coreauth.HedgeSecondary {
+		s.hedgedRequests++
+		if success && !record.Canceled {
+			s.hedgeWins++
+		}
+	}
+	if record.Canceled {
+		// The losing side of a hedge race is not a request the client saw, but the tokens
+		// it consumed were still billed upstream.
+		s.hedgeCanceled++
+		s.addTokens(statsKey, modelName, dayKey, hourKey, totalTokens)
+		return
+	}
+
 	s.totalRequests++
+	if record.QueueWait > 0 {
+		s.queuedRequests++
+		s.queueWait += record.QueueWait
+	}
 	if success {
 		s.successCount++
 	} else {
 		s.failureCount++
 	}
 	s.totalTokens += totalTokens
+	switch record.Affinity {
+	case coreauth.AffinityHit:
+		s.affinityHits++
+	case coreauth.AffinityMiss:
+		s.affinityMisses++
+	}
 
 	stats, ok := s.apis[statsKey]
 	if !ok {
@@ -201,6 +249,9 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		AuthIndex: record.AuthIndex,
 		Tokens:    detail,
 		Failed:    failed,
+		Affinity:  record.Affinity,
+		Hedge:     record.Hedge,
+		QueueWait: float64(record.QueueWait) / float64(time.Millisecond),
 	})
 
 	s.requestsByDay[dayKey]++
@@ -209,6 +260,28 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	s.tokensByHour[hourKey] += totalTokens
 }
 
+// addTokens records tokens without counting a request. s.mu must be held.
+func (s *RequestStatistics) addTokens(statsKey, model, dayKey string, hourKey int, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	s.totalTokens += tokens
+	s.tokensByDay[dayKey] += tokens
+	s.tokensByHour[hourKey] += tokens
+	stats, ok := s.apis[statsKey]
+	if !ok {
+		stats = &apiStats{Models: make(map[string]*modelStats)}
+		s.apis[statsKey] = stats
+	}
+	stats.TotalTokens += tokens
+	modelStatsValue, ok := stats.Models[model]
+	if !ok {
+		modelStatsValue = &modelStats{}
+		stats.Models[model] = modelStatsValue
+	}
+	modelStatsValue.TotalTokens += tokens
+}
+
 func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
 	stats.TotalRequests++
 	stats.TotalTokens += detail.Tokens.TotalTokens
@@ -236,6 +309,13 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
 	result.TotalTokens = s.totalTokens
+	result.AffinityHits = s.affinityHits
+	result.AffinityMisses = s.affinityMisses
+	result.HedgedRequests = s.hedgedRequests
+	result.HedgeWins = s.hedgeWins
+	result.HedgeCanceled = s.hedgeCanceled
+	result.QueuedRequests = s.queuedRequests
+	result.QueueWaitMS = float64(s.queueWait) / float64(time.Millisecond)
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
@@ -15,11 +15,13 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypto"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/geminicli"
 	"gopkg.in/yaml.v3"
@@ -798,6 +800,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 				attrs["base_url"] = base
 			}
 			addConfigHeadersToAttrs(entry.Headers, attrs)
+			addRoutingAttrs(entry.Weight, entry.Priority, entry.MaxConcurrency, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
 				Provider:   "gemini",
@@ -830,6 +833,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 				attrs["models_hash"] = hash
 			}
 			addConfigHeadersToAttrs(ck.Headers, attrs)
+			addRoutingAttrs(ck.Weight, ck.Priority, ck.MaxConcurrency, attrs)
 			proxyURL := strings.TrimSpace(ck.ProxyURL)
 			a := &coreauth.Auth{
 				ID:         id,
@@ -859,6 +863,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 				attrs["base_url"] = ck.BaseURL
 			}
 			addConfigHeadersToAttrs(ck.Headers, attrs)
+			addRoutingAttrs(ck.Weight, ck.Priority, ck.MaxConcurrency, attrs)
 			proxyURL := strings.TrimSpace(ck.ProxyURL)
 			a := &coreauth.Auth{
 				ID:         id,
@@ -902,6 +907,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 						attrs["models_hash"] = hash
 					}
 					addConfigHeadersToAttrs(compat.Headers, attrs)
+					addRoutingAttrs(entry.Weight, entry.Priority, entry.MaxConcurrency, attrs)
 					a := &coreauth.Auth{
 						ID:         id,
 						Provider:   providerName,
@@ -989,6 +995,10 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 		if err != nil || len(data) == 0 {
 			continue
 		}
+		if data, _, err = authcrypto.Open(data); err != nil {
+			log.Warnf("failed to decrypt auth file %s: %v", name, err)
+			continue
+		}
 		var metadata map[string]any
 		if err = json.Unmarshal(data, &metadata); err != nil {
 			continue
@@ -1366,6 +1376,18 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.LoggingToFile != newCfg.LoggingToFile {
 		changes = append(changes, fmt.Sprintf("logging-to-file: %t -> %t", oldCfg.LoggingToFile, newCfg.LoggingToFile))
 	}
+	if oldCfg.TLS != newCfg.TLS {
+		changes = append(changes, "tls: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.MTLSAuth, newCfg.MTLSAuth) {
+		changes = append(changes, "mtls-auth: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.JWTAuth, newCfg.JWTAuth) {
+		changes = append(changes, "jwt-auth: updated")
+	}
+	if oldCfg.VirtualKeys != newCfg.VirtualKeys {
+		changes = append(changes, fmt.Sprintf("virtual-keys: %t -> %t", oldCfg.VirtualKeys, newCfg.VirtualKeys))
+	}
 	if oldCfg.UsageStatisticsEnabled != newCfg.UsageStatisticsEnabled {
 		changes = append(changes, fmt.Sprintf("usage-statistics-enabled: %t -> %t", oldCfg.UsageStatisticsEnabled, newCfg.UsageStatisticsEnabled))
 	}
@@ -1378,6 +1400,30 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.RequestRetry != newCfg.RequestRetry {
 		changes = append(changes, fmt.Sprintf("request-retry: %d -> %d", oldCfg.RequestRetry, newCfg.RequestRetry))
 	}
+	if !reflect.DeepEqual(oldCfg.RetryPolicy, newCfg.RetryPolicy) {
+		changes = append(changes, "retry-policy: updated")
+	}
+	if oldCfg.StreamTTFTTimeoutSeconds != newCfg.StreamTTFTTimeoutSeconds {
+		changes = append(changes, fmt.Sprintf("stream-ttft-timeout-seconds: %d -> %d", oldCfg.StreamTTFTTimeoutSeconds, newCfg.StreamTTFTTimeoutSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.Concurrency, newCfg.Concurrency) {
+		changes = append(changes, "concurrency: updated")
+	}
+	if oldCfg.Hedging != newCfg.Hedging {
+		changes = append(changes, "hedging: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.ModelFallbacks, newCfg.ModelFallbacks) {
+		changes = append(changes, fmt.Sprintf("model-fallbacks: %d -> %d chains", len(oldCfg.ModelFallbacks), len(newCfg.ModelFallbacks)))
+	}
+	if oldCfg.Routing.Strategy != newCfg.Routing.Strategy {
+		changes = append(changes, fmt.Sprintf("routing.strategy: %s -> %s", oldCfg.Routing.Strategy, newCfg.Routing.Strategy))
+	}
+	if !reflect.DeepEqual(oldCfg.Routing.Providers, newCfg.Routing.Providers) {
+		changes = append(changes, "routing.providers: updated")
+	}
+	if oldCfg.Routing.SessionAffinity != newCfg.Routing.SessionAffinity {
+		changes = append(changes, "routing.session-affinity: updated")
+	}
 	if oldCfg.ProxyURL != newCfg.ProxyURL {
 		changes = append(changes, fmt.Sprintf("proxy-url: %s -> %s", oldCfg.ProxyURL, newCfg.ProxyURL))
 	}
@@ -1399,6 +1445,11 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if len(oldCfg.APIKeyPolicies) != len(newCfg.APIKeyPolicies) {
+		changes = append(changes, fmt.Sprintf("api-key-policies count: %d -> %d", len(oldCfg.APIKeyPolicies), len(newCfg.APIKeyPolicies)))
+	} else if !reflect.DeepEqual(oldCfg.APIKeyPolicies, newCfg.APIKeyPolicies) {
+		changes = append(changes, "api-key-policies: updated (count unchanged)")
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -1493,6 +1544,39 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			changes = append(changes, "remote-management.secret-key: updated")
 		}
 	}
+	if !reflect.DeepEqual(oldCfg.RemoteManagement.Keys, newCfg.RemoteManagement.Keys) {
+		changes = append(changes, fmt.Sprintf("remote-management.keys: %d -> %d entries", len(oldCfg.RemoteManagement.Keys), len(newCfg.RemoteManagement.Keys)))
+	}
+
+	if !reflect.DeepEqual(oldCfg.Guardrails, newCfg.Guardrails) {
+		changes = append(changes, fmt.Sprintf("guardrails: %d -> %d rules", len(oldCfg.Guardrails), len(newCfg.Guardrails)))
+	}
+	if oldCfg.Transcription.Model != newCfg.Transcription.Model {
+		changes = append(changes, fmt.Sprintf("transcription.model: %s -> %s", oldCfg.Transcription.Model, newCfg.Transcription.Model))
+	}
+	if oldCfg.Transcription.Instructions != newCfg.Transcription.Instructions {
+		changes = append(changes, "transcription.instructions: updated")
+	}
+	if oldCfg.Batches.Dir != newCfg.Batches.Dir {
+		changes = append(changes, fmt.Sprintf("batches.dir: %s -> %s", oldCfg.Batches.Dir, newCfg.Batches.Dir))
+	}
+	if oldCfg.Batches.MaxConcurrency != newCfg.Batches.MaxConcurrency {
+		changes = append(changes, fmt.Sprintf("batches.max-concurrency: %d -> %d", oldCfg.Batches.MaxConcurrency, newCfg.Batches.MaxConcurrency))
+	}
+	if oldCfg.Batches.MaxFileSizeMB != newCfg.Batches.MaxFileSizeMB {
+		changes = append(changes, fmt.Sprintf("batches.max-file-size-mb: %d -> %d", oldCfg.Batches.MaxFileSizeMB, newCfg.Batches.MaxFileSizeMB))
+	}
+
+	// CORS policies
+	if !reflect.DeepEqual(oldCfg.CORS.API, newCfg.CORS.API) {
+		changes = append(changes, fmt.Sprintf("cors.api.allowed-origins: %v -> %v", oldCfg.CORS.API.AllowedOrigins, newCfg.CORS.API.AllowedOrigins))
+	}
+	if !reflect.DeepEqual(oldCfg.CORS.Management, newCfg.CORS.Management) {
+		changes = append(changes, fmt.Sprintf("cors.management.allowed-origins: %v -> %v", oldCfg.CORS.Management.AllowedOrigins, newCfg.CORS.Management.AllowedOrigins))
+	}
+	if !reflect.DeepEqual(oldCfg.CORS.Websocket, newCfg.CORS.Websocket) {
+		changes = append(changes, fmt.Sprintf("cors.websocket.allowed-origins: %v -> %v", oldCfg.CORS.Websocket.AllowedOrigins, newCfg.CORS.Websocket.AllowedOrigins))
+	}
 
 	// OpenAI compatibility providers (summarized)
 	if compat := diffOpenAICompatibility(oldCfg.OpenAICompatibility, newCfg.OpenAICompatibility); len(compat) > 0 {
@@ -1519,6 +1603,23 @@ func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string)
 	}
 }
 
+// addRoutingAttrs records per-key routing hints: the weight and priority read by the weighted
+// and priority strategies, and the max_concurrency cap enforced by the concurrency limiter.
+func addRoutingAttrs(weight, priority, maxConcurrency int, attrs map[string]string) {
+	if attrs == nil {
+		return
+	}
+	if maxConcurrency > 0 {
+		attrs[coreauth.AttributeMaxConcurrency] = strconv.Itoa(maxConcurrency)
+	}
+	if weight != 0 {
+		attrs[coreauth.AttributeWeight] = strconv.Itoa(weight)
+	}
+	if priority != 0 {
+		attrs[coreauth.AttributePriority] = strconv.Itoa(priority)
+	}
+}
+
 func trimStrings(in []string) []string {
 	out := make([]string, len(in))
 	for i := range in {
@@ -34,11 +34,15 @@ type Manager struct {
 type Options struct {
 	Path            string
 	ProviderFactory func(*http.Request) (string, error)
-	OnConnected     func(string)
-	OnDisconnected  func(string, error)
-	LogDebugf       func(string, ...any)
-	LogInfof        func(string, ...any)
-	LogWarnf        func(string, ...any)
+	// CheckOrigin decides whether a browser origin may open a session. It is consulted on
+	// every upgrade, so it may read configuration that changes at runtime. When nil, only
+	// same-origin browsers and clients without an Origin header are accepted.
+	CheckOrigin    func(*http.Request) bool
+	OnConnected    func(string)
+	OnDisconnected func(string, error)
+	LogDebugf      func(string, ...any)
+	LogInfof       func(string, ...any)
+	LogWarnf       func(string, ...any)
 }
 
 // NewManager builds a websocket relay manager with the supplied options.
@@ -56,9 +60,7 @@ func NewManager(opts Options) *Manager {
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
+			CheckOrigin:     opts.CheckOrigin,
 		},
 		providerFactory: opts.ProviderFactory,
 		onConnected:     opts.OnConnected,
@@ -83,5 +83,12 @@ func BuildProviders(root *config.SDKConfig) ([]Provider, error) {
 			providers = append(providers, provider)
 		}
 	}
+	for _, runtimeCfg := range root.RuntimeAccessProviders() {
+		provider, err := BuildProvider(runtimeCfg, root)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
 	return providers, nil
 }
@@ -81,7 +81,6 @@ func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
 				"generateContent",
 				"countTokens",
 				"createCachedContent",
-				"batchGenerateContent",
 			},
 			"temperature":    1,
 			"topP":           0.95,
@@ -102,7 +101,6 @@ func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
 				"generateContent",
 				"countTokens",
 				"createCachedContent",
-				"batchGenerateContent",
 			},
 			"temperature":    1,
 			"topP":           0.95,
@@ -154,12 +152,7 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 	}
 	action := strings.Split(request.Action, ":")
 	if len(action) != 2 {
-		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
-			Error: handlers.ErrorDetail{
-				Message: fmt.Sprintf("%s not found.", c.Request.URL.Path),
-				Type:    "invalid_request_error",
-			},
-		})
+		h.WriteErrorResponse(c, handlers.DialectError(h.HandlerType(), http.StatusNotFound, "not_found", fmt.Sprintf("%s not found.", c.Request.URL.Path)))
 		return
 	}
 
@@ -173,9 +166,37 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	case "embedContent", "batchEmbedContents":
+		h.handleEmbedContent(c, action[0], rawJSON)
+	case "batchGenerateContent", "asyncBatchEmbedContent", "countTextTokens", "generateAnswer":
+		// Known Gemini methods the proxy cannot serve through the executors.
+		h.WriteErrorResponse(c, handlers.DialectError(h.HandlerType(), http.StatusNotImplemented, "not_supported", fmt.Sprintf("Method %s is not supported by this proxy.", method)))
+	default:
+		h.WriteErrorResponse(c, handlers.DialectError(h.HandlerType(), http.StatusNotFound, "not_found", fmt.Sprintf("%s not found.", c.Request.URL.Path)))
 	}
 }
 
+// handleEmbedContent handles embedContent and batchEmbedContents requests for Gemini
+// embedding models. The request and response keep the Gemini format of the method called.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The name of the embedding model
+//   - rawJSON: The raw JSON request body containing the content to embed
+func (h *GeminiAPIHandler) handleEmbedContent(c *gin.Context, modelName string, rawJSON []byte) {
+	c.Header("Content-Type", "application/json")
+	alt := h.GetAlt(c)
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbedWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
 // handleStreamGenerateContent handles streaming content generation requests for Gemini models.
 // This function establishes a Server-Sent Events connection and streams the generated content
 // back to the client in real-time. It supports both SSE format and direct streaming based
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -138,10 +139,16 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
+	if rawJSON, errMsg = applyGuardrails(ctx, handlerType, modelName, normalizedModel, rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	if errMsg = h.enforceRateLimit(ctx, handlerType); errMsg != nil {
+		return nil, errMsg
+	}
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -158,7 +165,7 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
-	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	resp, err := h.AuthManager.Execute(withServedModelHeaders(h.withRequestPolicy(ctx)), providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -180,10 +187,16 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
+	if rawJSON, errMsg = applyGuardrails(ctx, handlerType, modelName, normalizedModel, rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	if errMsg = h.enforceRateLimit(ctx, handlerType); errMsg != nil {
+		return nil, errMsg
+	}
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -200,7 +213,54 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
-	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	resp, err := h.AuthManager.ExecuteCount(h.withRequestPolicy(ctx), providers, req, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
+			if code := se.StatusCode(); code > 0 {
+				status = code
+			}
+		}
+		var addon http.Header
+		if he, ok := err.(interface{ Headers() http.Header }); ok && he != nil {
+			if hdr := he.Headers(); hdr != nil {
+				addon = hdr.Clone()
+			}
+		}
+		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+	}
+	return cloneBytes(resp.Payload), nil
+}
+
+// ExecuteEmbedWithAuthManager computes embeddings via the core auth manager. rawJSON is an
+// embedding request in the handler's format and the response uses the same format.
+func (h *BaseAPIHandler) ExecuteEmbedWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if info := registry.GetGlobalRegistry().GetModelInfo(normalizedModel); info != nil && !info.IsEmbedding() {
+		return nil, dialectError(handlerType, http.StatusBadRequest, "invalid_model", fmt.Sprintf("model %s does not support embeddings", modelName))
+	}
+	if rawJSON, errMsg = applyGuardrails(ctx, handlerType, modelName, normalizedModel, rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	if errMsg = h.enforceRateLimit(ctx, handlerType); errMsg != nil {
+		return nil, errMsg
+	}
+	req := coreexecutor.Request{
+		Model:   normalizedModel,
+		Payload: cloneBytes(rawJSON),
+	}
+	if cloned := cloneMetadata(metadata); cloned != nil {
+		req.Metadata = cloned
+	}
+	opts := coreexecutor.Options{
+		Alt:             alt,
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+	}
+	resp, err := h.AuthManager.ExecuteEmbed(withServedModelHeaders(h.withRequestPolicy(ctx)), providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -222,7 +282,13 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, handlerType, modelName)
+	if errMsg == nil {
+		rawJSON, errMsg = applyGuardrails(ctx, handlerType, modelName, normalizedModel, rawJSON)
+	}
+	if errMsg == nil {
+		errMsg = h.enforceRateLimit(ctx, handlerType)
+	}
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
@@ -245,7 +311,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	chunks, err := h.AuthManager.ExecuteStream(withServedModelHeaders(h.withRequestPolicy(ctx)), providers, req, opts)
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
@@ -294,10 +360,10 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
-func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+func (h *BaseAPIHandler) getRequestDetails(ctx context.Context, handlerType, modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
 	// Resolve "auto" model to an actual available model first
 	resolvedModelName := util.ResolveAutoModel(modelName)
-	
+
 	providerName, extractedModelName, isDynamic := h.parseDynamicModel(resolvedModelName)
 
 	// First, normalize the model name to handle suffixes like "-thinking-128"
@@ -314,10 +380,24 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 		providers = util.GetProviderName(normalizedModel)
 	}
 
+	// Client keys with an access policy may only reach the models and providers it allows.
+	policy := h.clientPolicy(ctx)
+	if policy != nil {
+		if err = checkPolicyModel(policy, handlerType, modelName, normalizedModel); err != nil {
+			return nil, "", nil, err
+		}
+	}
+
 	if len(providers) == 0 {
 		return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
 	}
 
+	if policy != nil {
+		if providers, err = filterPolicyProviders(policy, handlerType, modelName, providers); err != nil {
+			return nil, "", nil, err
+		}
+	}
+
 	// If it's a dynamic model, the normalizedModel was already set to extractedModelName.
 	// If it's a non-dynamic model, normalizedModel was set by normalizeModelMetadata.
 	// So, normalizedModel is already correctly set at this point.
@@ -349,6 +429,19 @@ func (h *BaseAPIHandler) parseDynamicModel(modelName string) (providerName, mode
 	return "", modelName, false
 }
 
+// withServedModelHeaders reports the provider and model that answered through response
+// headers, so clients can tell when a model fallback served the request.
+func withServedModelHeaders(ctx context.Context) context.Context {
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil {
+		return ctx
+	}
+	return coreauth.WithServedModelObserver(ctx, func(provider, model string) {
+		c.Header("X-CPA-MODEL", model)
+		c.Header("X-CPA-PROVIDER", provider)
+	})
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
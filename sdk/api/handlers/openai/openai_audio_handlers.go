@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcription"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// AudioTranscriptions handles the multipart /v1/audio/transcriptions endpoint.
+// The audio is sent inline to a multimodal model with a transcription instruction, and the
+// transcript is returned in the requested response_format.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) AudioTranscriptions(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		writeInvalidRequest(c, fmt.Errorf("file is required"))
+		return
+	}
+	format, err := transcription.NormalizeFormat(c.PostForm("response_format"))
+	if err != nil {
+		writeInvalidRequest(c, err)
+		return
+	}
+	mimeType, data, err := readUpload(file)
+	if err != nil {
+		writeInvalidRequest(c, err)
+		return
+	}
+	if !strings.HasPrefix(mimeType, "audio/") && !strings.HasPrefix(mimeType, "video/") {
+		writeInvalidRequest(c, fmt.Errorf("%s is not an audio file (%s)", file.Filename, mimeType))
+		return
+	}
+	var settings config.TranscriptionConfig
+	if h.Cfg != nil {
+		settings = h.Cfg.Transcription
+	}
+	req := transcription.Request{
+		Audio:          data,
+		MimeType:       mimeType,
+		Language:       strings.TrimSpace(c.PostForm("language")),
+		Prompt:         strings.TrimSpace(c.PostForm("prompt")),
+		Instructions:   strings.TrimSpace(settings.Instructions),
+		ResponseFormat: format,
+	}
+	if value := c.PostForm("temperature"); value != "" {
+		temperature, errParse := strconv.ParseFloat(value, 64)
+		if errParse != nil {
+			writeInvalidRequest(c, fmt.Errorf("temperature must be a number"))
+			return
+		}
+		req.Temperature = &temperature
+	}
+
+	modelName := transcriptionModel(c.PostForm("model"), settings)
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, Gemini, modelName, req.Gemini(), "")
+	if errMsg != nil {
+		errMsg = h.openAIError(errMsg)
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	segments := transcription.ParseGemini(resp)
+	if len(segments) == 0 {
+		errMsg = handlers.DialectError(h.HandlerType(), http.StatusBadGateway, "empty_transcript", "model "+modelName+" returned no transcript")
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	contentType, body := transcription.Render(format, req.Language, segments)
+	c.Data(http.StatusOK, contentType, body)
+	cliCancel()
+}
+
+// transcriptionModel returns the requested model when the proxy serves it, and otherwise the
+// configured transcription model, so Whisper model names keep working.
+func transcriptionModel(requested string, settings config.TranscriptionConfig) string {
+	requested = strings.TrimSpace(requested)
+	if requested != "" && registry.GetGlobalRegistry().GetModelInfo(requested) != nil {
+		return requested
+	}
+	if model := strings.TrimSpace(settings.Model); model != "" {
+		return model
+	}
+	return config.DefaultTranscriptionModel
+}
@@ -0,0 +1,314 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	virtualkeys "github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtual_keys"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batches"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// OpenAIBatchAPIHandler serves the OpenAI Files and Batch APIs. Batches are run by the proxy
+// itself through the same execution path as the synchronous endpoints.
+type OpenAIBatchAPIHandler struct {
+	*handlers.BaseAPIHandler
+	manager *batches.Manager
+}
+
+// NewOpenAIBatchAPIHandler creates a Files and Batch API handler backed by manager.
+// Call Start to resume the unfinished batches.
+//
+// Parameters:
+//   - apiHandlers: The base API handlers instance
+//   - manager: The batch manager storing files and batches
+//
+// Returns:
+//   - *OpenAIBatchAPIHandler: A new Files and Batch API handler
+func NewOpenAIBatchAPIHandler(apiHandlers *handlers.BaseAPIHandler, manager *batches.Manager) *OpenAIBatchAPIHandler {
+	return &OpenAIBatchAPIHandler{BaseAPIHandler: apiHandlers, manager: manager}
+}
+
+// HandlerType returns the identifier for this handler implementation.
+func (h *OpenAIBatchAPIHandler) HandlerType() string {
+	return OpenAI
+}
+
+// Models returns the OpenAI-compatible model metadata; batches use the models of their endpoint.
+func (h *OpenAIBatchAPIHandler) Models() []map[string]any {
+	return nil
+}
+
+// Start loads the stored batches and resumes the unfinished ones.
+func (h *OpenAIBatchAPIHandler) Start() error {
+	return h.manager.Start(h.executeBatchRequest)
+}
+
+// UploadFile handles POST /v1/files. Only batch input files are accepted.
+func (h *OpenAIBatchAPIHandler) UploadFile(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		writeInvalidRequest(c, fmt.Errorf("file is required"))
+		return
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		writeInvalidRequest(c, err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	file, err := h.manager.CreateFile(h.batchOwner(c), fileHeader.Filename, c.PostForm("purpose"), f)
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// ListFiles handles GET /v1/files.
+func (h *OpenAIBatchAPIHandler) ListFiles(c *gin.Context) {
+	files := h.manager.ListFiles(h.batchOwner(c), c.Query("purpose"))
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": files, "has_more": false})
+}
+
+// GetFile handles GET /v1/files/:id.
+func (h *OpenAIBatchAPIHandler) GetFile(c *gin.Context) {
+	file, err := h.manager.GetFile(h.batchOwner(c), c.Param("id"))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// GetFileContent handles GET /v1/files/:id/content.
+func (h *OpenAIBatchAPIHandler) GetFileContent(c *gin.Context) {
+	file, content, err := h.manager.OpenFile(h.batchOwner(c), c.Param("id"))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	defer func() { _ = content.Close() }()
+	c.DataFromReader(http.StatusOK, file.Bytes, "application/jsonl", content, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", file.Filename),
+	})
+}
+
+// DeleteFile handles DELETE /v1/files/:id.
+func (h *OpenAIBatchAPIHandler) DeleteFile(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.manager.DeleteFile(h.batchOwner(c), id); err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+}
+
+// CreateBatch handles POST /v1/batches.
+func (h *OpenAIBatchAPIHandler) CreateBatch(c *gin.Context) {
+	var params batches.CreateParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		writeInvalidRequest(c, err)
+		return
+	}
+	batch, err := h.manager.CreateBatch(h.batchOwner(c), params)
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// GetBatch handles GET /v1/batches/:id.
+func (h *OpenAIBatchAPIHandler) GetBatch(c *gin.Context) {
+	batch, err := h.manager.GetBatch(h.batchOwner(c), c.Param("id"))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// CancelBatch handles POST /v1/batches/:id/cancel.
+func (h *OpenAIBatchAPIHandler) CancelBatch(c *gin.Context) {
+	batch, err := h.manager.CancelBatch(h.batchOwner(c), c.Param("id"))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// ListBatches handles GET /v1/batches with the after and limit cursor parameters.
+func (h *OpenAIBatchAPIHandler) ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	list, hasMore := h.manager.ListBatches(h.batchOwner(c), c.Query("after"), limit)
+	response := gin.H{"object": "list", "data": list, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(list) > 0 {
+		response["first_id"] = list[0].ID
+		response["last_id"] = list[len(list)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// executeBatchRequest runs one batch request as the batch owner would have sent it to
+// endpoint. Streaming is disabled, and cooldowns are reported so the batch waits for them.
+func (h *OpenAIBatchAPIHandler) executeBatchRequest(ctx context.Context, owner batches.Owner, endpoint string, body []byte) batches.Result {
+	identity, ok := h.ownerIdentity(owner)
+	if !ok {
+		return batches.Result{Revoked: true}
+	}
+	ctx = coreauth.WithClientIdentity(ctx, identity)
+	body, _ = sjson.DeleteBytes(body, "stream")
+	modelName := gjson.GetBytes(body, "model").String()
+
+	var (
+		resp   []byte
+		errMsg *interfaces.ErrorMessage
+	)
+	switch endpoint {
+	case batches.EndpointChatCompletions:
+		resp, errMsg = h.ExecuteWithAuthManager(ctx, OpenAI, modelName, body, "")
+	case batches.EndpointCompletions:
+		resp, errMsg = h.ExecuteWithAuthManager(ctx, OpenAI, modelName, convertCompletionsRequestToChatCompletions(body), "")
+		if errMsg == nil {
+			resp = convertChatCompletionsResponseToCompletions(resp)
+		}
+	case batches.EndpointResponses:
+		resp, errMsg = h.ExecuteWithAuthManager(ctx, OpenaiResponse, modelName, body, "")
+	case batches.EndpointEmbeddings:
+		resp, errMsg = h.ExecuteEmbedWithAuthManager(ctx, OpenAI, modelName, body, "")
+	default:
+		errMsg = handlers.DialectError(OpenAI, http.StatusBadRequest, "invalid_request", "unsupported endpoint "+endpoint)
+	}
+	if errMsg == nil {
+		return batches.Result{Status: http.StatusOK, Body: resp}
+	}
+
+	status := errMsg.StatusCode
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	result := batches.Result{Status: status}
+	if errMsg.Error != nil {
+		result.Body = []byte(errMsg.Error.Error())
+	}
+	if !json.Valid(result.Body) {
+		result.Body, _ = json.Marshal(handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: string(result.Body), Type: "api_error"}})
+	}
+	if status == http.StatusTooManyRequests && errMsg.Addon != nil {
+		if seconds, err := strconv.Atoi(errMsg.Addon.Get("Retry-After")); err == nil && seconds > 0 {
+			result.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return result
+}
+
+// batchOwner returns the identity files and batches are scoped to. Inline API keys are
+// replaced by their digest so the stored records never hold a usable key.
+func (h *OpenAIBatchAPIHandler) batchOwner(c *gin.Context) batches.Owner {
+	var owner batches.Owner
+	if v, ok := c.Get("accessProvider"); ok {
+		owner.Provider, _ = v.(string)
+	}
+	if v, ok := c.Get("apiKey"); ok {
+		owner.ID, _ = v.(string)
+	}
+	if v, ok := c.Get("accessMetadata"); ok {
+		owner.Metadata, _ = v.(map[string]string)
+	}
+	if _, isAPIKey := h.inlineAPIKeys(owner.Provider); isAPIKey {
+		owner.ID = apiKeyDigest(owner.ID)
+	}
+	return owner
+}
+
+// ownerIdentity maps a batch owner back to the client identity its requests run as. It
+// reports false once the owner's key was removed, disabled or expired, or its access
+// provider is no longer configured.
+func (h *OpenAIBatchAPIHandler) ownerIdentity(owner batches.Owner) (coreauth.ClientIdentity, bool) {
+	if keys, isAPIKey := h.inlineAPIKeys(owner.Provider); isAPIKey {
+		for _, key := range keys {
+			if key != "" && apiKeyDigest(key) == owner.ID {
+				return coreauth.ClientIdentity{APIKey: key, Metadata: owner.Metadata}, true
+			}
+		}
+		return coreauth.ClientIdentity{}, false
+	}
+	switch owner.Provider {
+	case sdkconfig.VirtualKeyAccessProviderName:
+		if h.Cfg == nil || !h.Cfg.VirtualKeys {
+			return coreauth.ClientIdentity{}, false
+		}
+		key, err := virtualkeys.Default().Get(owner.ID)
+		if err != nil || !key.Enabled || key.Expired(time.Now()) {
+			return coreauth.ClientIdentity{}, false
+		}
+		return coreauth.ClientIdentity{APIKey: key.ID, Metadata: virtualkeys.KeyMetadata(key)}, true
+	case sdkconfig.AccessProviderTypeJWT, sdkconfig.AccessProviderTypeMTLS:
+		// Tokens and certificates cannot be checked again without the client; the identity
+		// stays valid while its provider is configured.
+		if h.Cfg == nil {
+			return coreauth.ClientIdentity{}, false
+		}
+		for _, provider := range h.Cfg.RuntimeAccessProviders() {
+			if provider.Name == owner.Provider {
+				return coreauth.ClientIdentity{APIKey: owner.ID, Metadata: owner.Metadata}, true
+			}
+		}
+		return coreauth.ClientIdentity{}, false
+	}
+	return coreauth.ClientIdentity{APIKey: owner.ID, Metadata: owner.Metadata}, true
+}
+
+// inlineAPIKeys returns the keys of the named config API key provider, and whether the
+// provider is one.
+func (h *OpenAIBatchAPIHandler) inlineAPIKeys(provider string) ([]string, bool) {
+	if provider == sdkconfig.DefaultAccessProviderName {
+		if h.Cfg == nil {
+			return nil, true
+		}
+		return h.Cfg.APIKeys, true
+	}
+	if h.Cfg == nil {
+		return nil, false
+	}
+	for _, candidate := range h.Cfg.Access.Providers {
+		if candidate.Name == provider && candidate.Type == sdkconfig.AccessProviderTypeConfigAPIKey {
+			return candidate.APIKeys, true
+		}
+	}
+	return nil, false
+}
+
+func apiKeyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func writeBatchError(c *gin.Context, err error) {
+	status, errType := http.StatusBadRequest, "invalid_request_error"
+	switch {
+	case errors.Is(err, batches.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, batches.ErrInUse):
+		status = http.StatusConflict
+	case errors.Is(err, batches.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	}
+	c.JSON(status, handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: err.Error(), Type: errType}})
+}
@@ -0,0 +1,188 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/images"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// defaultImageModel serves image requests that do not name a model.
+const defaultImageModel = "gemini-2.5-flash-image"
+
+// ImageGenerations handles the /v1/images/generations endpoint.
+// The prompt is sent to a Gemini image model once per requested image.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageGenerations(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	var req images.Request
+	if err == nil {
+		req, err = images.ParseOpenAI(rawJSON)
+	}
+	if err != nil {
+		writeInvalidRequest(c, err)
+		return
+	}
+	h.handleImages(c, req)
+}
+
+// ImageEdits handles the multipart /v1/images/edits endpoint. The uploaded images, and the
+// mask when given, are sent to a Gemini image model together with the prompt.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageEdits(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		writeInvalidRequest(c, fmt.Errorf("expected multipart/form-data: %w", err))
+		return
+	}
+	n := 1
+	if value := c.PostForm("n"); value != "" {
+		if n, err = strconv.Atoi(value); err != nil {
+			writeInvalidRequest(c, fmt.Errorf("n must be an integer"))
+			return
+		}
+	}
+	req, err := images.NewRequest(c.PostForm("model"), c.PostForm("prompt"), n, c.PostForm("size"), c.PostForm("response_format"))
+	if err != nil {
+		writeInvalidRequest(c, err)
+		return
+	}
+	files := append(append([]*multipart.FileHeader(nil), form.File["image"]...), form.File["image[]"]...)
+	if len(files) == 0 {
+		writeInvalidRequest(c, fmt.Errorf("image is required"))
+		return
+	}
+	for _, file := range files {
+		mimeType, data, errRead := readUpload(file)
+		if errRead != nil {
+			writeInvalidRequest(c, errRead)
+			return
+		}
+		req.Images = append(req.Images, images.Image{MimeType: mimeType, Data: data})
+	}
+	if masks := form.File["mask"]; len(masks) > 0 {
+		mimeType, data, errRead := readUpload(masks[0])
+		if errRead != nil {
+			writeInvalidRequest(c, errRead)
+			return
+		}
+		req.Mask = &images.Image{MimeType: mimeType, Data: data}
+	}
+	h.handleImages(c, req)
+}
+
+// handleImages runs the Gemini requests for an image request and writes the OpenAI response.
+// Requests are sent in the Gemini format so every Gemini-family provider can serve them.
+func (h *OpenAIAPIHandler) handleImages(c *gin.Context, req images.Request) {
+	c.Header("Content-Type", "application/json")
+	modelName := req.Model
+	if modelName == "" {
+		modelName = defaultImageModel
+	}
+	body := req.Gemini()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+
+	var (
+		results       []images.Result
+		revisedPrompt string
+	)
+	for attempt := 0; attempt < req.N && len(results) < req.N; attempt++ {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, Gemini, modelName, body, "")
+		if errMsg != nil {
+			errMsg = h.openAIError(errMsg)
+			h.WriteErrorResponse(c, errMsg)
+			cliCancel(errMsg.Error)
+			return
+		}
+		batch, text := images.ParseGemini(resp)
+		results = append(results, batch...)
+		if revisedPrompt == "" {
+			revisedPrompt = text
+		}
+	}
+	if len(results) == 0 {
+		message := "model " + modelName + " returned no image"
+		if revisedPrompt != "" {
+			message += ": " + revisedPrompt
+		}
+		errMsg := handlers.DialectError(h.HandlerType(), http.StatusBadGateway, "no_image", message)
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if len(results) > req.N {
+		results = results[:req.N]
+	}
+	_, _ = c.Writer.Write(images.OpenAIResponse(time.Now().Unix(), results, req.ResponseFormat, revisedPrompt))
+	cliCancel()
+}
+
+// openAIError reshapes an error from a request executed in another dialect so OpenAI
+// clients can read it. Headers such as Retry-After are kept.
+func (h *OpenAIAPIHandler) openAIError(errMsg *interfaces.ErrorMessage) *interfaces.ErrorMessage {
+	status := errMsg.StatusCode
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	message := http.StatusText(status)
+	if errMsg.Error != nil {
+		message = errMsg.Error.Error()
+		if nested := gjson.Get(message, "error.message"); nested.Exists() {
+			message = nested.String()
+		}
+	}
+	out := handlers.DialectError(h.HandlerType(), status, "", message)
+	for key, values := range errMsg.Addon {
+		if !strings.EqualFold(key, "Content-Type") {
+			out.Addon[key] = values
+		}
+	}
+	return out
+}
+
+// readUpload reads an uploaded file and detects its MIME type. Files larger than Gemini
+// accepts as inline data are rejected.
+func readUpload(file *multipart.FileHeader) (string, []byte, error) {
+	tooLarge := fmt.Errorf("%s exceeds the %d MB upload limit", file.Filename, misc.MaxInlineDataBytes>>20)
+	if file.Size > misc.MaxInlineDataBytes {
+		return "", nil, tooLarge
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() { _ = f.Close() }()
+	data, err := io.ReadAll(io.LimitReader(f, misc.MaxInlineDataBytes+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > misc.MaxInlineDataBytes {
+		return "", nil, tooLarge
+	}
+	return misc.DetectMimeType(file.Filename, file.Header.Get("Content-Type"), data), data, nil
+}
+
+func writeInvalidRequest(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: fmt.Sprintf("Invalid request: %v", err),
+			Type:    "invalid_request_error",
+		},
+	})
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrails"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// clientAPIKey returns the principal the access middleware authenticated the request with,
+// or the client identity of a request the proxy runs on a client's behalf.
+func clientAPIKey(ctx context.Context) string {
+	if identity, ok := coreauth.ClientIdentityFromContext(ctx); ok {
+		return identity.APIKey
+	}
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil {
+		return ""
+	}
+	if v, exists := c.Get("apiKey"); exists {
+		if key, isString := v.(string); isString {
+			return key
+		}
+	}
+	return ""
+}
+
+// clientPolicy returns the access policy of the calling client key, or nil. The model patterns
+// of a virtual key act as its allowed models unless its configured policy lists them already.
+func (h *BaseAPIHandler) clientPolicy(ctx context.Context) *config.APIKeyPolicy {
+	key := clientAPIKey(ctx)
+	var policy *config.APIKeyPolicy
+	if h.Cfg != nil && len(h.Cfg.APIKeyPolicies) > 0 {
+		policy = h.Cfg.APIKeyPolicy(key)
+	}
+	models := clientModels(ctx)
+	if len(models) == 0 || (policy != nil && len(policy.AllowedModels) > 0) {
+		return policy
+	}
+	scoped := config.APIKeyPolicy{APIKey: key}
+	if policy != nil {
+		scoped = *policy
+	}
+	scoped.AllowedModels = models
+	return &scoped
+}
+
+// clientModels returns the model patterns the access provider attached to the request.
+func clientModels(ctx context.Context) []string {
+	var metadata map[string]string
+	if identity, ok := coreauth.ClientIdentityFromContext(ctx); ok {
+		metadata = identity.Metadata
+	} else {
+		c, ok := ctx.Value("gin").(*gin.Context)
+		if !ok || c == nil {
+			return nil
+		}
+		v, exists := c.Get("accessMetadata")
+		if !exists {
+			return nil
+		}
+		metadata, _ = v.(map[string]string)
+	}
+	raw := strings.TrimSpace(metadata["models"])
+	if raw == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
+// withRequestPolicy hands the client key's policy to the auth manager so fallback models
+// and credential selection stay within it.
+func (h *BaseAPIHandler) withRequestPolicy(ctx context.Context) context.Context {
+	policy := h.clientPolicy(ctx)
+	if policy == nil {
+		return ctx
+	}
+	return coreauth.WithRequestPolicy(ctx, &coreauth.RequestPolicy{
+		AllowModel:            func(model string) bool { return policyAllowsModel(policy, model) },
+		Providers:             policy.AllowedProviders,
+		APIKeyCredentialsOnly: policy.APIKeyCredentialsOnly,
+	})
+}
+
+// checkPolicyModel returns a 403 in the client's dialect when the key may not call the model.
+func checkPolicyModel(policy *config.APIKeyPolicy, handlerType, modelName, normalizedModel string) *interfaces.ErrorMessage {
+	if policyAllowsModel(policy, modelName) && policyAllowsModel(policy, normalizedModel) {
+		return nil
+	}
+	return dialectError(handlerType, http.StatusForbidden, "model_not_allowed", fmt.Sprintf("API key is not allowed to use model %s", modelName))
+}
+
+// filterPolicyProviders drops the providers the key may not use and returns a 403 in the
+// client's dialect when none is left.
+func filterPolicyProviders(policy *config.APIKeyPolicy, handlerType, modelName string, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if len(policy.AllowedProviders) == 0 {
+		return providers, nil
+	}
+	allowed := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		for _, candidate := range policy.AllowedProviders {
+			if strings.EqualFold(strings.TrimSpace(candidate), provider) {
+				allowed = append(allowed, provider)
+				break
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, dialectError(handlerType, http.StatusForbidden, "provider_not_allowed", fmt.Sprintf("API key is not allowed to use the providers serving model %s", modelName))
+	}
+	return allowed, nil
+}
+
+// enforceRateLimit admits the request against the client key's budgets. The rate limit
+// headers are set on the response either way; exhausted budgets yield a 429 in the client's
+// dialect.
+func (h *BaseAPIHandler) enforceRateLimit(ctx context.Context, handlerType string) *interfaces.ErrorMessage {
+	policy := h.clientPolicy(ctx)
+	if policy == nil {
+		return nil
+	}
+	limits := ratelimit.Limits{
+		RequestsPerMinute: policy.RequestsPerMinute,
+		TokensPerMinute:   policy.TokensPerMinute,
+		DailyTokens:       policy.DailyTokens,
+		MonthlyTokens:     policy.MonthlyTokens,
+	}
+	decision := ratelimit.Default().Allow(policy.APIKey, limits, time.Now())
+	if decision.Allowed {
+		if c, ok := ctx.Value("gin").(*gin.Context); ok && c != nil {
+			for key, values := range decision.Headers {
+				c.Header(key, values[0])
+			}
+		}
+		return nil
+	}
+	errMsg := dialectError(handlerType, http.StatusTooManyRequests, "rate_limit_exceeded", fmt.Sprintf("API key rate limit exceeded (%s); retry in %s", decision.Reason, decision.RetryAfter.Round(time.Second)))
+	for key, values := range decision.Headers {
+		errMsg.Addon[key] = values
+	}
+	return errMsg
+}
+
+// applyGuardrails runs the configured guardrails on the client payload before translation.
+// A matching deny rule yields a 400 in the client's dialect; redactions are applied to the
+// returned payload and their counts logged.
+func applyGuardrails(ctx context.Context, handlerType, modelName, normalizedModel string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	outcome := guardrails.Default().Apply(rawJSON, clientAPIKey(ctx), modelName, normalizedModel)
+	if outcome.Denied != "" {
+		log.Infof("guardrail %s denied a request for model %s", outcome.Denied, modelName)
+		message := outcome.Message
+		if message == "" {
+			message = fmt.Sprintf("request blocked by content policy %s", outcome.Denied)
+		}
+		return nil, dialectError(handlerType, http.StatusBadRequest, "content_policy_violation", message)
+	}
+	if len(outcome.Redactions) > 0 {
+		log.Infof("guardrails redacted matches for model %s: %s", modelName, outcome.Summary())
+	}
+	return outcome.Payload, nil
+}
+
+// policyAllowsModel applies the deny list first, then the allow list when one is set.
+func policyAllowsModel(policy *config.APIKeyPolicy, model string) bool {
+	if policy == nil {
+		return true
+	}
+	for _, pattern := range policy.DeniedModels {
+		if util.MatchModelPattern(pattern, model) {
+			return false
+		}
+	}
+	if len(policy.AllowedModels) == 0 {
+		return true
+	}
+	for _, pattern := range policy.AllowedModels {
+		if util.MatchModelPattern(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// DialectError builds an error response shaped like the API handlerType speaks, for handlers
+// that reject a request before it reaches the auth manager.
+func DialectError(handlerType string, status int, code, message string) *interfaces.ErrorMessage {
+	return dialectError(handlerType, status, code, message)
+}
+
+// dialectError builds an error response shaped like the upstream API the client speaks.
+func dialectError(handlerType string, status int, code, message string) *interfaces.ErrorMessage {
+	var body any
+	switch handlerType {
+	case constant.Claude:
+		body = map[string]any{
+			"type":  "error",
+			"error": map[string]any{"type": claudeErrorType(status), "message": message},
+		}
+	case constant.Gemini, constant.GeminiCLI:
+		body = map[string]any{
+			"error": map[string]any{"code": status, "message": message, "status": geminiErrorStatus(status)},
+		}
+	default:
+		body = ErrorResponse{Error: ErrorDetail{Message: message, Type: claudeErrorType(status), Code: code}}
+	}
+	raw, _ := json.Marshal(body)
+	return &interfaces.ErrorMessage{
+		StatusCode: status,
+		Error:      errors.New(string(raw)),
+		Addon:      http.Header{"Content-Type": []string{"application/json"}},
+	}
+}
+
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "api_error"
+		}
+		return "invalid_request_error"
+	}
+}
+
+func geminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "INTERNAL"
+		}
+		return "INVALID_ARGUMENT"
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypto"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
@@ -63,7 +64,7 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = authcrypto.SaveStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -71,19 +72,8 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 		if errMarshal != nil {
 			return "", fmt.Errorf("auth filestore: marshal metadata failed: %w", errMarshal)
 		}
-		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
-			}
-		} else if errRead != nil && !os.IsNotExist(errRead) {
-			return "", fmt.Errorf("auth filestore: read existing failed: %w", errRead)
-		}
-		tmp := path + ".tmp"
-		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
-			return "", fmt.Errorf("auth filestore: write temp failed: %w", errWrite)
-		}
-		if errRename := os.Rename(tmp, path); errRename != nil {
-			return "", fmt.Errorf("auth filestore: rename failed: %w", errRename)
+		if errWrite := authcrypto.WriteFile(path, raw); errWrite != nil {
+			return "", fmt.Errorf("auth filestore: write failed: %w", errWrite)
 		}
 	default:
 		return "", fmt.Errorf("auth filestore: nothing to persist for %s", auth.ID)
@@ -161,7 +151,7 @@ func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, _, err := authcrypto.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
@@ -264,64 +254,102 @@ func (s *FileTokenStore) baseDirSnapshot() string {
 	return s.baseDir
 }
 
-func jsonEqual(a, b []byte) bool {
-	var objA any
-	var objB any
-	if err := json.Unmarshal(a, &objA); err != nil {
-		return false
+// Sidecar files hold runtime data next to the auth files. They have no .json suffix so they
+// are never mistaken for auth records.
+const (
+	authStateFileName   = ".auth-state"
+	rateLimitsFileName  = ".rate-limits"
+	virtualKeysFileName = ".virtual-keys"
+)
+
+// LoadState reads the persisted runtime availability state of the auths.
+func (s *FileTokenStore) LoadState(ctx context.Context) (map[string]*cliproxyauth.AuthState, error) {
+	data, err := s.readSidecar(authStateFileName)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(b, &objB); err != nil {
-		return false
+	states := make(map[string]*cliproxyauth.AuthState)
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err = json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("auth filestore: decode state failed: %w", err)
 	}
-	return deepEqualJSON(objA, objB)
+	return states, nil
 }
 
-func deepEqualJSON(a, b any) bool {
-	switch valA := a.(type) {
-	case map[string]any:
-		valB, ok := b.(map[string]any)
-		if !ok || len(valA) != len(valB) {
-			return false
-		}
-		for key, subA := range valA {
-			subB, ok1 := valB[key]
-			if !ok1 || !deepEqualJSON(subA, subB) {
-				return false
-			}
-		}
-		return true
-	case []any:
-		sliceB, ok := b.([]any)
-		if !ok || len(valA) != len(sliceB) {
-			return false
-		}
-		for i := range valA {
-			if !deepEqualJSON(valA[i], sliceB[i]) {
-				return false
-			}
-		}
-		return true
-	case float64:
-		valB, ok := b.(float64)
-		if !ok {
-			return false
-		}
-		return valA == valB
-	case string:
-		valB, ok := b.(string)
-		if !ok {
-			return false
+// SaveState replaces the persisted runtime availability state of the auths.
+func (s *FileTokenStore) SaveState(ctx context.Context, states map[string]*cliproxyauth.AuthState) error {
+	if len(states) == 0 {
+		return s.writeSidecar(authStateFileName, nil)
+	}
+	raw, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("auth filestore: marshal state failed: %w", err)
+	}
+	return s.writeSidecar(authStateFileName, raw)
+}
+
+// LoadRateLimits reads the persisted client rate limit counters.
+func (s *FileTokenStore) LoadRateLimits(ctx context.Context) ([]byte, error) {
+	return s.readSidecar(rateLimitsFileName)
+}
+
+// SaveRateLimits replaces the persisted client rate limit counters.
+func (s *FileTokenStore) SaveRateLimits(ctx context.Context, data []byte) error {
+	return s.writeSidecar(rateLimitsFileName, data)
+}
+
+// LoadVirtualKeys reads the persisted virtual client keys.
+func (s *FileTokenStore) LoadVirtualKeys(ctx context.Context) ([]byte, error) {
+	return s.readSidecar(virtualKeysFileName)
+}
+
+// SaveVirtualKeys replaces the persisted virtual client keys.
+func (s *FileTokenStore) SaveVirtualKeys(ctx context.Context, data []byte) error {
+	return s.writeSidecar(virtualKeysFileName, data)
+}
+
+// readSidecar returns the content of a sidecar file, or nil when it does not exist.
+func (s *FileTokenStore) readSidecar(name string) ([]byte, error) {
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return nil, fmt.Errorf("auth filestore: directory not configured")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
 		}
-		return valA == valB
-	case bool:
-		valB, ok := b.(bool)
-		if !ok {
-			return false
+		return nil, fmt.Errorf("auth filestore: read %s failed: %w", name, err)
+	}
+	return data, nil
+}
+
+// writeSidecar atomically replaces a sidecar file; empty data removes it.
+func (s *FileTokenStore) writeSidecar(name string, data []byte) error {
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return fmt.Errorf("auth filestore: directory not configured")
+	}
+	path := filepath.Join(dir, name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(data) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("auth filestore: delete %s failed: %w", name, err)
 		}
-		return valA == valB
-	case nil:
-		return b == nil
-	default:
-		return false
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("auth filestore: create dir failed: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("auth filestore: write temp failed: %w", err)
 	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("auth filestore: rename failed: %w", err)
+	}
+	return nil
 }
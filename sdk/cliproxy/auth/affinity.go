@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultAffinityTTL        = time.Hour
+	defaultAffinityMaxEntries = 10000
+)
+
+// Session affinity outcomes reported through SessionAffinityFromContext.
+const (
+	// AffinityHit means the request reused the credential pinned to its affinity key.
+	AffinityHit = "hit"
+	// AffinityMiss means the request had an affinity key but a new credential was picked.
+	AffinityMiss = "miss"
+)
+
+// SessionAffinityConfig controls sticky credential selection. Requests sharing an affinity
+// key keep using the same auth until it is blocked, so upstream prompt caches stay warm.
+type SessionAffinityConfig struct {
+	// Enabled turns session affinity on.
+	Enabled bool
+	// Header names a request header whose value is used as the affinity key when present.
+	Header string
+	// TTL is how long an idle key stays pinned.
+	TTL time.Duration
+	// MaxEntries bounds the number of pinned keys; the least recently used are evicted first.
+	MaxEntries int
+}
+
+type affinityEntry struct {
+	key     string
+	authID  string
+	expires time.Time
+}
+
+// sessionAffinity is a TTL and size bounded LRU map from affinity key to auth ID.
+type sessionAffinity struct {
+	mu      sync.Mutex
+	cfg     SessionAffinityConfig
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func newSessionAffinity() *sessionAffinity {
+	return &sessionAffinity{entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (a *sessionAffinity) configure(cfg SessionAffinityConfig) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultAffinityTTL
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultAffinityMaxEntries
+	}
+	cfg.Header = strings.TrimSpace(cfg.Header)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cfg = cfg
+	if !cfg.Enabled {
+		a.entries = make(map[string]*list.Element)
+		a.order.Init()
+		return
+	}
+	a.evictLocked(time.Now())
+}
+
+func (a *sessionAffinity) settings() SessionAffinityConfig {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cfg
+}
+
+func (a *sessionAffinity) lookup(key string, now time.Time) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	elem, ok := a.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*affinityEntry)
+	if now.After(entry.expires) {
+		a.order.Remove(elem)
+		delete(a.entries, key)
+		return "", false
+	}
+	return entry.authID, true
+}
+
+func (a *sessionAffinity) store(key, authID string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	expires := now.Add(a.cfg.TTL)
+	if elem, ok := a.entries[key]; ok {
+		entry := elem.Value.(*affinityEntry)
+		entry.authID = authID
+		entry.expires = expires
+		a.order.MoveToFront(elem)
+		return
+	}
+	a.entries[key] = a.order.PushFront(&affinityEntry{key: key, authID: authID, expires: expires})
+	a.evictLocked(now)
+}
+
+// evictLocked drops expired entries from the tail and enforces MaxEntries.
+func (a *sessionAffinity) evictLocked(now time.Time) {
+	for a.order.Len() > 0 {
+		tail := a.order.Back()
+		entry := tail.Value.(*affinityEntry)
+		if a.order.Len() <= a.cfg.MaxEntries && !now.After(entry.expires) {
+			return
+		}
+		a.order.Remove(tail)
+		delete(a.entries, entry.key)
+	}
+}
+
+// SetSessionAffinity applies the session affinity settings. Pinned keys survive
+// reconfiguration unless affinity is disabled.
+func (m *Manager) SetSessionAffinity(cfg SessionAffinityConfig) {
+	m.affinity.configure(cfg)
+}
+
+// selectAuth picks a candidate through the active selector, honouring session affinity.
+// The returned outcome is empty when the request carries no affinity key.
+func (m *Manager) selectAuth(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, candidates []*Auth) (*Auth, string, error) {
+	cfg := m.affinity.settings()
+	if !cfg.Enabled {
+		selected, err := m.selector.Pick(ctx, provider, model, opts, candidates)
+		return selected, "", err
+	}
+	key := affinityKey(ctx, opts, cfg.Header)
+	if key == "" {
+		selected, err := m.selector.Pick(ctx, provider, model, opts, candidates)
+		return selected, "", err
+	}
+	scoped := provider + "\x00" + key
+	now := time.Now()
+	if authID, ok := m.affinity.lookup(scoped, now); ok {
+		for _, candidate := range candidates {
+			if candidate.ID != authID {
+				continue
+			}
+			if blocked, _, _ := isAuthBlockedForModel(candidate, model, now); !blocked {
+				m.affinity.store(scoped, authID, now)
+				return candidate, AffinityHit, nil
+			}
+			break
+		}
+	}
+	selected, err := m.selector.Pick(ctx, provider, model, opts, candidates)
+	if err != nil {
+		return nil, "", err
+	}
+	if selected != nil {
+		m.affinity.store(scoped, selected.ID, now)
+	}
+	return selected, AffinityMiss, nil
+}
+
+// affinityKey derives the sticky-session key for a request. An explicit header wins,
+// then client supplied identifiers (Claude metadata.user_id, OpenAI prompt_cache_key and
+// user), and finally a hash of the system prompt plus the first conversation message.
+func affinityKey(ctx context.Context, opts cliproxyexecutor.Options, header string) string {
+	if header != "" && ctx != nil {
+		if hc, ok := ctx.Value("gin").(interface{ GetHeader(string) string }); ok && hc != nil {
+			if value := strings.TrimSpace(hc.GetHeader(header)); value != "" {
+				return "header:" + value
+			}
+		}
+	}
+	payload := opts.OriginalRequest
+	if len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return ""
+	}
+	for _, path := range []string{"metadata.user_id", "prompt_cache_key", "user"} {
+		if value := strings.TrimSpace(gjson.GetBytes(payload, path).String()); value != "" {
+			return path + ":" + value
+		}
+	}
+	return conversationFingerprint(payload)
+}
+
+// conversationFingerprint hashes the system prompt and the first non-system message across
+// the Claude, OpenAI chat, OpenAI responses and Gemini request shapes.
+func conversationFingerprint(payload []byte) string {
+	var parts []string
+	for _, path := range []string{"system", "instructions", "systemInstruction", "system_instruction"} {
+		if value := gjson.GetBytes(payload, path); value.Exists() {
+			parts = append(parts, value.Raw)
+		}
+	}
+	for _, path := range []string{"messages", "input", "contents"} {
+		messages := gjson.GetBytes(payload, path)
+		if !messages.IsArray() {
+			continue
+		}
+		for _, message := range messages.Array() {
+			role := message.Get("role").String()
+			if role == "system" || role == "developer" {
+				parts = append(parts, message.Get("content").Raw)
+				continue
+			}
+			parts = append(parts, message.Raw)
+			break
+		}
+		break
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return "hash:" + hex.EncodeToString(sum[:16])
+}
+
+type affinityOutcomeContextKey struct{}
+
+func withAffinityOutcome(ctx context.Context, outcome string) context.Context {
+	if outcome == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, affinityOutcomeContextKey{}, outcome)
+}
+
+// SessionAffinityFromContext reports whether the executing request reused its pinned
+// credential (AffinityHit), was pinned to a new one (AffinityMiss), or had no key ("").
+func SessionAffinityFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	outcome, _ := ctx.Value(affinityOutcomeContextKey{}).(string)
+	return outcome
+}
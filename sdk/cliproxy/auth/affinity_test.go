@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestAffinityKeyDerivation(t *testing.T) {
+	cases := map[string]string{
+		`{"metadata":{"user_id":"u-1"},"messages":[]}`: "metadata.user_id:u-1",
+		`{"prompt_cache_key":"pk","user":"someone"}`:   "prompt_cache_key:pk",
+		`{"user":"someone"}`:                           "user:someone",
+		`{"model":"x"}`:                                "",
+	}
+	for payload, want := range cases {
+		got := affinityKey(context.Background(), cliproxyexecutor.Options{OriginalRequest: []byte(payload)}, "")
+		if got != want {
+			t.Fatalf("payload %s: expected %q, got %q", payload, want, got)
+		}
+	}
+
+	first := `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`
+	later := `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"more"}]}`
+	a := affinityKey(context.Background(), cliproxyexecutor.Options{OriginalRequest: []byte(first)}, "")
+	b := affinityKey(context.Background(), cliproxyexecutor.Options{OriginalRequest: []byte(later)}, "")
+	if a == "" || a != b {
+		t.Fatalf("expected the same conversation to share a fingerprint, got %q and %q", a, b)
+	}
+}
+
+func TestSelectAuthStaysPinnedUntilBlocked(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetSessionAffinity(SessionAffinityConfig{Enabled: true})
+	auths := []*Auth{{ID: "a", Provider: "p"}, {ID: "b", Provider: "p"}}
+	opts := cliproxyexecutor.Options{OriginalRequest: []byte(`{"user":"u"}`)}
+
+	first, outcome, err := m.selectAuth(context.Background(), "p", "m", opts, auths)
+	if err != nil || outcome != AffinityMiss {
+		t.Fatalf("expected initial miss, got %q (%v)", outcome, err)
+	}
+	for i := 0; i < 3; i++ {
+		picked, outcome, _ := m.selectAuth(context.Background(), "p", "m", opts, auths)
+		if picked.ID != first.ID || outcome != AffinityHit {
+			t.Fatalf("expected pinned auth %s, got %s (%s)", first.ID, picked.ID, outcome)
+		}
+	}
+
+	for _, candidate := range auths {
+		if candidate.ID == first.ID {
+			candidate.ModelStates = map[string]*ModelState{"m": {Unavailable: true, NextRetryAfter: time.Now().Add(time.Minute)}}
+		}
+	}
+	picked, outcome, _ := m.selectAuth(context.Background(), "p", "m", opts, auths)
+	if picked.ID == first.ID || outcome != AffinityMiss {
+		t.Fatalf("expected re-pin away from blocked auth, got %s (%s)", picked.ID, outcome)
+	}
+}
+
+func TestSessionAffinityBoundsEntries(t *testing.T) {
+	a := newSessionAffinity()
+	a.configure(SessionAffinityConfig{Enabled: true, MaxEntries: 2, TTL: time.Minute})
+	now := time.Now()
+	a.store("k1", "a", now)
+	a.store("k2", "a", now)
+	a.store("k3", "a", now)
+	if _, ok := a.lookup("k1", now); ok {
+		t.Fatal("expected least recently used key to be evicted")
+	}
+	if _, ok := a.lookup("k3", now.Add(2*time.Minute)); ok {
+		t.Fatal("expected expired key to be dropped")
+	}
+}
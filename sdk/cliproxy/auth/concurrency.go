@@ -0,0 +1,466 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	defaultConcurrencyQueueSize    = 100
+	defaultConcurrencyQueueTimeout = 30 * time.Second
+)
+
+// AttributeMaxConcurrency caps the requests executing at once on an auth. File-backed auths
+// may carry the same key in their metadata. Zero falls back to the provider default.
+const AttributeMaxConcurrency = "max_concurrency"
+
+// errAuthsAtCapacity is returned by pickNext when every eligible auth is at its concurrency limit.
+var errAuthsAtCapacity = &Error{Code: "auth_at_capacity", Message: "all credentials are at their concurrency limit", Retryable: true, HTTPStatus: http.StatusServiceUnavailable}
+
+// ConcurrencyConfig bounds parallel requests per auth. Requests that find every eligible
+// auth at capacity try the other providers serving the model first, then wait in a
+// per-provider FIFO queue instead of failing.
+type ConcurrencyConfig struct {
+	// DefaultMax applies to auths without their own limit or provider default; 0 is unlimited.
+	DefaultMax int
+	// Providers maps a provider key to the default limit for its auths.
+	Providers map[string]int
+	// QueueSize bounds the waiting requests per provider (default 100).
+	QueueSize int
+	// QueueTimeout is the longest a request waits for a free slot (default 30s).
+	QueueTimeout time.Duration
+}
+
+// ProviderQueueStats describes the concurrency queue of one provider.
+type ProviderQueueStats struct {
+	Provider    string  `json:"provider"`
+	Depth       int     `json:"depth"`
+	Queued      int64   `json:"queued"`
+	Timeouts    int64   `json:"timeouts"`
+	Rejected    int64   `json:"rejected"`
+	AvgWaitMS   float64 `json:"avg_wait_ms"`
+	MaxWaitMS   float64 `json:"max_wait_ms"`
+	TotalWaitMS float64 `json:"total_wait_ms"`
+}
+
+// AuthConcurrency describes the in-flight requests of one auth against its limit.
+type AuthConcurrency struct {
+	AuthID   string `json:"auth_id"`
+	Provider string `json:"provider"`
+	Label    string `json:"label,omitempty"`
+	InFlight int64  `json:"in_flight"`
+	Limit    int    `json:"limit"`
+}
+
+// ConcurrencyStats is a snapshot of the concurrency limiter.
+type ConcurrencyStats struct {
+	Queues []ProviderQueueStats `json:"queues"`
+	Auths  []AuthConcurrency    `json:"auths"`
+}
+
+type queueCounters struct {
+	queued    int64
+	timeouts  int64
+	rejected  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// concurrencyLimiter holds the limits and the per-provider wait queues.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	cfg      ConcurrencyConfig
+	queues   map[string]*list.List // provider -> *queuedWaiter
+	counters map[string]*queueCounters
+	// generations counts the slots freed per provider, so a request that found every auth
+	// at capacity can tell whether a slot was released before it joined the queue.
+	generations map[string]uint64
+}
+
+// queuedWaiter is a request waiting for a slot; gen is the generation it last looked at.
+type queuedWaiter struct {
+	ch  chan struct{}
+	gen uint64
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{
+		cfg:         ConcurrencyConfig{QueueSize: defaultConcurrencyQueueSize, QueueTimeout: defaultConcurrencyQueueTimeout},
+		queues:      make(map[string]*list.List),
+		counters:    make(map[string]*queueCounters),
+		generations: make(map[string]uint64),
+	}
+}
+
+func (l *concurrencyLimiter) configure(cfg ConcurrencyConfig) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultConcurrencyQueueSize
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = defaultConcurrencyQueueTimeout
+	}
+	providers := make(map[string]int, len(cfg.Providers))
+	for provider, limit := range cfg.Providers {
+		if key := strings.ToLower(strings.TrimSpace(provider)); key != "" && limit > 0 {
+			providers[key] = limit
+		}
+	}
+	cfg.Providers = providers
+	l.mu.Lock()
+	l.cfg = cfg
+	// Limits may have grown; let every waiter look again.
+	for provider := range l.generations {
+		l.generations[provider]++
+	}
+	for _, queue := range l.queues {
+		for e := queue.Front(); e != nil; e = e.Next() {
+			close(e.Value.(*queuedWaiter).ch)
+		}
+		queue.Init()
+	}
+	l.mu.Unlock()
+}
+
+// limit returns the concurrency limit for the auth; 0 means unlimited.
+func (l *concurrencyLimiter) limit(auth *Auth) int {
+	if v := int(authNumber(auth, AttributeMaxConcurrency, 0)); v > 0 {
+		return v
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.cfg.Providers[auth.Provider]; ok {
+		return v
+	}
+	if l.cfg.DefaultMax > 0 {
+		return l.cfg.DefaultMax
+	}
+	return 0
+}
+
+func (l *concurrencyLimiter) timeout() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg.QueueTimeout
+}
+
+func (l *concurrencyLimiter) countersLocked(provider string) *queueCounters {
+	c, ok := l.counters[provider]
+	if !ok {
+		c = &queueCounters{}
+		l.counters[provider] = c
+	}
+	return c
+}
+
+// generation returns the number of slots freed for the provider so far.
+func (l *concurrencyLimiter) generation(provider string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.generations[provider]
+}
+
+// waiting reports whether requests are queued for the provider.
+func (l *concurrencyLimiter) waiting(provider string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	queue, ok := l.queues[provider]
+	return ok && queue.Len() > 0
+}
+
+// wait blocks until a slot may have been freed for the provider, the deadline passes or ctx
+// is done. gen is the generation observed before the caller found no free slot; if a slot
+// was freed since, wait returns at once so the caller looks again. New requests queue
+// behind existing waiters, and woken waiters that still find no slot rejoin at the front,
+// to keep FIFO order. Slots are per auth while the queue is per provider, so a rejoining
+// waiter first hands its wakeup to the next waiter that has not looked since the slot was
+// freed; that one may be able to use an auth the rejoining waiter cannot.
+func (l *concurrencyLimiter) wait(ctx context.Context, provider string, deadline time.Time, rejoin bool, gen uint64) error {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return newConcurrencyTimeoutError(provider)
+	}
+	w := &queuedWaiter{ch: make(chan struct{}), gen: gen}
+	l.mu.Lock()
+	queue, ok := l.queues[provider]
+	if !ok {
+		queue = list.New()
+		l.queues[provider] = queue
+	}
+	if l.generations[provider] != gen && (rejoin || queue.Len() == 0) {
+		l.mu.Unlock()
+		return nil
+	}
+	counters := l.countersLocked(provider)
+	if !rejoin && queue.Len() >= l.cfg.QueueSize {
+		counters.rejected++
+		l.mu.Unlock()
+		return &Error{Code: "concurrency_queue_full", Message: fmt.Sprintf("%s concurrency queue is full", provider), Retryable: true, HTTPStatus: http.StatusServiceUnavailable}
+	}
+	var elem *list.Element
+	if rejoin {
+		handoffLocked(queue, gen)
+		elem = queue.PushFront(w)
+	} else {
+		elem = queue.PushBack(w)
+		counters.queued++
+	}
+	l.mu.Unlock()
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-w.ch:
+		return nil
+	case <-timer.C:
+		l.leave(provider, elem, w.ch)
+		l.mu.Lock()
+		l.countersLocked(provider).timeouts++
+		l.mu.Unlock()
+		return newConcurrencyTimeoutError(provider)
+	case <-ctx.Done():
+		l.leave(provider, elem, w.ch)
+		return ctx.Err()
+	}
+}
+
+// leave removes a waiter that gave up; if it was woken meanwhile the wakeup is passed on.
+func (l *concurrencyLimiter) leave(provider string, elem *list.Element, ch chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ch:
+		l.notifyLocked(provider)
+		return
+	default:
+	}
+	if queue, ok := l.queues[provider]; ok {
+		for e := queue.Front(); e != nil; e = e.Next() {
+			if e == elem {
+				queue.Remove(e)
+				return
+			}
+		}
+	}
+}
+
+// notify wakes the longest waiting request of the provider.
+func (l *concurrencyLimiter) notify(provider string) {
+	l.mu.Lock()
+	l.notifyLocked(provider)
+	l.mu.Unlock()
+}
+
+func (l *concurrencyLimiter) notifyLocked(provider string) {
+	l.generations[provider]++
+	queue, ok := l.queues[provider]
+	if !ok || queue.Len() == 0 {
+		return
+	}
+	close(queue.Remove(queue.Front()).(*queuedWaiter).ch)
+}
+
+// handoffLocked wakes the first waiter that has not looked since generation gen. It does
+// not bump the generation, so waiters that cannot use the freed slot do not wake each
+// other in turn.
+func handoffLocked(queue *list.List, gen uint64) {
+	for e := queue.Front(); e != nil; e = e.Next() {
+		if w := e.Value.(*queuedWaiter); w.gen < gen {
+			close(queue.Remove(e).(*queuedWaiter).ch)
+			return
+		}
+	}
+}
+
+func (l *concurrencyLimiter) recordWait(provider string, waited time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counters := l.countersLocked(provider)
+	counters.totalWait += waited
+	if waited > counters.maxWait {
+		counters.maxWait = waited
+	}
+}
+
+func newConcurrencyTimeoutError(provider string) *Error {
+	return &Error{Code: "concurrency_queue_timeout", Message: fmt.Sprintf("timed out waiting for a free %s credential", provider), Retryable: true, HTTPStatus: http.StatusServiceUnavailable}
+}
+
+// SetConcurrency applies per-auth concurrency limits and queue settings.
+func (m *Manager) SetConcurrency(cfg ConcurrencyConfig) {
+	m.concurrency.configure(cfg)
+}
+
+// ConcurrencyStats returns queue depth and wait times per provider and the in-flight count
+// of every limited auth.
+func (m *Manager) ConcurrencyStats() ConcurrencyStats {
+	m.mu.RLock()
+	auths := make([]*Auth, 0, len(m.auths))
+	for _, auth := range m.auths {
+		auths = append(auths, auth)
+	}
+	m.mu.RUnlock()
+
+	stats := ConcurrencyStats{Auths: make([]AuthConcurrency, 0)}
+	for _, auth := range auths {
+		limit := m.concurrency.limit(auth)
+		if limit <= 0 {
+			continue
+		}
+		stats.Auths = append(stats.Auths, AuthConcurrency{
+			AuthID:   auth.ID,
+			Provider: auth.Provider,
+			Label:    auth.Label,
+			InFlight: m.InFlight(auth.ID),
+			Limit:    limit,
+		})
+	}
+	sort.Slice(stats.Auths, func(i, j int) bool {
+		if stats.Auths[i].Provider != stats.Auths[j].Provider {
+			return stats.Auths[i].Provider < stats.Auths[j].Provider
+		}
+		return stats.Auths[i].AuthID < stats.Auths[j].AuthID
+	})
+
+	l := m.concurrency
+	l.mu.Lock()
+	stats.Queues = make([]ProviderQueueStats, 0, len(l.counters))
+	for provider, counters := range l.counters {
+		entry := ProviderQueueStats{
+			Provider:    provider,
+			Queued:      counters.queued,
+			Timeouts:    counters.timeouts,
+			Rejected:    counters.rejected,
+			MaxWaitMS:   float64(counters.maxWait) / float64(time.Millisecond),
+			TotalWaitMS: float64(counters.totalWait) / float64(time.Millisecond),
+		}
+		if queue, ok := l.queues[provider]; ok {
+			entry.Depth = queue.Len()
+		}
+		if counters.queued > 0 {
+			entry.AvgWaitMS = entry.TotalWaitMS / float64(counters.queued)
+		}
+		stats.Queues = append(stats.Queues, entry)
+	}
+	l.mu.Unlock()
+	sort.Slice(stats.Queues, func(i, j int) bool { return stats.Queues[i].Provider < stats.Queues[j].Provider })
+	return stats
+}
+
+// authPick is an auth selected for one attempt together with its reserved concurrency slot.
+type authPick struct {
+	auth      *Auth
+	executor  ProviderExecutor
+	affinity  string
+	queueWait time.Duration
+	release   func()
+}
+
+// context annotates ctx with the selection details reported to executors and usage plugins.
+func (p *authPick) context(ctx context.Context) context.Context {
+	ctx = withAffinityOutcome(ctx, p.affinity)
+	if p.queueWait > 0 {
+		ctx = context.WithValue(ctx, queueWaitContextKey{}, p.queueWait)
+	}
+	return ctx
+}
+
+// acquireNext picks the next untried auth and reserves a concurrency slot on it. When every
+// eligible auth is at capacity and wait is set, it queues until a slot frees up; requests
+// already queued for the provider are served first.
+func (m *Manager) acquireNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}, wait bool) (*authPick, error) {
+	var (
+		started  time.Time
+		deadline time.Time
+		rejoin   bool
+	)
+	wait = wait && queueingAllowed(ctx)
+	for {
+		gen := m.concurrency.generation(provider)
+		var (
+			auth     *Auth
+			executor ProviderExecutor
+			affinity string
+			err      error = errAuthsAtCapacity
+		)
+		if !wait || rejoin || !m.concurrency.waiting(provider) {
+			auth, executor, affinity, err = m.pickNext(ctx, provider, model, opts, tried)
+		}
+		if err == nil {
+			limit := m.concurrency.limit(auth)
+			release, ok := m.inFlight.tryAcquire(auth.ID, limit)
+			if ok {
+				pick := &authPick{auth: auth, executor: executor, affinity: affinity}
+				pick.release = func() {
+					release()
+					if limit > 0 {
+						m.concurrency.notify(provider)
+					}
+				}
+				if !started.IsZero() {
+					pick.queueWait = time.Since(started)
+					m.concurrency.recordWait(provider, pick.queueWait)
+				}
+				return pick, nil
+			}
+			// Another request took the last slot between selection and reservation.
+			err = errAuthsAtCapacity
+		}
+		if !wait || !errors.Is(err, errAuthsAtCapacity) {
+			return nil, err
+		}
+		if started.IsZero() {
+			started = time.Now()
+			deadline = started.Add(m.concurrency.timeout())
+		}
+		if errWait := m.concurrency.wait(ctx, provider, deadline, rejoin, gen); errWait != nil {
+			return nil, errWait
+		}
+		rejoin = true
+	}
+}
+
+type queueWaitContextKey struct{}
+
+type noQueueContextKey struct{}
+
+// withoutQueueing returns a context in which requests finding every auth of a provider at
+// capacity fail at once instead of queueing, so other providers can be tried first.
+func withoutQueueing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noQueueContextKey{}, true)
+}
+
+// providerAttemptContext disables queueing while other providers remain to be tried.
+func providerAttemptContext(ctx context.Context, providers []string) context.Context {
+	if len(providers) > 1 {
+		return withoutQueueing(ctx)
+	}
+	return ctx
+}
+
+func queueingAllowed(ctx context.Context) bool {
+	skip, _ := ctx.Value(noQueueContextKey{}).(bool)
+	return !skip
+}
+
+// isAtCapacity reports whether err means every auth of a provider was busy.
+func isAtCapacity(err error) bool {
+	return errors.Is(err, errAuthsAtCapacity)
+}
+
+// QueueWaitFromContext returns how long the executing request waited for a concurrency slot.
+func QueueWaitFromContext(ctx context.Context) time.Duration {
+	if ctx == nil {
+		return 0
+	}
+	waited, _ := ctx.Value(queueWaitContextKey{}).(time.Duration)
+	return waited
+}
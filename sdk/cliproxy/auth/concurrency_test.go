@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// gateTestExecutor blocks every call until a value is sent on gate.
+type gateTestExecutor struct {
+	retryTestExecutor
+	gate chan struct{}
+}
+
+func (e *gateTestExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	select {
+	case <-e.gate:
+		return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+	case <-ctx.Done():
+		return cliproxyexecutor.Response{}, ctx.Err()
+	}
+}
+
+func newConcurrencyTestManager(t *testing.T, provider, authID string) (*Manager, *gateTestExecutor) {
+	t.Helper()
+	exec := &gateTestExecutor{retryTestExecutor: retryTestExecutor{provider: provider}, gate: make(chan struct{})}
+	registry.GetGlobalRegistry().RegisterClient(authID, provider, []*registry.ModelInfo{{ID: "limited-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(exec)
+	auth := &Auth{ID: authID, Provider: provider, Status: StatusActive, Attributes: map[string]string{AttributeMaxConcurrency: "1"}}
+	if _, err := m.Register(context.Background(), auth); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	return m, exec
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not reached in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExecuteQueuesWhenAuthAtCapacity(t *testing.T) {
+	m, exec := newConcurrencyTestManager(t, "limit-test", "limit-auth")
+	req := cliproxyexecutor.Request{Model: "limited-model"}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := m.Execute(context.Background(), []string{"limit-test"}, req, cliproxyexecutor.Options{})
+			errs <- err
+		}()
+	}
+	waitFor(t, func() bool {
+		stats := m.ConcurrencyStats()
+		return len(stats.Queues) == 1 && stats.Queues[0].Depth == 1
+	})
+	if got := m.InFlight("limit-auth"); got != 1 {
+		t.Fatalf("expected 1 request in flight, got %d", got)
+	}
+
+	exec.gate <- struct{}{}
+	exec.gate <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	stats := m.ConcurrencyStats()
+	if stats.Queues[0].Queued != 1 || stats.Queues[0].Depth != 0 {
+		t.Fatalf("unexpected queue stats %+v", stats.Queues[0])
+	}
+}
+
+func TestExecuteQueueTimeout(t *testing.T) {
+	m, exec := newConcurrencyTestManager(t, "limit-timeout-test", "limit-timeout-auth")
+	m.SetConcurrency(ConcurrencyConfig{QueueTimeout: 20 * time.Millisecond})
+	req := cliproxyexecutor.Request{Model: "limited-model"}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = m.Execute(context.Background(), []string{"limit-timeout-test"}, req, cliproxyexecutor.Options{})
+		close(done)
+	}()
+	waitFor(t, func() bool { return m.InFlight("limit-timeout-auth") == 1 })
+
+	_, err := m.Execute(context.Background(), []string{"limit-timeout-test"}, req, cliproxyexecutor.Options{})
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.Code != "concurrency_queue_timeout" {
+		t.Fatalf("expected queue timeout, got %v", err)
+	}
+	exec.gate <- struct{}{}
+	<-done
+}
+
+func TestConcurrencyWaitSeesSlotFreedBeforeQueueing(t *testing.T) {
+	l := newConcurrencyLimiter()
+	gen := l.generation("race-test")
+	// A slot is released after the caller found no capacity but before it joins the queue.
+	l.notify("race-test")
+
+	started := time.Now()
+	if err := l.wait(context.Background(), "race-test", started.Add(time.Second), false, gen); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if waited := time.Since(started); waited > 100*time.Millisecond {
+		t.Fatalf("expected an immediate return, waited %v", waited)
+	}
+	if l.waiting("race-test") {
+		t.Fatal("expected the caller not to stay queued")
+	}
+}
+
+func TestConcurrencyRejoinPassesWakeupOn(t *testing.T) {
+	l := newConcurrencyLimiter()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	gen := l.generation("handoff-test")
+	queueDepth := func() int {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.queues["handoff-test"].Len()
+	}
+
+	first := make(chan error, 1)
+	go func() { first <- l.wait(ctx, "handoff-test", deadline, false, gen) }()
+	waitFor(t, func() bool { return l.waiting("handoff-test") })
+	second := make(chan error, 1)
+	go func() { second <- l.wait(ctx, "handoff-test", deadline, false, gen) }()
+	waitFor(t, func() bool { return queueDepth() == 2 })
+
+	// The freed slot wakes the first waiter, which cannot use it and rejoins.
+	l.notify("handoff-test")
+	if err := <-first; err != nil {
+		t.Fatalf("first waiter: %v", err)
+	}
+	rejoined := make(chan error, 1)
+	go func() { rejoined <- l.wait(ctx, "handoff-test", deadline, true, l.generation("handoff-test")) }()
+
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Fatalf("second waiter: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the rejoining waiter to pass the wakeup on")
+	}
+	select {
+	case <-rejoined:
+		t.Fatal("expected the rejoining waiter to stay queued")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestExecuteFailsOverToIdleProviderBeforeQueueing(t *testing.T) {
+	m, exec := newConcurrencyTestManager(t, "limit-busy-test", "limit-busy-auth")
+	idle := &retryTestExecutor{provider: "limit-idle-test"}
+	registry.GetGlobalRegistry().RegisterClient("limit-idle-auth", idle.provider, []*registry.ModelInfo{{ID: "limited-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("limit-idle-auth") })
+	m.RegisterExecutor(idle)
+	if _, err := m.Register(context.Background(), &Auth{ID: "limit-idle-auth", Provider: idle.provider, Status: StatusActive}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	req := cliproxyexecutor.Request{Model: "limited-model"}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = m.Execute(context.Background(), []string{"limit-busy-test"}, req, cliproxyexecutor.Options{})
+		close(done)
+	}()
+	waitFor(t, func() bool { return m.InFlight("limit-busy-auth") == 1 })
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Execute(context.Background(), []string{"limit-busy-test", "limit-idle-test"}, req, cliproxyexecutor.Options{}); err != nil {
+			t.Fatalf("expected the idle provider to serve, got %v", err)
+		}
+	}
+	if got := idle.calls.Load(); got != 2 {
+		t.Fatalf("expected 2 calls on the idle provider, got %d", got)
+	}
+	exec.gate <- struct{}{}
+	<-done
+}
+
+// endlessStreamExecutor streams chunks until the attempt is canceled.
+type endlessStreamExecutor struct {
+	retryTestExecutor
+}
+
+func (e *endlessStreamExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case out <- cliproxyexecutor.StreamChunk{Payload: []byte("data")}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestAbandonedStreamReleasesConcurrencySlot(t *testing.T) {
+	exec := &endlessStreamExecutor{retryTestExecutor{provider: "limit-stream-test"}}
+	registry.GetGlobalRegistry().RegisterClient("limit-stream-auth", exec.provider, []*registry.ModelInfo{{ID: "limited-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("limit-stream-auth") })
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(exec)
+	auth := &Auth{ID: "limit-stream-auth", Provider: exec.provider, Status: StatusActive, Attributes: map[string]string{AttributeMaxConcurrency: "1"}}
+	if _, err := m.Register(context.Background(), auth); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, err := m.ExecuteStream(ctx, []string{exec.provider}, cliproxyexecutor.Request{Model: "limited-model"}, cliproxyexecutor.Options{Stream: true})
+	if err != nil {
+		t.Fatalf("execute stream: %v", err)
+	}
+	<-chunks
+	// The client goes away without draining the stream.
+	cancel()
+	waitFor(t, func() bool { return m.InFlight("limit-stream-auth") == 0 })
+	if auth, _ := m.GetByID("limit-stream-auth"); auth.LastError != nil {
+		t.Fatalf("expected the abandoned stream not to count as a failure, got %v", auth.LastError)
+	}
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type embedTestExecutor struct {
+	retryTestExecutor
+}
+
+func (e *embedTestExecutor) Embed(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.calls.Add(1)
+	return cliproxyexecutor.Response{Payload: []byte("embedded")}, nil
+}
+
+func TestExecuteEmbedUsesEmbeddingExecutor(t *testing.T) {
+	exec := &embedTestExecutor{retryTestExecutor{provider: "embed-test"}}
+	m := newRetryTestManager(t, &exec.retryTestExecutor, "embed-test-auth", "embed-model")
+	m.RegisterExecutor(exec)
+
+	resp, err := m.ExecuteEmbed(context.Background(), []string{"embed-test"}, cliproxyexecutor.Request{Model: "embed-model"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteEmbed: %v", err)
+	}
+	if string(resp.Payload) != "embedded" {
+		t.Fatalf("unexpected payload %q", resp.Payload)
+	}
+}
+
+func TestExecuteEmbedWithoutEmbeddingExecutorIsNotImplemented(t *testing.T) {
+	exec := &retryTestExecutor{provider: "embed-test-plain"}
+	m := newRetryTestManager(t, exec, "embed-test-plain-auth", "embed-model")
+
+	_, err := m.ExecuteEmbed(context.Background(), []string{"embed-test-plain"}, cliproxyexecutor.Request{Model: "embed-model"}, cliproxyexecutor.Options{})
+	if got := statusCodeFromError(err); got != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d (%v)", got, err)
+	}
+	if got := exec.calls.Load(); got != 0 {
+		t.Fatalf("expected no executor calls, got %d", got)
+	}
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// SetModelFallbacks installs the model fallback chains walked by Execute and ExecuteStream.
+// Keys are matched case-insensitively against the requested model; entries may carry a
+// thinking suffix or a "provider://model" prefix to pin an OpenAI-compatible provider.
+func (m *Manager) SetModelFallbacks(chains map[string][]string) {
+	normalized := make(map[string][]string, len(chains))
+	for model, fallbacks := range chains {
+		key := strings.ToLower(strings.TrimSpace(model))
+		if key == "" {
+			continue
+		}
+		entries := make([]string, 0, len(fallbacks))
+		for _, fallback := range fallbacks {
+			if trimmed := strings.TrimSpace(fallback); trimmed != "" {
+				entries = append(entries, trimmed)
+			}
+		}
+		if len(entries) > 0 {
+			normalized[key] = entries
+		}
+	}
+	m.modelFallbacks.Store(&normalized)
+}
+
+// ModelFallbacks returns the configured fallback chain for model, if any.
+func (m *Manager) ModelFallbacks(model string) []string {
+	chains := m.modelFallbacks.Load()
+	if chains == nil {
+		return nil
+	}
+	return append([]string(nil), (*chains)[strings.ToLower(strings.TrimSpace(model))]...)
+}
+
+// fallbackHop is one model attempt in a fallback chain.
+type fallbackHop struct {
+	providers []string
+	req       cliproxyexecutor.Request
+	opts      cliproxyexecutor.Options
+}
+
+// fallbackChain returns the requested model followed by its configured fallbacks.
+// Fallbacks without a provider serving them, or outside the request policy, are skipped.
+func (m *Manager) fallbackChain(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) []fallbackHop {
+	policy := RequestPolicyFromContext(ctx)
+	hops := []fallbackHop{{providers: providers, req: req, opts: opts}}
+	seen := map[string]struct{}{strings.ToLower(req.Model): {}}
+	for _, entry := range m.ModelFallbacks(req.Model) {
+		hopProviders, model, metadata := resolveFallbackModel(entry)
+		key := strings.ToLower(model)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		if !policy.allowsModel(model) {
+			log.Debugf("model fallback %s for %s skipped: not allowed by request policy", entry, req.Model)
+			continue
+		}
+		hopProviders = policy.filterProviders(m.normalizeProviders(hopProviders))
+		if len(hopProviders) == 0 {
+			log.Debugf("model fallback %s for %s skipped: no provider serves it", entry, req.Model)
+			continue
+		}
+		hops = append(hops, fallbackHop{
+			providers: hopProviders,
+			req:       fallbackRequest(req, model, metadata),
+			opts:      fallbackOptions(opts, metadata),
+		})
+	}
+	return hops
+}
+
+// resolveFallbackModel maps a configured fallback entry to its providers, base model and
+// thinking metadata, mirroring how handlers resolve a client supplied model name.
+func resolveFallbackModel(entry string) ([]string, string, map[string]any) {
+	if provider, model, ok := strings.Cut(entry, "://"); ok && provider != "" && model != "" {
+		return []string{provider}, model, nil
+	}
+	model, metadata := util.NormalizeGeminiThinkingModel(entry)
+	return util.GetProviderName(model), model, metadata
+}
+
+// fallbackRequest rewrites req for a fallback model. The payload keeps the client format;
+// executors translate it again for the fallback provider.
+func fallbackRequest(req cliproxyexecutor.Request, model string, metadata map[string]any) cliproxyexecutor.Request {
+	out := req
+	out.Model = model
+	out.Metadata = fallbackMetadata(req.Metadata, metadata)
+	if len(req.Payload) > 0 && gjson.GetBytes(req.Payload, "model").Exists() {
+		if updated, err := sjson.SetBytes(req.Payload, "model", model); err == nil {
+			out.Payload = updated
+		}
+	}
+	return out
+}
+
+func fallbackOptions(opts cliproxyexecutor.Options, metadata map[string]any) cliproxyexecutor.Options {
+	out := opts
+	out.Metadata = fallbackMetadata(opts.Metadata, metadata)
+	return out
+}
+
+// fallbackMetadata drops thinking hints derived from the original model name and applies
+// the ones derived from the fallback entry.
+func fallbackMetadata(base, overlay map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(overlay))
+	for k, v := range base {
+		switch k {
+		case util.GeminiOriginalModelMetadataKey, util.GeminiThinkingBudgetMetadataKey, util.GeminiIncludeThoughtsMetadataKey:
+			continue
+		}
+		out[k] = v
+	}
+	for k, v := range overlay {
+		out[k] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// shouldFallback reports whether a failed model attempt may move on to the next fallback.
+// Failures caused by the request itself would repeat on any model and are returned as is.
+func shouldFallback(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if isSelectionError(err) {
+		return true
+	}
+	switch status := statusCodeFromError(err); {
+	case status == 0:
+		return true
+	case status >= http.StatusInternalServerError:
+		return true
+	default:
+		switch status {
+		case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden,
+			http.StatusNotFound, http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+	}
+	return false
+}
+
+// ServedModelObserver receives the provider and model that produced a successful response.
+type ServedModelObserver func(provider, model string)
+
+type servedModelContextKey struct{}
+
+// WithServedModelObserver returns a context that reports which provider and model answered
+// a request executed through Manager. Streaming requests report before the stream is returned.
+func WithServedModelObserver(ctx context.Context, observer ServedModelObserver) context.Context {
+	if observer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, servedModelContextKey{}, observer)
+}
+
+func notifyServedModel(ctx context.Context, provider, model string) {
+	if ctx == nil {
+		return
+	}
+	if observer, ok := ctx.Value(servedModelContextKey{}).(ServedModelObserver); ok && observer != nil {
+		observer(provider, model)
+	}
+}
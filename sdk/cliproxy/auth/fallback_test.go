@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestExecuteFallsBackToNextModel(t *testing.T) {
+	primary := &retryTestExecutor{provider: "fallback-primary", failures: 100, status: http.StatusTooManyRequests}
+	m := newRetryTestManager(t, primary, "fallback-primary-auth", "fallback-primary-model")
+	secondary := &retryTestExecutor{provider: "fallback-secondary"}
+	registry.GetGlobalRegistry().RegisterClient("fallback-secondary-auth", secondary.provider, []*registry.ModelInfo{{ID: "fallback-secondary-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("fallback-secondary-auth") })
+	m.RegisterExecutor(secondary)
+	if _, err := m.Register(context.Background(), &Auth{ID: "fallback-secondary-auth", Provider: secondary.provider, Status: StatusActive}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	m.SetModelFallbacks(map[string][]string{"Fallback-Primary-Model": {"unknown-model", "fallback-secondary-model"}})
+
+	var servedProvider, servedModel string
+	ctx := WithServedModelObserver(context.Background(), func(provider, model string) {
+		servedProvider, servedModel = provider, model
+	})
+	req := cliproxyexecutor.Request{Model: "fallback-primary-model", Payload: []byte(`{"model":"fallback-primary-model"}`)}
+	if _, err := m.Execute(ctx, []string{primary.provider}, req, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("expected fallback to succeed, got %v", err)
+	}
+	if servedProvider != secondary.provider || servedModel != "fallback-secondary-model" {
+		t.Fatalf("unexpected served model %s/%s", servedProvider, servedModel)
+	}
+	if got := primary.calls.Load(); got != 1 {
+		t.Fatalf("expected one primary call, got %d", got)
+	}
+}
+
+func TestExecuteReturnsLastFallbackError(t *testing.T) {
+	primary := &retryTestExecutor{provider: "fallback-last-primary", failures: 100, status: http.StatusTooManyRequests}
+	m := newRetryTestManager(t, primary, "fallback-last-primary-auth", "fallback-last-primary-model")
+	m.SetRetryPolicy(RetryPolicy{MaxRetries: 0})
+	secondary := &retryTestExecutor{provider: "fallback-last-secondary", failures: 100, status: http.StatusServiceUnavailable}
+	registry.GetGlobalRegistry().RegisterClient("fallback-last-secondary-auth", secondary.provider, []*registry.ModelInfo{{ID: "fallback-last-secondary-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("fallback-last-secondary-auth") })
+	m.RegisterExecutor(secondary)
+	if _, err := m.Register(context.Background(), &Auth{ID: "fallback-last-secondary-auth", Provider: secondary.provider, Status: StatusActive}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	m.SetModelFallbacks(map[string][]string{"fallback-last-primary-model": {"fallback-last-secondary-model"}})
+
+	req := cliproxyexecutor.Request{Model: "fallback-last-primary-model"}
+	_, err := m.Execute(context.Background(), []string{primary.provider}, req, cliproxyexecutor.Options{})
+	if status := statusCodeFromError(err); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last fallback error (503), got %d: %v", status, err)
+	}
+}
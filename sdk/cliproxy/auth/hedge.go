@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	defaultHedgePercentile = 95
+	defaultHedgeMinSamples = 20
+	defaultHedgeMaxRatio   = 0.05
+	// hedgeLatencyWindow is the number of recent latencies kept per model.
+	hedgeLatencyWindow = 256
+	// hedgeBudgetBurst caps how many hedges may be saved up while traffic is quiet.
+	hedgeBudgetBurst = 10
+)
+
+// Hedge roles reported through HedgeRoleFromContext once a hedge has been launched.
+const (
+	// HedgePrimary marks the original attempt of a hedged request.
+	HedgePrimary = "primary"
+	// HedgeSecondary marks the extra attempt launched after the hedge delay.
+	HedgeSecondary = "hedge"
+)
+
+// errHedgeLost is the cancellation cause of the attempt that lost a hedge race.
+var errHedgeLost = errors.New("hedged request lost the race")
+
+// HedgeConfig controls hedged non-streaming requests. When the primary attempt has not
+// answered within the model's latency percentile, a second attempt is sent to another auth
+// or provider and the first success wins.
+type HedgeConfig struct {
+	// Enabled turns hedging on.
+	Enabled bool
+	// Percentile of recent successful latencies after which a hedge is launched (default 95).
+	Percentile float64
+	// MinSamples is the number of latencies a model needs before it is hedged (default 20).
+	MinSamples int
+	// MinDelay is the lower bound for the hedge delay.
+	MinDelay time.Duration
+	// MaxRatio caps hedges as a fraction of requests so quota burn stays bounded (default 0.05).
+	MaxRatio float64
+}
+
+// hedgeTracker keeps recent latencies per model and the hedge budget.
+type hedgeTracker struct {
+	mu        sync.Mutex
+	cfg       HedgeConfig
+	latencies map[string]*latencyWindow
+	budget    float64
+}
+
+// latencyWindow is a fixed-size ring of recent latencies.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func newHedgeTracker() *hedgeTracker {
+	return &hedgeTracker{latencies: make(map[string]*latencyWindow)}
+}
+
+func (t *hedgeTracker) configure(cfg HedgeConfig) {
+	if cfg.Percentile <= 0 || cfg.Percentile > 100 {
+		cfg.Percentile = defaultHedgePercentile
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = defaultHedgeMinSamples
+	}
+	if cfg.MinDelay < 0 {
+		cfg.MinDelay = 0
+	}
+	if cfg.MaxRatio <= 0 {
+		cfg.MaxRatio = defaultHedgeMaxRatio
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+	if !cfg.Enabled {
+		t.budget = 0
+	}
+}
+
+func (t *hedgeTracker) observe(model string, latency time.Duration) {
+	if model == "" || latency <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	window, ok := t.latencies[model]
+	if !ok {
+		window = &latencyWindow{samples: make([]time.Duration, 0, hedgeLatencyWindow)}
+		t.latencies[model] = window
+	}
+	if len(window.samples) < hedgeLatencyWindow {
+		window.samples = append(window.samples, latency)
+		return
+	}
+	window.samples[window.next] = latency
+	window.next = (window.next + 1) % hedgeLatencyWindow
+}
+
+// delay returns how long to wait before hedging a request for the model. ok is false when
+// hedging is disabled or the model has too few samples. Every eligible request earns
+// MaxRatio of a hedge, so hedges can never exceed that share of traffic.
+func (t *hedgeTracker) delay(model string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.cfg.Enabled {
+		return 0, false
+	}
+	window, ok := t.latencies[model]
+	if !ok || len(window.samples) < t.cfg.MinSamples {
+		return 0, false
+	}
+	t.budget = math.Min(t.budget+t.cfg.MaxRatio, hedgeBudgetBurst)
+	sorted := append([]time.Duration(nil), window.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(math.Ceil(t.cfg.Percentile/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	d := sorted[idx]
+	if d < t.cfg.MinDelay {
+		d = t.cfg.MinDelay
+	}
+	return d, true
+}
+
+// take spends one hedge from the budget.
+func (t *hedgeTracker) take() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.budget < 1 {
+		return false
+	}
+	t.budget--
+	return true
+}
+
+// SetHedging applies the hedging settings. Collected latencies survive reconfiguration.
+func (m *Manager) SetHedging(cfg HedgeConfig) {
+	m.hedges.configure(cfg)
+}
+
+// hedgeRace is shared by the attempts of one hedged request.
+type hedgeRace struct {
+	mu       sync.Mutex
+	launched bool
+}
+
+type hedgeAttempt struct {
+	race *hedgeRace
+	role string
+}
+
+type hedgeContextKey struct{}
+
+// HedgeRoleFromContext returns HedgePrimary or HedgeSecondary when the executing attempt is
+// part of a request that launched a hedge, and "" otherwise.
+func HedgeRoleFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	attempt, ok := ctx.Value(hedgeContextKey{}).(*hedgeAttempt)
+	if !ok || attempt == nil {
+		return ""
+	}
+	attempt.race.mu.Lock()
+	defer attempt.race.mu.Unlock()
+	if !attempt.race.launched {
+		return ""
+	}
+	return attempt.role
+}
+
+// HedgeCanceledFromContext reports whether the executing attempt lost a hedge race and was
+// canceled. Such attempts are not failures of the request or of the credential.
+func HedgeCanceledFromContext(ctx context.Context) bool {
+	return ctx != nil && errors.Is(context.Cause(ctx), errHedgeLost)
+}
+
+type hedgeOutcome struct {
+	resp cliproxyexecutor.Response
+	err  error
+	idx  int
+}
+
+// executeHedged runs the primary attempt and, if it has not answered after delay, a second
+// attempt on another auth of the same provider or on one of the alternate providers. The
+// first success wins and the other attempt is canceled. It returns the last error when every
+// attempt fails.
+func (m *Manager) executeHedged(ctx context.Context, provider string, alternates []string, primary *authPick, tried map[string]struct{}, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, delay time.Duration) (cliproxyexecutor.Response, error) {
+	race := &hedgeRace{}
+	outcomes := make(chan hedgeOutcome, 2)
+	var cancels []context.CancelCauseFunc
+	launch := func(attemptProvider string, pick *authPick, role string) {
+		attemptCtx, cancel := context.WithCancelCause(ctx)
+		attemptCtx = context.WithValue(attemptCtx, hedgeContextKey{}, &hedgeAttempt{race: race, role: role})
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := m.executeOnce(attemptCtx, attemptProvider, pick, req, opts)
+			outcomes <- hedgeOutcome{resp: resp, err: err, idx: idx}
+		}()
+	}
+	launch(provider, primary, HedgePrimary)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending := 1
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			hedgeProvider, pick, ok := m.pickHedge(ctx, provider, alternates, req.Model, opts, tried)
+			if !ok {
+				continue
+			}
+			if !m.hedges.take() {
+				pick.release()
+				continue
+			}
+			tried[pick.auth.ID] = struct{}{}
+			race.mu.Lock()
+			race.launched = true
+			race.mu.Unlock()
+			launch(hedgeProvider, pick, HedgeSecondary)
+			pending++
+		case outcome := <-outcomes:
+			pending--
+			if outcome.err == nil {
+				for i, cancel := range cancels {
+					if i != outcome.idx {
+						cancel(errHedgeLost)
+					}
+				}
+				cancels[outcome.idx](nil)
+				return outcome.resp, nil
+			}
+			cancels[outcome.idx](nil)
+			lastErr = outcome.err
+		}
+	}
+	return cliproxyexecutor.Response{}, lastErr
+}
+
+// pickHedge picks an untried auth with a free slot for a hedge, preferring the primary's
+// provider. Hedges never queue for a slot.
+func (m *Manager) pickHedge(ctx context.Context, provider string, alternates []string, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (string, *authPick, bool) {
+	for i, candidate := range append([]string{provider}, alternates...) {
+		if i > 0 && candidate == provider {
+			continue
+		}
+		pick, err := m.acquireNext(ctx, candidate, model, opts, tried, false)
+		if err == nil {
+			return candidate, pick, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// hedgeTestExecutor stalls on the "hedge-a-slow" auth until canceled and answers at once on others.
+type hedgeTestExecutor struct {
+	retryTestExecutor
+}
+
+func (e *hedgeTestExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if auth.ID == "hedge-a-slow" {
+		<-ctx.Done()
+		return cliproxyexecutor.Response{}, ctx.Err()
+	}
+	return cliproxyexecutor.Response{Payload: []byte(auth.ID)}, nil
+}
+
+type hedgeTestHook struct {
+	NoopHook
+	mu      sync.Mutex
+	results []Result
+}
+
+func (h *hedgeTestHook) OnResult(_ context.Context, result Result) {
+	h.mu.Lock()
+	h.results = append(h.results, result)
+	h.mu.Unlock()
+}
+
+func (h *hedgeTestHook) snapshot() []Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Result(nil), h.results...)
+}
+
+func TestExecuteHedgesSlowPrimary(t *testing.T) {
+	exec := &hedgeTestExecutor{retryTestExecutor{provider: "hedge-test"}}
+	hook := &hedgeTestHook{}
+	m := NewManager(nil, &FillFirstSelector{}, hook)
+	m.RegisterExecutor(exec)
+	for _, id := range []string{"hedge-a-slow", "hedge-b-fast"} {
+		registry.GetGlobalRegistry().RegisterClient(id, exec.provider, []*registry.ModelInfo{{ID: "hedge-model"}})
+		authID := id
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: exec.provider, Status: StatusActive}); err != nil {
+			t.Fatalf("register auth: %v", err)
+		}
+	}
+	m.SetHedging(HedgeConfig{Enabled: true, MinSamples: 1, MaxRatio: 1})
+	m.hedges.observe("hedge-model", 10*time.Millisecond)
+
+	resp, err := m.Execute(context.Background(), []string{"hedge-test"}, cliproxyexecutor.Request{Model: "hedge-model"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("expected hedge to succeed, got %v", err)
+	}
+	if string(resp.Payload) != "hedge-b-fast" {
+		t.Fatalf("expected hedge auth to answer, got %q", resp.Payload)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(hook.snapshot()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	var canceled, succeeded bool
+	for _, result := range hook.snapshot() {
+		switch {
+		case result.AuthID == "hedge-a-slow" && result.Canceled:
+			canceled = true
+		case result.AuthID == "hedge-b-fast" && result.Success:
+			succeeded = true
+		}
+	}
+	if !canceled || !succeeded {
+		t.Fatalf("expected a canceled primary and a successful hedge, got %+v", hook.snapshot())
+	}
+	loser, _ := m.GetByID("hedge-a-slow")
+	if blocked, _, _ := isAuthBlockedForModel(loser, "hedge-model", time.Now()); blocked {
+		t.Fatalf("canceled hedge loser must not be blocked")
+	}
+}
+
+func TestHedgeBudgetCapsRatio(t *testing.T) {
+	tracker := newHedgeTracker()
+	tracker.configure(HedgeConfig{Enabled: true, MinSamples: 1, MaxRatio: 0.25})
+	tracker.observe("model", time.Second)
+	hedges := 0
+	for i := 0; i < 100; i++ {
+		if _, ok := tracker.delay("model"); ok && tracker.take() {
+			hedges++
+		}
+	}
+	if hedges != 25 {
+		t.Fatalf("expected 25 hedges for 100 requests at ratio 0.25, got %d", hedges)
+	}
+}
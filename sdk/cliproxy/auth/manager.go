@@ -32,6 +32,12 @@ type ProviderExecutor interface {
 	CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
 }
 
+// EmbeddingExecutor is implemented by provider executors that serve embedding models. The
+// request payload and the response use opts.SourceFormat.
+type EmbeddingExecutor interface {
+	Embed(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
 // RefreshEvaluator allows runtime state to override refresh decisions.
 type RefreshEvaluator interface {
 	ShouldRefresh(now time.Time, auth *Auth) bool
@@ -64,8 +70,15 @@ type Result struct {
 	Success bool
 	// RetryAfter carries a provider supplied retry hint (e.g. 429 retryDelay).
 	RetryAfter *time.Duration
+	// Latency is the wall time of the execution; for streams it spans the whole stream.
+	Latency time.Duration
+	// TTFT is the time until the first streamed chunk arrived; zero for non-streaming calls.
+	TTFT time.Duration
 	// Error describes the failure when Success is false.
 	Error *Error
+	// Canceled marks an attempt abandoned by the manager, such as the loser of a hedge race,
+	// or by the client. Hooks observe it but it leaves the auth state untouched.
+	Canceled bool
 }
 
 // Selector chooses an auth candidate for execution.
@@ -101,14 +114,45 @@ type Manager struct {
 	executors map[string]ProviderExecutor
 	selector  Selector
 	hook      Hook
-	mu        sync.RWMutex
-	auths     map[string]*Auth
+	// baseSelector is the selector used when no selection strategy is configured.
+	baseSelector Selector
+	// strategies caches selectors built by SetSelectionStrategy, keyed by strategy name.
+	strategies map[string]Selector
+	// inFlight counts requests currently executing per auth.
+	inFlight inFlightTracker
+	// affinity pins affinity keys to auths for sticky selection.
+	affinity *sessionAffinity
+	// scores tracks latency, time-to-first-token and error-rate moving averages.
+	scores *scoreTracker
+	// hedges tracks per-model latencies and the budget for hedged requests.
+	hedges *hedgeTracker
+	// concurrency enforces per-auth concurrency limits and queues waiting requests.
+	concurrency *concurrencyLimiter
+	// streamTTFTTimeout bounds the wait for the first stream payload, in nanoseconds.
+	streamTTFTTimeout atomic.Int64
+	mu                sync.RWMutex
+	auths             map[string]*Auth
 	// providerOffsets tracks per-model provider rotation state for multi-provider routing.
 	providerOffsets map[string]int
 
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// retryPolicy holds the active retry policy; swapped atomically on config reload.
+	retryPolicy atomic.Pointer[RetryPolicy]
+	// modelFallbacks maps lower-cased model names to their fallback chains.
+	modelFallbacks atomic.Pointer[map[string][]string]
+
+	// stateMu guards the debounced runtime state persistence and stateSyncCancel.
+	stateMu           sync.Mutex
+	stateFlushPending bool
+	stateLastSaved    []byte
+	// persistedState holds state loaded from the store for auths that are registered after
+	// Load, such as config API keys. It is guarded by mu and consumed on registration.
+	persistedState map[string]*AuthState
+	// stateSyncCancel stops the listener for state shared by other replicas.
+	stateSyncCancel context.CancelFunc
+
 	// Auto refresh state
 	refreshCancel context.CancelFunc
 }
@@ -126,6 +170,12 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		executors:       make(map[string]ProviderExecutor),
 		selector:        selector,
 		hook:            hook,
+		baseSelector:    selector,
+		strategies:      make(map[string]Selector),
+		affinity:        newSessionAffinity(),
+		scores:          newScoreTracker(),
+		hedges:          newHedgeTracker(),
+		concurrency:     newConcurrencyLimiter(),
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
 	}
@@ -175,12 +225,14 @@ func (m *Manager) Register(ctx context.Context, auth *Auth) (*Auth, error) {
 	if auth.ID == "" {
 		auth.ID = uuid.NewString()
 	}
+	stored := auth.Clone()
 	m.mu.Lock()
-	m.auths[auth.ID] = auth.Clone()
+	m.applyPersistedStateLocked(stored)
+	m.auths[auth.ID] = stored
 	m.mu.Unlock()
 	_ = m.persist(ctx, auth)
-	m.hook.OnAuthRegistered(ctx, auth.Clone())
-	return auth.Clone(), nil
+	m.hook.OnAuthRegistered(ctx, stored.Clone())
+	return stored.Clone(), nil
 }
 
 // Update replaces an existing auth entry and notifies hooks.
@@ -189,15 +241,21 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 		return nil, nil
 	}
 	auth.EnsureIndex()
+	stored := auth.Clone()
 	m.mu.Lock()
-	m.auths[auth.ID] = auth.Clone()
+	m.applyPersistedStateLocked(stored)
+	m.auths[auth.ID] = stored
 	m.mu.Unlock()
+	if auth.Disabled {
+		m.scores.forget(auth.ID)
+	}
 	_ = m.persist(ctx, auth)
-	m.hook.OnAuthUpdated(ctx, auth.Clone())
-	return auth.Clone(), nil
+	m.hook.OnAuthUpdated(ctx, stored.Clone())
+	return stored.Clone(), nil
 }
 
-// Load resets manager state from the backing store.
+// Load resets manager state from the backing store. Cooldown, quota and per-model state
+// persisted through a StateStore is restored; expired windows are dropped.
 func (m *Manager) Load(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -216,26 +274,75 @@ func (m *Manager) Load(ctx context.Context) error {
 		auth.EnsureIndex()
 		m.auths[auth.ID] = auth.Clone()
 	}
+	m.scores.retain(m.auths)
+	m.restoreStateLocked(ctx)
 	return nil
 }
 
 // Execute performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When every candidate fails with a retryable status, it backs off and retries per the RetryPolicy.
+// If the model still cannot be served, its configured fallback models are tried in order.
+// With hedging enabled, slow attempts are raced against a second auth or provider.
 func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
-	rotated := m.rotateProviders(req.Model, normalized)
-	defer m.advanceProviderCursor(req.Model, normalized)
 
+	hops := m.fallbackChain(ctx, normalized, req, opts)
+	retry := m.newRetryState()
 	var lastErr error
-	for _, provider := range rotated {
-		resp, errExec := m.executeWithProvider(ctx, provider, req, opts)
+	for idx, hop := range hops {
+		retry.beginHop(idx+1 < len(hops))
+		resp, errExec := m.executeModel(ctx, retry, hop.providers, hop.req, hop.opts)
 		if errExec == nil {
 			return resp, nil
 		}
-		lastErr = errExec
+		lastErr = preferUpstreamError(lastErr, errExec)
+		if !shouldFallback(ctx, errExec) {
+			break
+		}
+		if idx+1 < len(hops) {
+			log.Debugf("model %s unavailable, falling back to %s: %v", hop.req.Model, hops[idx+1].req.Model, errExec)
+		}
+	}
+	return cliproxyexecutor.Response{}, lastErr
+}
+
+// executeModel runs a single model across its providers, retrying per the RetryPolicy.
+func (m *Manager) executeModel(ctx context.Context, retry *retryState, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	rotated := m.rotateProviders(req.Model, providers)
+	defer m.advanceProviderCursor(req.Model, providers)
+
+	var lastErr error
+	for {
+		busy := -1
+		for i, provider := range rotated {
+			alternates := append(append([]string(nil), rotated[i+1:]...), rotated[:i]...)
+			resp, errExec := m.executeWithProvider(providerAttemptContext(retry.context(ctx), rotated), provider, alternates, req, opts)
+			if errExec == nil {
+				notifyServedModel(ctx, provider, req.Model)
+				return resp, nil
+			}
+			if busy < 0 && isAtCapacity(errExec) {
+				busy = i
+			}
+			lastErr = preferUpstreamError(lastErr, errExec)
+		}
+		if busy >= 0 && len(rotated) > 1 {
+			// Every provider failed; wait for a slot on the first one that was only busy.
+			alternates := append(append([]string(nil), rotated[busy+1:]...), rotated[:busy]...)
+			resp, errExec := m.executeWithProvider(retry.context(ctx), rotated[busy], alternates, req, opts)
+			if errExec == nil {
+				notifyServedModel(ctx, rotated[busy], req.Model)
+				return resp, nil
+			}
+			lastErr = preferUpstreamError(lastErr, errExec)
+		}
+		if !m.waitForRetry(ctx, retry, rotated, req.Model, lastErr) {
+			break
+		}
 	}
 	if lastErr != nil {
 		return cliproxyexecutor.Response{}, lastErr
@@ -246,6 +353,30 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 // ExecuteCount performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
 func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeCall(ctx, providers, req, opts, func(execCtx context.Context, executor ProviderExecutor, auth *Auth) (cliproxyexecutor.Response, error) {
+		return executor.CountTokens(execCtx, auth, req, opts)
+	})
+}
+
+// ExecuteEmbed computes embeddings with the providers whose executor implements
+// EmbeddingExecutor. Providers are rotated and retried like ExecuteCount.
+func (m *Manager) ExecuteEmbed(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	supported := make([]string, 0, len(providers))
+	for _, provider := range m.normalizeProviders(providers) {
+		if _, ok := m.executorFor(provider).(EmbeddingExecutor); ok {
+			supported = append(supported, provider)
+		}
+	}
+	if len(supported) == 0 {
+		return cliproxyexecutor.Response{}, &Error{Code: "not_supported", Message: "no provider serving model " + req.Model + " supports embeddings", HTTPStatus: http.StatusNotImplemented}
+	}
+	return m.executeCall(ctx, supported, req, opts, func(execCtx context.Context, executor ProviderExecutor, auth *Auth) (cliproxyexecutor.Response, error) {
+		return executor.(EmbeddingExecutor).Embed(execCtx, auth, req, opts)
+	})
+}
+
+// executeCall runs a single-shot call such as token counting across providers, retrying per the RetryPolicy.
+func (m *Manager) executeCall(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, call providerCall) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -253,13 +384,30 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
+	retry := m.newRetryState()
 	var lastErr error
-	for _, provider := range rotated {
-		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts)
-		if errExec == nil {
-			return resp, nil
+	for {
+		busy := ""
+		for _, provider := range rotated {
+			resp, errExec := m.executeCallWithProvider(providerAttemptContext(retry.context(ctx), rotated), provider, req, opts, call)
+			if errExec == nil {
+				return resp, nil
+			}
+			if busy == "" && isAtCapacity(errExec) {
+				busy = provider
+			}
+			lastErr = preferUpstreamError(lastErr, errExec)
+		}
+		if busy != "" && len(rotated) > 1 {
+			resp, errExec := m.executeCallWithProvider(retry.context(ctx), busy, req, opts, call)
+			if errExec == nil {
+				return resp, nil
+			}
+			lastErr = preferUpstreamError(lastErr, errExec)
+		}
+		if !m.waitForRetry(ctx, retry, rotated, req.Model, lastErr) {
+			break
 		}
-		lastErr = errExec
 	}
 	if lastErr != nil {
 		return cliproxyexecutor.Response{}, lastErr
@@ -269,21 +417,64 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 
 // ExecuteStream performs a streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// Streams that fail or time out before their first payload chunk fail over like non-streaming calls.
+// If the model cannot be served, its configured fallback models are tried in order.
 func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
-	rotated := m.rotateProviders(req.Model, normalized)
-	defer m.advanceProviderCursor(req.Model, normalized)
 
+	hops := m.fallbackChain(ctx, normalized, req, opts)
+	retry := m.newRetryState()
 	var lastErr error
-	for _, provider := range rotated {
-		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts)
+	for idx, hop := range hops {
+		retry.beginHop(idx+1 < len(hops))
+		chunks, errStream := m.executeStreamModel(ctx, retry, hop.providers, hop.req, hop.opts)
 		if errStream == nil {
 			return chunks, nil
 		}
-		lastErr = errStream
+		lastErr = preferUpstreamError(lastErr, errStream)
+		if !shouldFallback(ctx, errStream) {
+			break
+		}
+		if idx+1 < len(hops) {
+			log.Debugf("model %s unavailable, falling back to %s: %v", hop.req.Model, hops[idx+1].req.Model, errStream)
+		}
+	}
+	return nil, lastErr
+}
+
+// executeStreamModel starts a stream for a single model across its providers, retrying per the RetryPolicy.
+func (m *Manager) executeStreamModel(ctx context.Context, retry *retryState, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	rotated := m.rotateProviders(req.Model, providers)
+	defer m.advanceProviderCursor(req.Model, providers)
+
+	var lastErr error
+	for {
+		busy := ""
+		for _, provider := range rotated {
+			chunks, errStream := m.executeStreamWithProvider(providerAttemptContext(retry.context(ctx), rotated), provider, req, opts)
+			if errStream == nil {
+				notifyServedModel(ctx, provider, req.Model)
+				return chunks, nil
+			}
+			if busy == "" && isAtCapacity(errStream) {
+				busy = provider
+			}
+			lastErr = preferUpstreamError(lastErr, errStream)
+		}
+		if busy != "" && len(rotated) > 1 {
+			chunks, errStream := m.executeStreamWithProvider(retry.context(ctx), busy, req, opts)
+			if errStream == nil {
+				notifyServedModel(ctx, busy, req.Model)
+				return chunks, nil
+			}
+			lastErr = preferUpstreamError(lastErr, errStream)
+		}
+		if !m.waitForRetry(ctx, retry, rotated, req.Model, lastErr) {
+			break
+		}
 	}
 	if lastErr != nil {
 		return nil, lastErr
@@ -291,14 +482,14 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
-func (m *Manager) executeWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeWithProvider(ctx context.Context, provider string, alternates []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		pick, errPick := m.acquireNext(ctx, provider, req.Model, opts, tried, true)
 		if errPick != nil {
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
@@ -306,47 +497,70 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 			return cliproxyexecutor.Response{}, errPick
 		}
 
-		accountType, accountInfo := auth.AccountInfo()
-		if accountType == "api_key" {
-			log.Debugf("Use API key %s for model %s", util.HideAPIKey(accountInfo), req.Model)
-		} else if accountType == "oauth" {
-			log.Debugf("Use OAuth %s for model %s", accountInfo, req.Model)
-		}
-
-		tried[auth.ID] = struct{}{}
-		execCtx := ctx
-		if rt := m.roundTripperFor(auth); rt != nil {
-			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+		tried[pick.auth.ID] = struct{}{}
+		var resp cliproxyexecutor.Response
+		var errExec error
+		if delay, ok := m.hedges.delay(req.Model); ok {
+			resp, errExec = m.executeHedged(ctx, provider, alternates, pick, tried, req, opts, delay)
+		} else {
+			resp, errExec = m.executeOnce(ctx, provider, pick, req, opts)
 		}
-		resp, errExec := executor.Execute(execCtx, auth, req, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
-			if ra := retryAfterFromError(errExec); ra != nil {
-				result.RetryAfter = ra
-			}
-			m.MarkResult(execCtx, result)
 			lastErr = errExec
 			continue
 		}
-		m.MarkResult(execCtx, result)
 		return resp, nil
 	}
 }
 
-func (m *Manager) executeCountWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+// executeOnce runs a single non-streaming attempt on the picked auth and records its result.
+func (m *Manager) executeOnce(ctx context.Context, provider string, pick *authPick, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	auth := pick.auth
+	accountType, accountInfo := auth.AccountInfo()
+	if accountType == "api_key" {
+		log.Debugf("Use API key %s for model %s", util.HideAPIKey(accountInfo), req.Model)
+	} else if accountType == "oauth" {
+		log.Debugf("Use OAuth %s for model %s", accountInfo, req.Model)
+	}
+
+	execCtx := pick.context(ctx)
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	started := time.Now()
+	resp, errExec := pick.executor.Execute(execCtx, auth, req, opts)
+	pick.release()
+	result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil, Latency: time.Since(started)}
+	if errExec != nil {
+		result.Error = &Error{Message: errExec.Error()}
+		var se cliproxyexecutor.StatusError
+		if errors.As(errExec, &se) && se != nil {
+			result.Error.HTTPStatus = se.StatusCode()
+		}
+		if ra := retryAfterFromError(errExec); ra != nil {
+			result.RetryAfter = ra
+		}
+		result.Canceled = HedgeCanceledFromContext(execCtx)
+		m.MarkResult(execCtx, result)
+		return cliproxyexecutor.Response{}, errExec
+	}
+	m.hedges.observe(req.Model, result.Latency)
+	m.MarkResult(execCtx, result)
+	return resp, nil
+}
+
+// providerCall performs one attempt of a single-shot call on the picked auth.
+type providerCall func(ctx context.Context, executor ProviderExecutor, auth *Auth) (cliproxyexecutor.Response, error)
+
+func (m *Manager) executeCallWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, call providerCall) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		pick, errPick := m.acquireNext(ctx, provider, req.Model, opts, tried, true)
 		if errPick != nil {
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
@@ -354,6 +568,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 			return cliproxyexecutor.Response{}, errPick
 		}
 
+		auth, executor := pick.auth, pick.executor
 		accountType, accountInfo := auth.AccountInfo()
 		if accountType == "api_key" {
 			log.Debugf("Use API key %s for model %s", util.HideAPIKey(accountInfo), req.Model)
@@ -362,13 +577,15 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx := pick.context(ctx)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
-		resp, errExec := executor.CountTokens(execCtx, auth, req, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
+		started := time.Now()
+		resp, errExec := call(execCtx, executor, auth)
+		pick.release()
+		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil, Latency: time.Since(started)}
 		if errExec != nil {
 			result.Error = &Error{Message: errExec.Error()}
 			var se cliproxyexecutor.StatusError
@@ -394,7 +611,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		pick, errPick := m.acquireNext(ctx, provider, req.Model, opts, tried, true)
 		if errPick != nil {
 			if lastErr != nil {
 				return nil, lastErr
@@ -402,6 +619,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 			return nil, errPick
 		}
 
+		auth, executor, release := pick.auth, pick.executor, pick.release
 		accountType, accountInfo := auth.AccountInfo()
 		if accountType == "api_key" {
 			log.Debugf("Use API key %s for model %s", util.HideAPIKey(accountInfo), req.Model)
@@ -410,42 +628,86 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		// Each attempt gets its own cancelable context so an abandoned stream can be torn down.
+		attemptCtx, cancelAttempt := context.WithCancel(ctx)
+		execCtx := pick.context(attemptCtx)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		started := time.Now()
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, req, opts)
+		var buffered []cliproxyexecutor.StreamChunk
+		open := false
+		if errStream == nil {
+			buffered, open, errStream = awaitFirstPayload(ctx, chunks, m.StreamTTFTTimeout())
+			if errStream != nil {
+				drainStream(chunks)
+			}
+		}
 		if errStream != nil {
+			release()
 			rerr := &Error{Message: errStream.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errStream, &se) && se != nil {
 				rerr.HTTPStatus = se.StatusCode()
 			}
-			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: rerr}
+			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: rerr, Latency: time.Since(started)}
 			result.RetryAfter = retryAfterFromError(errStream)
+			// A client that gave up while waiting for the first chunk says nothing about the auth.
+			result.Canceled = ctx.Err() != nil
 			m.MarkResult(execCtx, result)
+			cancelAttempt()
+			if ctx.Err() != nil {
+				return nil, errStream
+			}
 			lastErr = errStream
 			continue
 		}
+		ttft := time.Since(started)
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
+			defer cancelAttempt()
+			defer release()
+			// A client that stops reading must not pin the concurrency slot: once the attempt
+			// is canceled the rest of the stream is discarded.
+			send := func(chunk cliproxyexecutor.StreamChunk) bool {
+				select {
+				case out <- chunk:
+					return true
+				case <-streamCtx.Done():
+					if open {
+						drainStream(streamChunks)
+					}
+					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Latency: time.Since(started), TTFT: ttft, Canceled: true})
+					return false
+				}
+			}
+			for _, chunk := range buffered {
+				if !send(chunk) {
+					return
+				}
+			}
 			var failed bool
-			for chunk := range streamChunks {
-				if chunk.Err != nil && !failed {
-					failed = true
-					rerr := &Error{Message: chunk.Err.Error()}
-					var se cliproxyexecutor.StatusError
-					if errors.As(chunk.Err, &se) && se != nil {
-						rerr.HTTPStatus = se.StatusCode()
+			if open {
+				for chunk := range streamChunks {
+					if chunk.Err != nil && !failed {
+						failed = true
+						rerr := &Error{Message: chunk.Err.Error()}
+						var se cliproxyexecutor.StatusError
+						if errors.As(chunk.Err, &se) && se != nil {
+							rerr.HTTPStatus = se.StatusCode()
+						}
+						m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: rerr, Latency: time.Since(started), TTFT: ttft})
+					}
+					if !send(chunk) {
+						return
 					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: rerr})
 				}
-				out <- chunk
 			}
 			if !failed {
-				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: true})
+				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: true, Latency: time.Since(started), TTFT: ttft})
 			}
 		}(execCtx, auth.Clone(), provider, chunks)
 		return out, nil
@@ -512,12 +774,18 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	if result.AuthID == "" {
 		return
 	}
+	if result.Canceled {
+		m.hook.OnResult(ctx, result)
+		return
+	}
+	m.scores.observe(result, time.Now())
 
 	shouldResumeModel := false
 	shouldSuspendModel := false
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var shared *AuthState
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
@@ -604,6 +872,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			} else {
 				applyAuthFailureState(auth, result.Error, result.RetryAfter, now)
 			}
+			shared = sharedFailureState(auth, result.Model, now)
 		}
 
 		_ = m.persist(ctx, auth)
@@ -621,6 +890,8 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	} else if shouldSuspendModel {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
+	m.publishState(result.AuthID, shared)
+	m.scheduleStateFlush()
 
 	m.hook.OnResult(ctx, result)
 }
@@ -882,44 +1153,64 @@ func (m *Manager) GetByID(id string) (*Auth, bool) {
 	return auth.Clone(), true
 }
 
-func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, error) {
+func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, string, error) {
 	m.mu.RLock()
 	executor, okExecutor := m.executors[provider]
 	if !okExecutor {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
+		return nil, nil, "", &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
 	candidates := make([]*Auth, 0, len(m.auths))
 	modelKey := strings.TrimSpace(model)
 	registryRef := registry.GetGlobalRegistry()
+	retryPolicy := retryRoundPolicy(ctx)
+	requestPolicy := RequestPolicyFromContext(ctx)
+	atCapacity := 0
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
 		}
+		if !requestPolicy.allowsAuth(candidate) {
+			continue
+		}
 		if _, used := tried[candidate.ID]; used {
 			continue
 		}
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if limit := m.concurrency.limit(candidate); limit > 0 && m.inFlight.load(candidate.ID) >= int64(limit) {
+			atCapacity++
+			continue
+		}
+		if retryPolicy != nil && retryPolicy.liftsBlock(candidate, modelKey) {
+			candidate = withBlockLifted(candidate, modelKey)
+		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+		if atCapacity > 0 {
+			return nil, nil, "", errAuthsAtCapacity
+		}
+		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
+	selected, affinity, errPick := m.selectAuth(ctx, provider, model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
-		return nil, nil, errPick
+		if atCapacity > 0 {
+			// The free auths are cooling down; a busy one will free up sooner.
+			return nil, nil, "", errAuthsAtCapacity
+		}
+		return nil, nil, "", errPick
 	}
 	if selected == nil {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+		return nil, nil, "", &Error{Code: "auth_not_found", Message: "selector returned no auth"}
 	}
 	authCopy := selected.Clone()
 	m.mu.RUnlock()
-	return authCopy, executor, nil
+	return authCopy, executor, affinity, nil
 }
 
 func (m *Manager) persist(ctx context.Context, auth *Auth) error {
@@ -1230,6 +1521,38 @@ func (m *Manager) markRefreshPending(id string, now time.Time) bool {
 	return true
 }
 
+// Refresh renews the credentials of one auth immediately, regardless of its schedule.
+func (m *Manager) Refresh(ctx context.Context, id string) error {
+	m.mu.RLock()
+	auth := m.auths[id]
+	var exec ProviderExecutor
+	if auth != nil {
+		exec = m.executors[auth.Provider]
+	}
+	m.mu.RUnlock()
+	switch {
+	case auth == nil:
+		return &Error{Code: "auth_not_found", Message: "auth not found"}
+	case auth.Disabled:
+		return &Error{Code: "auth_disabled", Message: "auth is disabled"}
+	case exec == nil:
+		return &Error{Code: "provider_not_found", Message: "no executor registered for provider " + auth.Provider}
+	}
+	started := time.Now()
+	m.refreshAuth(ctx, id)
+	current, ok := m.GetByID(id)
+	if !ok {
+		return &Error{Code: "auth_not_found", Message: "auth not found"}
+	}
+	if current.LastRefreshedAt.Before(started) {
+		if current.LastError != nil {
+			return current.LastError
+		}
+		return &Error{Code: "refresh_failed", Message: "refresh did not complete"}
+	}
+	return nil
+}
+
 func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	m.mu.RLock()
 	auth := m.auths[id]
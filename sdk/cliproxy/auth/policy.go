@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// RequestPolicy restricts where a request may be routed. Handlers attach the policy of the
+// calling client key so model fallbacks and credential selection honour it as well.
+type RequestPolicy struct {
+	// AllowModel reports whether a model may serve the request; nil allows every model.
+	AllowModel func(model string) bool
+	// Providers limits routing to these providers when non-empty.
+	Providers []string
+	// APIKeyCredentialsOnly limits the request to credentials that carry an api_key attribute.
+	APIKeyCredentialsOnly bool
+}
+
+type requestPolicyContextKey struct{}
+
+// WithRequestPolicy attaches the routing policy to ctx.
+func WithRequestPolicy(ctx context.Context, policy *RequestPolicy) context.Context {
+	if policy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestPolicyContextKey{}, policy)
+}
+
+// RequestPolicyFromContext returns the routing policy attached to ctx, or nil.
+func RequestPolicyFromContext(ctx context.Context) *RequestPolicy {
+	if ctx == nil {
+		return nil
+	}
+	policy, _ := ctx.Value(requestPolicyContextKey{}).(*RequestPolicy)
+	return policy
+}
+
+// ClientIdentity is the client a request runs for when no HTTP request is in flight, such
+// as a batch the proxy executes on the client's behalf.
+type ClientIdentity struct {
+	// APIKey is the principal the client authenticated with.
+	APIKey string
+	// Metadata is the access metadata of the principal, such as its scopes.
+	Metadata map[string]string
+}
+
+type clientIdentityContextKey struct{}
+
+// WithClientIdentity attaches the client identity to ctx, so key policies, rate limits and
+// usage attribution apply as if the client had sent the request itself.
+func WithClientIdentity(ctx context.Context, identity ClientIdentity) context.Context {
+	return context.WithValue(ctx, clientIdentityContextKey{}, identity)
+}
+
+// ClientIdentityFromContext returns the client identity attached to ctx.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	if ctx == nil {
+		return ClientIdentity{}, false
+	}
+	identity, ok := ctx.Value(clientIdentityContextKey{}).(ClientIdentity)
+	return identity, ok
+}
+
+func (p *RequestPolicy) allowsModel(model string) bool {
+	return p == nil || p.AllowModel == nil || p.AllowModel(model)
+}
+
+func (p *RequestPolicy) allowsProvider(provider string) bool {
+	if p == nil || len(p.Providers) == 0 {
+		return true
+	}
+	for _, allowed := range p.Providers {
+		if strings.EqualFold(strings.TrimSpace(allowed), provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterProviders drops the providers the policy does not allow.
+func (p *RequestPolicy) filterProviders(providers []string) []string {
+	if p == nil || len(p.Providers) == 0 {
+		return providers
+	}
+	out := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if p.allowsProvider(provider) {
+			out = append(out, provider)
+		}
+	}
+	return out
+}
+
+// allowsAuth reports whether the auth may serve a request under the policy.
+func (p *RequestPolicy) allowsAuth(auth *Auth) bool {
+	if p == nil || auth == nil {
+		return true
+	}
+	if !p.allowsProvider(auth.Provider) {
+		return false
+	}
+	if p.APIKeyCredentialsOnly && strings.TrimSpace(auth.Attributes["api_key"]) == "" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestRequestPolicyKeepsRequestOffOAuthCredentials(t *testing.T) {
+	exec := &hedgeTestExecutor{retryTestExecutor{provider: "policy-test"}}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(exec)
+	auths := []*Auth{
+		{ID: "policy-oauth", Provider: "policy-test", Status: StatusActive, Metadata: map[string]any{"email": "user@example.com"}},
+		{ID: "policy-oauth-no-email", Provider: "policy-test", Status: StatusActive, Metadata: map[string]any{"type": "policy-test", "access_token": "token"}},
+		{ID: "policy-apikey", Provider: "policy-test", Status: StatusActive, Attributes: map[string]string{"api_key": "sk-test"}},
+	}
+	for _, auth := range auths {
+		registry.GetGlobalRegistry().RegisterClient(auth.ID, "policy-test", []*registry.ModelInfo{{ID: "policy-model"}})
+		authID := auth.ID
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+		if _, err := m.Register(context.Background(), auth); err != nil {
+			t.Fatalf("register auth: %v", err)
+		}
+	}
+
+	ctx := WithRequestPolicy(context.Background(), &RequestPolicy{APIKeyCredentialsOnly: true})
+	for i := 0; i < 6; i++ {
+		resp, err := m.Execute(ctx, []string{"policy-test"}, cliproxyexecutor.Request{Model: "policy-model"}, cliproxyexecutor.Options{})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		if got := string(resp.Payload); got != "policy-apikey" {
+			t.Fatalf("expected the API key credential, got %s", got)
+		}
+	}
+
+	ctx = WithRequestPolicy(context.Background(), &RequestPolicy{Providers: []string{"other"}})
+	if _, err := m.Execute(ctx, []string{"policy-test"}, cliproxyexecutor.Request{Model: "policy-model"}, cliproxyexecutor.Options{}); err == nil {
+		t.Fatal("expected no credential outside the allowed providers")
+	}
+}
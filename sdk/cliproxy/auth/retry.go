@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryBaseDelay  = 500 * time.Millisecond
+	defaultRetryMaxDelay   = 10 * time.Second
+	defaultRetryMaxElapsed = 60 * time.Second
+)
+
+// DefaultRetryStatusCodes lists the upstream statuses retried when a policy does not override them.
+var DefaultRetryStatusCodes = []int{
+	http.StatusForbidden,
+	http.StatusRequestTimeout,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy controls how Manager retries executions after every candidate failed.
+// Each retry goes back through the selector, so it may land on a different credential.
+type RetryPolicy struct {
+	// MaxRetries is the number of additional rounds attempted after the first one fails.
+	MaxRetries int
+	// StatusCodes lists upstream HTTP statuses that qualify for a retry.
+	StatusCodes []int
+	// BaseDelay is the initial backoff interval; it doubles on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps a single backoff interval.
+	MaxDelay time.Duration
+	// MaxElapsed bounds the total time a single request may spend across all attempts.
+	MaxElapsed time.Duration
+}
+
+// normalized returns a copy of the policy with defaults applied to unset fields.
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.MaxRetries < 0 {
+		p.MaxRetries = 0
+	}
+	if len(p.StatusCodes) == 0 {
+		p.StatusCodes = DefaultRetryStatusCodes
+	}
+	p.StatusCodes = append([]int(nil), p.StatusCodes...)
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	if p.MaxElapsed <= 0 {
+		p.MaxElapsed = defaultRetryMaxElapsed
+	}
+	return p
+}
+
+func (p RetryPolicy) retryableStatus(status int) bool {
+	if status <= 0 {
+		return false
+	}
+	for _, code := range p.StatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the jittered delay for the given zero-based retry attempt.
+// The delay is drawn uniformly from [d/2, d] where d grows exponentially up to MaxDelay.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half+1)
+}
+
+// liftsBlock reports whether a retry round may reuse a credential that is only blocked
+// because of an earlier retryable failure. Quota cooldowns and disabled states are kept.
+func (p RetryPolicy) liftsBlock(auth *Auth, model string) bool {
+	if auth == nil || auth.Disabled || auth.Status == StatusDisabled {
+		return false
+	}
+	lastErr := auth.LastError
+	if model != "" {
+		state, ok := auth.ModelStates[model]
+		if !ok || state == nil {
+			return false
+		}
+		if state.Status == StatusDisabled || state.Quota.Exceeded {
+			return false
+		}
+		lastErr = state.LastError
+	} else if auth.Quota.Exceeded {
+		return false
+	}
+	status := statusCodeFromResult(lastErr)
+	return status != http.StatusTooManyRequests && p.retryableStatus(status)
+}
+
+// withBlockLifted returns a copy of auth whose transient block for model is cleared.
+func withBlockLifted(auth *Auth, model string) *Auth {
+	lifted := auth.Clone()
+	if model != "" {
+		if state := lifted.ModelStates[model]; state != nil {
+			state.Unavailable = false
+			state.NextRetryAfter = time.Time{}
+		}
+		return lifted
+	}
+	lifted.Unavailable = false
+	lifted.NextRetryAfter = time.Time{}
+	return lifted
+}
+
+// retryContextKey marks contexts that belong to a retry round.
+type retryContextKey struct{}
+
+func withRetryRound(ctx context.Context, policy *RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryContextKey{}, policy)
+}
+
+func retryRoundPolicy(ctx context.Context) *RetryPolicy {
+	if ctx == nil {
+		return nil
+	}
+	policy, _ := ctx.Value(retryContextKey{}).(*RetryPolicy)
+	return policy
+}
+
+// SetRetryPolicy installs the retry policy applied by Execute, ExecuteCount and ExecuteStream.
+// It is safe to call at any time; in-flight requests keep the policy they started with.
+func (m *Manager) SetRetryPolicy(policy RetryPolicy) {
+	normalized := policy.normalized()
+	m.retryPolicy.Store(&normalized)
+}
+
+// RetryPolicy returns the currently active retry policy.
+func (m *Manager) RetryPolicy() RetryPolicy {
+	if p := m.retryPolicy.Load(); p != nil {
+		return *p
+	}
+	return RetryPolicy{}.normalized()
+}
+
+// retryState tracks retry progress for a single top-level request.
+type retryState struct {
+	policy  RetryPolicy
+	started time.Time
+	attempt int
+	// fallbackPending is set while a fallback model remains to be tried after the current one.
+	fallbackPending bool
+}
+
+// beginHop resets the per-model attempt counter when moving along a fallback chain.
+// The elapsed budget is shared by every model in the chain.
+func (r *retryState) beginHop(fallbackPending bool) {
+	r.attempt = 0
+	r.fallbackPending = fallbackPending
+}
+
+// context returns the context for the current round; retry rounds carry the policy so
+// pickNext can reuse credentials that were only blocked by the previous failure.
+func (r *retryState) context(ctx context.Context) context.Context {
+	if r == nil || r.attempt == 0 {
+		return ctx
+	}
+	return withRetryRound(ctx, &r.policy)
+}
+
+func (m *Manager) newRetryState() *retryState {
+	return &retryState{policy: m.RetryPolicy(), started: time.Now()}
+}
+
+// waitForRetry decides whether the failed request should run another round and, if so,
+// sleeps for the backoff interval. It honours provider retry hints, the earliest time a
+// cooling credential becomes available, the policy deadline and context cancellation.
+func (m *Manager) waitForRetry(ctx context.Context, state *retryState, providers []string, model string, lastErr error) bool {
+	if state == nil || lastErr == nil {
+		return false
+	}
+	policy := state.policy
+	if state.attempt >= policy.MaxRetries {
+		return false
+	}
+	if !policy.retryableStatus(statusCodeFromError(lastErr)) {
+		return false
+	}
+	// Prefer an idle fallback model over waiting out a cooldown on this one.
+	if state.fallbackPending && (isSelectionError(lastErr) || statusCodeFromError(lastErr) == http.StatusTooManyRequests) {
+		return false
+	}
+	delay := policy.backoff(state.attempt)
+	if ra := retryAfterFromError(lastErr); ra != nil && *ra > delay {
+		delay = *ra
+	}
+	if wait := m.availabilityWait(providers, model, &policy, time.Now()); wait > delay {
+		delay = wait
+	}
+	if time.Since(state.started)+delay > policy.MaxElapsed {
+		log.Debugf("retry budget exhausted for model %s after %d attempt(s)", model, state.attempt+1)
+		return false
+	}
+	state.attempt++
+	log.Debugf("retrying model %s in %s (retry %d/%d): %v", model, delay, state.attempt, policy.MaxRetries, lastErr)
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// availabilityWait reports how long until at least one credential serving the model
+// across the given providers becomes selectable in a retry round. Zero means one is
+// available now or none will become available on its own.
+func (m *Manager) availabilityWait(providers []string, model string, policy *RetryPolicy, now time.Time) time.Duration {
+	modelKey := strings.TrimSpace(model)
+	registryRef := registry.GetGlobalRegistry()
+	var earliest time.Time
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, provider := range providers {
+		for _, candidate := range m.auths {
+			if candidate.Provider != provider || candidate.Disabled {
+				continue
+			}
+			if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
+				continue
+			}
+			blocked, _, next := isAuthBlockedForModel(candidate, modelKey, now)
+			if !blocked || policy.liftsBlock(candidate, modelKey) {
+				return 0
+			}
+			if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
+				earliest = next
+			}
+		}
+	}
+	if earliest.IsZero() {
+		return 0
+	}
+	return earliest.Sub(now)
+}
+
+// preferUpstreamError keeps an upstream failure from an earlier round when a retry
+// round could not even select a credential, so clients see the original cause.
+func preferUpstreamError(previous, current error) error {
+	if previous != nil && isSelectionError(current) {
+		return previous
+	}
+	return current
+}
+
+func isSelectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var cooldown *modelCooldownError
+	if errors.As(err, &cooldown) {
+		return true
+	}
+	var authErr *Error
+	if errors.As(err, &authErr) && authErr != nil {
+		switch authErr.Code {
+		case "auth_not_found", "auth_unavailable", "executor_not_found":
+			return true
+		}
+	}
+	return false
+}
+
+func statusCodeFromError(err error) int {
+	if err == nil {
+		return 0
+	}
+	var se interface{ StatusCode() int }
+	if errors.As(err, &se) && se != nil {
+		return se.StatusCode()
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type retryTestStatusError struct {
+	code int
+}
+
+func (e retryTestStatusError) Error() string   { return http.StatusText(e.code) }
+func (e retryTestStatusError) StatusCode() int { return e.code }
+
+type retryTestExecutor struct {
+	provider string
+	failures int32
+	status   int
+	calls    atomic.Int32
+}
+
+func (e *retryTestExecutor) Identifier() string { return e.provider }
+
+func (e *retryTestExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if e.calls.Add(1) <= e.failures {
+		return cliproxyexecutor.Response{}, retryTestStatusError{code: e.status}
+	}
+	return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+}
+
+func (e *retryTestExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, retryTestStatusError{code: http.StatusNotImplemented}
+}
+
+func (e *retryTestExecutor) Refresh(ctx context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *retryTestExecutor) CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return e.Execute(ctx, auth, req, opts)
+}
+
+func newRetryTestManager(t *testing.T, exec *retryTestExecutor, authID, model string) *Manager {
+	t.Helper()
+	registry.GetGlobalRegistry().RegisterClient(authID, exec.provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(exec)
+	if _, err := m.Register(context.Background(), &Auth{ID: authID, Provider: exec.provider, Status: StatusActive}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	return m
+}
+
+func TestExecuteRetriesTransientFailureOnSameCredential(t *testing.T) {
+	exec := &retryTestExecutor{provider: "retry-test", failures: 2, status: http.StatusServiceUnavailable}
+	m := newRetryTestManager(t, exec, "retry-test-auth", "retry-model")
+	m.SetRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+
+	resp, err := m.Execute(context.Background(), []string{"retry-test"}, cliproxyexecutor.Request{Model: "retry-model"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if string(resp.Payload) != "ok" {
+		t.Fatalf("unexpected payload %q", resp.Payload)
+	}
+	if got := exec.calls.Load(); got != 3 {
+		t.Fatalf("expected 3 executor calls, got %d", got)
+	}
+}
+
+func TestExecuteDoesNotRetryNonRetryableStatus(t *testing.T) {
+	exec := &retryTestExecutor{provider: "retry-test-400", failures: 5, status: http.StatusBadRequest}
+	m := newRetryTestManager(t, exec, "retry-test-400-auth", "retry-model")
+	m.SetRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+
+	_, err := m.Execute(context.Background(), []string{"retry-test-400"}, cliproxyexecutor.Request{Model: "retry-model"}, cliproxyexecutor.Options{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got := statusCodeFromError(err); got != http.StatusBadRequest {
+		t.Fatalf("expected upstream 400 to surface, got %d (%v)", got, err)
+	}
+	if got := exec.calls.Load(); got != 1 {
+		t.Fatalf("expected a single executor call, got %d", got)
+	}
+}
+
+func TestExecuteRetryStopsAtDeadline(t *testing.T) {
+	exec := &retryTestExecutor{provider: "retry-test-deadline", failures: 100, status: http.StatusBadGateway}
+	m := newRetryTestManager(t, exec, "retry-test-deadline-auth", "retry-model")
+	m.SetRetryPolicy(RetryPolicy{MaxRetries: 50, BaseDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond, MaxElapsed: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := m.Execute(context.Background(), []string{"retry-test-deadline"}, cliproxyexecutor.Request{Model: "retry-model"}, cliproxyexecutor.Options{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("retry loop ignored deadline, ran for %s", elapsed)
+	}
+	if got := exec.calls.Load(); got >= 50 {
+		t.Fatalf("expected deadline to cut retries short, got %d calls", got)
+	}
+}
+
+func TestRetryPolicyBackoffIsBounded(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.normalized()
+	for attempt := 0; attempt < 10; attempt++ {
+		delay := policy.backoff(attempt)
+		if delay <= 0 || delay > policy.MaxDelay {
+			t.Fatalf("attempt %d: delay %s out of range", attempt, delay)
+		}
+	}
+}
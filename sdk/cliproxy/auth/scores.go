@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	// scoreEWMAAlpha weights the newest sample in the moving averages.
+	scoreEWMAAlpha = 0.3
+	// scoreErrorPenalty scales how strongly the error rate inflates a score.
+	scoreErrorPenalty = 10.0
+)
+
+// AuthScore is a snapshot of the moving averages tracked for one auth and model.
+// An empty Model holds the aggregate across all models served by the auth.
+type AuthScore struct {
+	AuthID    string    `json:"auth_id"`
+	Provider  string    `json:"provider"`
+	Label     string    `json:"label,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	TTFTMS    float64   `json:"ttft_ms"`
+	ErrorRate float64   `json:"error_rate"`
+	Samples   int64     `json:"samples"`
+	Score     float64   `json:"score"`
+	InFlight  int64     `json:"in_flight"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ewmaStats holds exponentially weighted moving averages for one auth and model.
+type ewmaStats struct {
+	latencyMS   float64
+	ttftMS      float64
+	errorRate   float64
+	samples     int64
+	ttftSamples int64
+	updatedAt   time.Time
+}
+
+func ewma(current, sample float64, first bool) float64 {
+	if first {
+		return sample
+	}
+	return current + scoreEWMAAlpha*(sample-current)
+}
+
+func (s *ewmaStats) observe(success bool, latency, ttft time.Duration, now time.Time) {
+	first := s.samples == 0
+	failure := 1.0
+	if success {
+		failure = 0
+	}
+	s.errorRate = ewma(s.errorRate, failure, first)
+	if latency > 0 {
+		s.latencyMS = ewma(s.latencyMS, float64(latency)/float64(time.Millisecond), s.latencyMS == 0)
+	}
+	if ttft > 0 {
+		s.ttftMS = ewma(s.ttftMS, float64(ttft)/float64(time.Millisecond), s.ttftSamples == 0)
+		s.ttftSamples++
+	}
+	s.samples++
+	s.updatedAt = now
+}
+
+// score returns the expected cost of routing to the auth; lower is better. Time to first
+// token is preferred over total latency because it does not depend on response length.
+// Auths without samples score zero so they get explored.
+func (s *ewmaStats) score() float64 {
+	if s == nil || s.samples == 0 {
+		return 0
+	}
+	base := s.latencyMS
+	if s.ttftSamples > 0 {
+		base = s.ttftMS
+	}
+	if base <= 0 {
+		base = 1
+	}
+	return base * (1 + scoreErrorPenalty*s.errorRate)
+}
+
+// scoreTracker records per-auth and per-model moving averages from execution results.
+type scoreTracker struct {
+	mu    sync.RWMutex
+	stats map[string]map[string]*ewmaStats // auth ID -> model ("" for aggregate)
+}
+
+func newScoreTracker() *scoreTracker {
+	return &scoreTracker{stats: make(map[string]map[string]*ewmaStats)}
+}
+
+func (t *scoreTracker) observe(result Result, now time.Time) {
+	if result.AuthID == "" {
+		return
+	}
+	success := result.Success
+	if !success && !countsAgainstAuth(result.Error) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	models, ok := t.stats[result.AuthID]
+	if !ok {
+		models = make(map[string]*ewmaStats)
+		t.stats[result.AuthID] = models
+	}
+	keys := []string{""}
+	if result.Model != "" {
+		keys = append(keys, result.Model)
+	}
+	for _, key := range keys {
+		stats, ok := models[key]
+		if !ok {
+			stats = &ewmaStats{}
+			models[key] = stats
+		}
+		stats.observe(success, result.Latency, result.TTFT, now)
+	}
+}
+
+// score returns the per-model score for the auth, falling back to its aggregate.
+func (t *scoreTracker) score(authID, model string) float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	models := t.stats[authID]
+	if stats, ok := models[model]; ok && model != "" {
+		return stats.score()
+	}
+	return models[""].score()
+}
+
+// forget drops the moving averages of the auth.
+func (t *scoreTracker) forget(authID string) {
+	t.mu.Lock()
+	delete(t.stats, authID)
+	t.mu.Unlock()
+}
+
+// retain drops the moving averages of every auth not in keep.
+func (t *scoreTracker) retain(keep map[string]*Auth) {
+	t.mu.Lock()
+	for authID := range t.stats {
+		if _, ok := keep[authID]; !ok {
+			delete(t.stats, authID)
+		}
+	}
+	t.mu.Unlock()
+}
+
+// countsAgainstAuth reports whether a failure reflects on the credential rather than on
+// the request itself; malformed or oversized requests fail on every credential.
+func countsAgainstAuth(err *Error) bool {
+	switch statusCodeFromResult(err) {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return false
+	}
+	return true
+}
+
+// Score returns the adaptive routing score for the auth and model; lower is better.
+func (m *Manager) Score(authID, model string) float64 {
+	return m.scores.score(authID, model)
+}
+
+// AuthScores returns the tracked moving averages for every registered auth, ordered by
+// provider, auth ID and model.
+func (m *Manager) AuthScores() []AuthScore {
+	m.mu.RLock()
+	auths := make(map[string]*Auth, len(m.auths))
+	for id, auth := range m.auths {
+		auths[id] = auth
+	}
+	m.mu.RUnlock()
+
+	m.scores.mu.RLock()
+	out := make([]AuthScore, 0, len(m.scores.stats))
+	for authID, models := range m.scores.stats {
+		auth, ok := auths[authID]
+		if !ok || auth.Disabled {
+			continue
+		}
+		for model, stats := range models {
+			out = append(out, AuthScore{
+				AuthID:    authID,
+				Provider:  auth.Provider,
+				Label:     auth.Label,
+				Model:     model,
+				LatencyMS: stats.latencyMS,
+				TTFTMS:    stats.ttftMS,
+				ErrorRate: stats.errorRate,
+				Samples:   stats.samples,
+				Score:     stats.score(),
+				InFlight:  m.InFlight(authID),
+				UpdatedAt: stats.updatedAt,
+			})
+		}
+	}
+	m.scores.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		if out[i].AuthID != out[j].AuthID {
+			return out[i].AuthID < out[j].AuthID
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// ScoreSource reports adaptive routing scores; lower is better.
+type ScoreSource interface {
+	Score(authID, model string) float64
+}
+
+// PowerOfTwoSelector samples two available auths at random and picks the one with the
+// lower score, so slow or failing credentials receive proportionally less traffic without
+// starving them of the samples needed to recover.
+type PowerOfTwoSelector struct {
+	Scores ScoreSource
+}
+
+// Pick implements Selector.
+func (s *PowerOfTwoSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	available, err := availableAuths(provider, model, auths, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(available) == 1 || s.Scores == nil {
+		return available[rand.IntN(len(available))], nil
+	}
+	i := rand.IntN(len(available))
+	j := rand.IntN(len(available) - 1)
+	if j >= i {
+		j++
+	}
+	first, second := available[i], available[j]
+	if s.Scores.Score(second.ID, model) < s.Scores.Score(first.ID, model) {
+		return second, nil
+	}
+	return first, nil
+}
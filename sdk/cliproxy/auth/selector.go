@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"math"
 	"net/http"
-	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -87,6 +86,12 @@ func (e *modelCooldownError) StatusCode() int {
 	return http.StatusTooManyRequests
 }
 
+// RetryAfter exposes the cooldown reset interval so retries can wait for it.
+func (e *modelCooldownError) RetryAfter() *time.Duration {
+	resetIn := e.resetIn
+	return &resetIn
+}
+
 func (e *modelCooldownError) Headers() http.Header {
 	headers := make(http.Header)
 	headers.Set("Content-Type", "application/json")
@@ -102,46 +107,15 @@ func (e *modelCooldownError) Headers() http.Header {
 func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
 	_ = ctx
 	_ = opts
-	if len(auths) == 0 {
-		return nil, &Error{Code: "auth_not_found", Message: "no auth candidates"}
+	available, err := availableAuths(provider, model, auths, time.Now())
+	if err != nil {
+		return nil, err
 	}
+	key := provider + ":" + model
+	s.mu.Lock()
 	if s.cursors == nil {
 		s.cursors = make(map[string]int)
 	}
-	available := make([]*Auth, 0, len(auths))
-	now := time.Now()
-	cooldownCount := 0
-	var earliest time.Time
-	for i := 0; i < len(auths); i++ {
-		candidate := auths[i]
-		blocked, reason, next := isAuthBlockedForModel(candidate, model, now)
-		if !blocked {
-			available = append(available, candidate)
-			continue
-		}
-		if reason == blockReasonCooldown {
-			cooldownCount++
-			if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
-				earliest = next
-			}
-		}
-	}
-	if len(available) == 0 {
-		if cooldownCount == len(auths) && !earliest.IsZero() {
-			resetIn := earliest.Sub(now)
-			if resetIn < 0 {
-				resetIn = 0
-			}
-			return nil, newModelCooldownError(model, provider, resetIn)
-		}
-		return nil, &Error{Code: "auth_unavailable", Message: "no auth available"}
-	}
-	// Make round-robin deterministic even if caller's candidate order is unstable.
-	if len(available) > 1 {
-		sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
-	}
-	key := provider + ":" + model
-	s.mu.Lock()
 	index := s.cursors[key]
 
 	if index >= 2_147_483_640 {
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+// stateSyncRetryDelay is the pause before a failed state listener reconnects.
+const stateSyncRetryDelay = 5 * time.Second
+
+// StateBroadcaster is implemented by stores shared between proxy replicas. A cooldown learned
+// by one replica is published right away so the other replicas stop using the credential too.
+type StateBroadcaster interface {
+	// StateSharingEnabled reports whether the store was configured to share runtime state.
+	StateSharingEnabled() bool
+	// PublishState announces the state of one auth to the other replicas.
+	PublishState(ctx context.Context, authID string, state *AuthState) error
+	// ListenState delivers state published by other replicas until ctx is done or the
+	// connection fails.
+	ListenState(ctx context.Context, handle func(authID string, state *AuthState)) error
+}
+
+// stateBroadcaster returns the store as a StateBroadcaster when sharing is enabled.
+func (m *Manager) stateBroadcaster() StateBroadcaster {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	broadcaster, ok := m.store.(StateBroadcaster)
+	if !ok || !broadcaster.StateSharingEnabled() {
+		return nil
+	}
+	return broadcaster
+}
+
+// StartStateSync subscribes to runtime state published by other replicas when the store
+// shares state. It reconnects until StopStateSync is called.
+func (m *Manager) StartStateSync(parent context.Context) {
+	broadcaster := m.stateBroadcaster()
+	if broadcaster == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m.stateMu.Lock()
+	if m.stateSyncCancel != nil {
+		m.stateSyncCancel()
+	}
+	m.stateSyncCancel = cancel
+	m.stateMu.Unlock()
+	go func() {
+		for {
+			err := broadcaster.ListenState(ctx, m.applySharedState)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnf("auth state listener stopped, reconnecting: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(stateSyncRetryDelay):
+			}
+		}
+	}()
+}
+
+// StopStateSync stops listening for runtime state from other replicas.
+func (m *Manager) StopStateSync() {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if m.stateSyncCancel != nil {
+		m.stateSyncCancel()
+		m.stateSyncCancel = nil
+	}
+}
+
+// sharedFailureState extracts the state a failed result produced on the auth, limited to the
+// affected model, so it can be published. It returns nil when the failure set no cooldown.
+func sharedFailureState(auth *Auth, model string, now time.Time) *AuthState {
+	if auth == nil {
+		return nil
+	}
+	state := &AuthState{Status: auth.Status, StatusMessage: auth.StatusMessage, LastError: cloneError(auth.LastError)}
+	if model != "" {
+		ms := auth.ModelStates[model]
+		if ms == nil || !ms.NextRetryAfter.After(now) {
+			return nil
+		}
+		copyState := *ms
+		copyState.LastError = cloneError(ms.LastError)
+		state.ModelStates = map[string]*ModelState{model: &copyState}
+		return state
+	}
+	if !auth.NextRetryAfter.After(now) {
+		return nil
+	}
+	state.Unavailable = auth.Unavailable
+	state.NextRetryAfter = auth.NextRetryAfter
+	state.Quota = auth.Quota
+	return state
+}
+
+// publishState sends the state to the other replicas without blocking the request path.
+func (m *Manager) publishState(authID string, state *AuthState) {
+	if state == nil {
+		return
+	}
+	broadcaster := m.stateBroadcaster()
+	if broadcaster == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := broadcaster.PublishState(ctx, authID, state); err != nil {
+			log.Warnf("failed to publish auth state for %s: %v", authID, err)
+		}
+	}()
+}
+
+// applySharedState merges state published by another replica. Windows are only ever
+// extended, so a late or duplicated message cannot clear a newer local cooldown.
+func (m *Manager) applySharedState(authID string, state *AuthState) {
+	if state == nil {
+		return
+	}
+	type suspension struct {
+		model string
+		quota bool
+	}
+	var suspended []suspension
+	now := time.Now()
+
+	m.mu.Lock()
+	auth, ok := m.auths[authID]
+	if !ok || auth == nil || auth.Disabled {
+		m.mu.Unlock()
+		return
+	}
+	changed := false
+	for model, remote := range state.ModelStates {
+		if remote == nil || !remote.NextRetryAfter.After(now) {
+			continue
+		}
+		if local := auth.ModelStates[model]; local != nil && (local.Status == StatusDisabled || !remote.NextRetryAfter.After(local.NextRetryAfter)) {
+			continue
+		}
+		if auth.ModelStates == nil {
+			auth.ModelStates = make(map[string]*ModelState)
+		}
+		copyState := *remote
+		copyState.LastError = cloneError(remote.LastError)
+		auth.ModelStates[model] = &copyState
+		suspended = append(suspended, suspension{model: model, quota: remote.Quota.Exceeded})
+		changed = true
+	}
+	if state.NextRetryAfter.After(now) && state.NextRetryAfter.After(auth.NextRetryAfter) {
+		auth.Unavailable = state.Unavailable
+		auth.NextRetryAfter = state.NextRetryAfter
+		auth.Quota = state.Quota
+		changed = true
+	}
+	if changed {
+		if state.Status == StatusError {
+			auth.Status = StatusError
+			auth.StatusMessage = state.StatusMessage
+			auth.LastError = cloneError(state.LastError)
+		}
+		auth.UpdatedAt = now
+		updateAggregatedAvailability(auth, now)
+	}
+	m.mu.Unlock()
+
+	for _, s := range suspended {
+		reason := "shared_state"
+		if s.quota {
+			reason = "quota"
+			registry.GetGlobalRegistry().SetModelQuotaExceeded(authID, s.model)
+		}
+		registry.GetGlobalRegistry().SuspendClientModel(authID, s.model, reason)
+	}
+	if changed {
+		m.scheduleStateFlush()
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sharedStateMessage struct {
+	origin *sharedStateTestStore
+	id     string
+	state  *AuthState
+}
+
+// sharedStateBus fans published state out to every listening store, like NOTIFY does.
+type sharedStateBus struct {
+	mu        sync.Mutex
+	listeners []chan sharedStateMessage
+}
+
+type sharedStateTestStore struct {
+	*stateTestStore
+	bus *sharedStateBus
+}
+
+func (s *sharedStateTestStore) StateSharingEnabled() bool { return true }
+
+func (s *sharedStateTestStore) PublishState(_ context.Context, authID string, state *AuthState) error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	for _, ch := range s.bus.listeners {
+		ch <- sharedStateMessage{origin: s, id: authID, state: state}
+	}
+	return nil
+}
+
+func (s *sharedStateTestStore) ListenState(ctx context.Context, handle func(authID string, state *AuthState)) error {
+	ch := make(chan sharedStateMessage, 16)
+	s.bus.mu.Lock()
+	s.bus.listeners = append(s.bus.listeners, ch)
+	s.bus.mu.Unlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-ch:
+			if msg.origin != s {
+				handle(msg.id, msg.state)
+			}
+		}
+	}
+}
+
+func TestSharedStateBlocksAuthOnOtherReplica(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := &sharedStateBus{}
+	newReplica := func() *Manager {
+		m := NewManager(&sharedStateTestStore{stateTestStore: newStateTestStore(), bus: bus}, nil, nil)
+		if _, err := m.Register(ctx, &Auth{ID: "shared-auth", Provider: "shared-test", Status: StatusActive}); err != nil {
+			t.Fatalf("register auth: %v", err)
+		}
+		m.StartStateSync(ctx)
+		return m
+	}
+	first, second := newReplica(), newReplica()
+	defer first.StopStateSync()
+	defer second.StopStateSync()
+	waitFor(t, func() bool {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		return len(bus.listeners) == 2
+	})
+
+	retryAfter := 10 * time.Minute
+	first.MarkResult(ctx, Result{
+		AuthID:     "shared-auth",
+		Provider:   "shared-test",
+		Model:      "shared-model",
+		RetryAfter: &retryAfter,
+		Error:      &Error{Message: "rate limited", HTTPStatus: http.StatusTooManyRequests},
+	})
+
+	waitFor(t, func() bool {
+		auth, _ := second.GetByID("shared-auth")
+		state := auth.ModelStates["shared-model"]
+		return state != nil && state.Unavailable && state.Quota.Exceeded
+	})
+	auth, _ := second.GetByID("shared-auth")
+	if time.Until(auth.ModelStates["shared-model"].NextRetryAfter) < 9*time.Minute {
+		t.Fatalf("expected the cooldown window of the first replica, got %v", auth.ModelStates["shared-model"].NextRetryAfter)
+	}
+
+	// An older window must not shorten a newer local cooldown.
+	second.applySharedState("shared-auth", &AuthState{ModelStates: map[string]*ModelState{
+		"shared-model": {Status: StatusError, Unavailable: true, NextRetryAfter: time.Now().Add(time.Minute)},
+	}})
+	auth, _ = second.GetByID("shared-auth")
+	if time.Until(auth.ModelStates["shared-model"].NextRetryAfter) < 9*time.Minute {
+		t.Fatal("expected the longer cooldown to be kept")
+	}
+}
+
+func TestStateSyncStartStopConcurrently(t *testing.T) {
+	m := NewManager(&sharedStateTestStore{stateTestStore: newStateTestStore(), bus: &sharedStateBus{}}, nil, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.StartStateSync(context.Background())
+		}()
+		go func() {
+			defer wg.Done()
+			m.StopStateSync()
+		}()
+	}
+	wg.Wait()
+	m.StopStateSync()
+}
@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// stateFlushDelay batches runtime state changes into one store write.
+const stateFlushDelay = 2 * time.Second
+
+// AuthState is the runtime availability state of an auth that survives restarts: cooldown
+// windows, quota state with its backoff level, and per-model state.
+type AuthState struct {
+	Status         Status                 `json:"status,omitempty"`
+	StatusMessage  string                 `json:"status_message,omitempty"`
+	Unavailable    bool                   `json:"unavailable,omitempty"`
+	NextRetryAfter time.Time              `json:"next_retry_after,omitempty"`
+	Quota          QuotaState             `json:"quota"`
+	LastError      *Error                 `json:"last_error,omitempty"`
+	ModelStates    map[string]*ModelState `json:"model_states,omitempty"`
+}
+
+// StateStore is implemented by stores that can persist runtime availability state next to
+// the auth records. The state is written as a whole, keyed by auth ID.
+type StateStore interface {
+	// LoadState returns the persisted state keyed by auth ID.
+	LoadState(ctx context.Context) (map[string]*AuthState, error)
+	// SaveState replaces the persisted state.
+	SaveState(ctx context.Context, states map[string]*AuthState) error
+}
+
+// stateRetainUntil returns how long a cooldown or quota window is worth keeping. Backoff
+// levels are kept for quotaBackoffMax after the window closes, so a credential that is rate
+// limited again right away continues its progression instead of starting from zero.
+func stateRetainUntil(nextRetry time.Time, quota QuotaState) time.Time {
+	until := nextRetry
+	if quota.NextRecoverAt.After(until) {
+		until = quota.NextRecoverAt
+	}
+	if quota.BackoffLevel > 0 && !until.IsZero() {
+		until = until.Add(quotaBackoffMax)
+	}
+	return until
+}
+
+// snapshotAuthState extracts the persistable state of the auth, dropping expired windows.
+// It returns nil when nothing is worth keeping.
+func snapshotAuthState(auth *Auth, now time.Time) *AuthState {
+	if auth == nil {
+		return nil
+	}
+	var state AuthState
+	keep := false
+	for model, ms := range auth.ModelStates {
+		if ms == nil || ms.Status == StatusDisabled {
+			continue
+		}
+		if !stateRetainUntil(ms.NextRetryAfter, ms.Quota).After(now) {
+			continue
+		}
+		if state.ModelStates == nil {
+			state.ModelStates = make(map[string]*ModelState)
+		}
+		copyState := *ms
+		copyState.LastError = cloneError(ms.LastError)
+		state.ModelStates[model] = &copyState
+		keep = true
+	}
+	if stateRetainUntil(auth.NextRetryAfter, auth.Quota).After(now) {
+		state.Unavailable = auth.Unavailable
+		state.NextRetryAfter = auth.NextRetryAfter
+		state.Quota = auth.Quota
+		keep = true
+	}
+	if !keep {
+		return nil
+	}
+	if auth.Status == StatusError {
+		state.Status = auth.Status
+		state.StatusMessage = auth.StatusMessage
+		state.LastError = cloneError(auth.LastError)
+	}
+	return &state
+}
+
+// restoreAuthState applies persisted state to a freshly loaded auth.
+func restoreAuthState(auth *Auth, state *AuthState, now time.Time) {
+	if auth == nil || state == nil {
+		return
+	}
+	for model, ms := range state.ModelStates {
+		if ms == nil || !stateRetainUntil(ms.NextRetryAfter, ms.Quota).After(now) {
+			continue
+		}
+		if auth.ModelStates == nil {
+			auth.ModelStates = make(map[string]*ModelState)
+		}
+		copyState := *ms
+		auth.ModelStates[model] = &copyState
+	}
+	if stateRetainUntil(state.NextRetryAfter, state.Quota).After(now) {
+		auth.Unavailable = state.Unavailable
+		auth.NextRetryAfter = state.NextRetryAfter
+		auth.Quota = state.Quota
+	}
+	if state.Status == StatusError && !auth.Disabled {
+		auth.Status = state.Status
+		auth.StatusMessage = state.StatusMessage
+		auth.LastError = cloneError(state.LastError)
+	}
+	updateAggregatedAvailability(auth, now)
+}
+
+// PreserveRuntimeState copies the live cooldown, quota and per-model state of previous onto
+// auth when auth was rebuilt from its backing record, which never carries that state.
+func PreserveRuntimeState(auth, previous *Auth) {
+	if auth == nil || previous == nil || len(auth.ModelStates) > 0 {
+		return
+	}
+	restoreAuthState(auth, snapshotAuthState(previous, time.Now()), time.Now())
+}
+
+// restoreStateLocked loads persisted runtime state into the loaded auths. State of auths
+// that are not loaded yet is kept until they are registered.
+func (m *Manager) restoreStateLocked(ctx context.Context) {
+	stateStore, ok := m.store.(StateStore)
+	if !ok {
+		return
+	}
+	states, err := stateStore.LoadState(ctx)
+	if err != nil {
+		log.Warnf("failed to load auth runtime state: %v", err)
+		return
+	}
+	m.persistedState = make(map[string]*AuthState, len(states))
+	for id, state := range states {
+		if state != nil {
+			m.persistedState[id] = state
+		}
+	}
+	for _, auth := range m.auths {
+		m.applyPersistedStateLocked(auth)
+	}
+}
+
+// applyPersistedStateLocked restores the persisted state of an auth the first time it is
+// seen after Load. m.mu must be held.
+func (m *Manager) applyPersistedStateLocked(auth *Auth) {
+	state, ok := m.persistedState[auth.ID]
+	if !ok {
+		return
+	}
+	delete(m.persistedState, auth.ID)
+	restoreAuthState(auth, state, time.Now())
+}
+
+// retained reports whether any window of the state is still worth keeping.
+func (s *AuthState) retained(now time.Time) bool {
+	if stateRetainUntil(s.NextRetryAfter, s.Quota).After(now) {
+		return true
+	}
+	for _, ms := range s.ModelStates {
+		if ms != nil && stateRetainUntil(ms.NextRetryAfter, ms.Quota).After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleStateFlush persists runtime state shortly after it changes. Bursts of failures
+// are coalesced into a single write.
+func (m *Manager) scheduleStateFlush() {
+	m.mu.RLock()
+	_, ok := m.store.(StateStore)
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if m.stateFlushPending {
+		return
+	}
+	m.stateFlushPending = true
+	time.AfterFunc(stateFlushDelay, func() {
+		m.stateMu.Lock()
+		m.stateFlushPending = false
+		m.stateMu.Unlock()
+		if err := m.FlushState(context.Background()); err != nil {
+			log.Warnf("failed to persist auth runtime state: %v", err)
+		}
+	})
+}
+
+// FlushState writes the current runtime state to the store when it supports StateStore.
+// Expired windows are pruned and unchanged state is not rewritten.
+func (m *Manager) FlushState(ctx context.Context) error {
+	m.mu.RLock()
+	stateStore, ok := m.store.(StateStore)
+	if !ok {
+		m.mu.RUnlock()
+		return nil
+	}
+	now := time.Now()
+	states := make(map[string]*AuthState)
+	for id, auth := range m.auths {
+		if state := snapshotAuthState(auth, now); state != nil {
+			states[id] = state
+		}
+	}
+	// Keep the state of auths that have not been registered again yet.
+	for id, state := range m.persistedState {
+		if _, registered := m.auths[id]; !registered && state.retained(now) {
+			states[id] = state
+		}
+	}
+	m.mu.RUnlock()
+
+	raw, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if bytes.Equal(raw, m.stateLastSaved) {
+		return nil
+	}
+	if err = stateStore.SaveState(ctx, states); err != nil {
+		return err
+	}
+	m.stateLastSaved = raw
+	return nil
+}
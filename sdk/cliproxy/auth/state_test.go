@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stateTestStore struct {
+	mu     sync.Mutex
+	auths  map[string]*Auth
+	states map[string]*AuthState
+}
+
+func newStateTestStore() *stateTestStore {
+	return &stateTestStore{auths: make(map[string]*Auth)}
+}
+
+func (s *stateTestStore) List(context.Context) ([]*Auth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Auth, 0, len(s.auths))
+	for _, auth := range s.auths {
+		list = append(list, auth.Clone())
+	}
+	return list, nil
+}
+
+func (s *stateTestStore) Save(_ context.Context, auth *Auth) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Auth records never carry runtime state; it travels through SaveState only.
+	record := auth.Clone()
+	record.ModelStates = nil
+	record.Quota = QuotaState{}
+	record.Unavailable = false
+	record.NextRetryAfter = time.Time{}
+	s.auths[auth.ID] = record
+	return auth.ID, nil
+}
+
+func (s *stateTestStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.auths, id)
+	return nil
+}
+
+func (s *stateTestStore) LoadState(context.Context) (map[string]*AuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states, nil
+}
+
+func (s *stateTestStore) SaveState(_ context.Context, states map[string]*AuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = states
+	return nil
+}
+
+func TestRuntimeStateSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	store := newStateTestStore()
+	m := NewManager(store, nil, nil)
+	if _, err := m.Register(ctx, &Auth{ID: "state-auth", Provider: "state-test", Status: StatusActive, Metadata: map[string]any{"type": "state-test"}}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	retryAfter := time.Hour
+	m.MarkResult(ctx, Result{
+		AuthID:     "state-auth",
+		Provider:   "state-test",
+		Model:      "state-model",
+		RetryAfter: &retryAfter,
+		Error:      &Error{Message: "rate limited", HTTPStatus: http.StatusTooManyRequests},
+	})
+	if err := m.FlushState(ctx); err != nil {
+		t.Fatalf("flush state: %v", err)
+	}
+
+	restarted := NewManager(store, nil, nil)
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	auth, ok := restarted.GetByID("state-auth")
+	if !ok {
+		t.Fatal("auth not loaded")
+	}
+	state := auth.ModelStates["state-model"]
+	if state == nil || !state.Unavailable || !state.Quota.Exceeded {
+		t.Fatalf("expected restored quota cooldown, got %+v", state)
+	}
+	if time.Until(state.NextRetryAfter) < 50*time.Minute {
+		t.Fatalf("expected cooldown of about an hour, got %v", time.Until(state.NextRetryAfter))
+	}
+}
+
+func TestRuntimeStateDropsExpiredWindows(t *testing.T) {
+	ctx := context.Background()
+	store := newStateTestStore()
+	if _, err := store.Save(ctx, &Auth{ID: "expired-auth", Provider: "state-test", Status: StatusActive}); err != nil {
+		t.Fatalf("save auth: %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	store.states = map[string]*AuthState{
+		"expired-auth": {ModelStates: map[string]*ModelState{
+			"state-model": {Status: StatusError, Unavailable: true, NextRetryAfter: past},
+		}},
+		"missing-auth": {Unavailable: true, NextRetryAfter: time.Now().Add(time.Hour)},
+	}
+
+	m := NewManager(store, nil, nil)
+	if err := m.Load(ctx); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	auth, _ := m.GetByID("expired-auth")
+	if len(auth.ModelStates) != 0 {
+		t.Fatalf("expected expired model state to be dropped, got %+v", auth.ModelStates)
+	}
+	if err := m.FlushState(ctx); err != nil {
+		t.Fatalf("flush state: %v", err)
+	}
+	if _, ok := store.states["expired-auth"]; ok || len(store.states) != 1 {
+		t.Fatalf("expected only the unregistered auth state to be kept, got %+v", store.states)
+	}
+}
+
+func TestRuntimeStateRestoredOnLateRegistration(t *testing.T) {
+	ctx := context.Background()
+	store := newStateTestStore()
+	store.states = map[string]*AuthState{
+		"config-key-auth": {Unavailable: true, NextRetryAfter: time.Now().Add(time.Hour), Quota: QuotaState{Exceeded: true}},
+	}
+	m := NewManager(store, nil, nil)
+	if err := m.Load(ctx); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	// Config API-key auths are registered by the watcher after Load.
+	registered, err := m.Register(ctx, &Auth{ID: "config-key-auth", Provider: "state-test", Status: StatusActive})
+	if err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	if !registered.Unavailable || !registered.Quota.Exceeded || time.Until(registered.NextRetryAfter) < 50*time.Minute {
+		t.Fatalf("expected restored cooldown, got unavailable=%v quota=%+v next=%v", registered.Unavailable, registered.Quota, registered.NextRetryAfter)
+	}
+	// The persisted state is consumed once; later updates carry the live state.
+	registered.Unavailable, registered.NextRetryAfter, registered.Quota = false, time.Time{}, QuotaState{}
+	updated, _ := m.Update(ctx, registered)
+	if updated.Unavailable {
+		t.Fatal("expected a later update not to restore the persisted state again")
+	}
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// Selection strategy names accepted by Manager.SetSelectionStrategy.
+const (
+	StrategyRoundRobin    = "round-robin"
+	StrategyWeighted      = "weighted"
+	StrategyPriority      = "priority"
+	StrategyLeastInFlight = "least-in-flight"
+	StrategyFillFirst     = "fill-first"
+	StrategyPowerOfTwo    = "power-of-two"
+)
+
+// Auth attribute keys read by the selection strategies. File-backed auths may carry the
+// same keys in their metadata instead.
+const (
+	// AttributeWeight is the relative share of traffic for the weighted strategy (default 1).
+	AttributeWeight = "weight"
+	// AttributePriority orders tiers for the priority strategy; higher tiers are used first (default 0).
+	AttributePriority = "priority"
+)
+
+// InFlightCounter reports how many requests are currently executing on an auth.
+type InFlightCounter interface {
+	InFlight(authID string) int64
+}
+
+// availableAuths filters out blocked candidates and returns the rest in a stable order.
+// When nothing is available it returns the error the caller should surface.
+func availableAuths(provider, model string, auths []*Auth, now time.Time) ([]*Auth, error) {
+	if len(auths) == 0 {
+		return nil, &Error{Code: "auth_not_found", Message: "no auth candidates"}
+	}
+	available := make([]*Auth, 0, len(auths))
+	cooldownCount := 0
+	var earliest time.Time
+	for i := 0; i < len(auths); i++ {
+		candidate := auths[i]
+		blocked, reason, next := isAuthBlockedForModel(candidate, model, now)
+		if !blocked {
+			available = append(available, candidate)
+			continue
+		}
+		if reason == blockReasonCooldown {
+			cooldownCount++
+			if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
+				earliest = next
+			}
+		}
+	}
+	if len(available) == 0 {
+		if cooldownCount == len(auths) && !earliest.IsZero() {
+			resetIn := earliest.Sub(now)
+			if resetIn < 0 {
+				resetIn = 0
+			}
+			return nil, newModelCooldownError(model, provider, resetIn)
+		}
+		return nil, &Error{Code: "auth_unavailable", Message: "no auth available"}
+	}
+	// Keep the order deterministic even if caller's candidate order is unstable.
+	if len(available) > 1 {
+		sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
+	}
+	return available, nil
+}
+
+// authNumber reads a numeric routing hint from attributes, falling back to metadata.
+func authNumber(auth *Auth, key string, def float64) float64 {
+	if auth == nil {
+		return def
+	}
+	if raw, ok := auth.Attributes[key]; ok {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+			return v
+		}
+	}
+	switch v := auth.Metadata[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case string:
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return parsed
+		}
+	}
+	return def
+}
+
+// WeightedSelector picks an available auth at random, proportionally to its weight attribute.
+// Auths with a zero or negative weight only receive traffic when no weighted auth is available.
+type WeightedSelector struct{}
+
+// Pick implements Selector.
+func (WeightedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	available, err := availableAuths(provider, model, auths, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]float64, len(available))
+	var total float64
+	for i, candidate := range available {
+		if w := authNumber(candidate, AttributeWeight, 1); w > 0 {
+			weights[i] = w
+			total += w
+		}
+	}
+	if total <= 0 {
+		return available[rand.IntN(len(available))], nil
+	}
+	target := rand.Float64() * total
+	for i, w := range weights {
+		if target < w {
+			return available[i], nil
+		}
+		target -= w
+	}
+	for i := len(available) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return available[i], nil
+		}
+	}
+	return available[0], nil
+}
+
+// PrioritySelector round-robins within the highest priority tier that has an available auth,
+// so lower tiers only serve traffic while every auth above them is blocked.
+type PrioritySelector struct {
+	rr RoundRobinSelector
+}
+
+// Pick implements Selector.
+func (s *PrioritySelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	available, err := availableAuths(provider, model, auths, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	best := authNumber(available[0], AttributePriority, 0)
+	for _, candidate := range available[1:] {
+		if p := authNumber(candidate, AttributePriority, 0); p > best {
+			best = p
+		}
+	}
+	tier := make([]*Auth, 0, len(available))
+	for _, candidate := range available {
+		if authNumber(candidate, AttributePriority, 0) == best {
+			tier = append(tier, candidate)
+		}
+	}
+	return s.rr.Pick(ctx, provider+":"+strconv.FormatFloat(best, 'f', -1, 64), model, opts, tier)
+}
+
+// LeastInFlightSelector picks the available auth with the fewest requests in flight,
+// rotating between auths that are tied.
+type LeastInFlightSelector struct {
+	Counter InFlightCounter
+	rr      RoundRobinSelector
+}
+
+// Pick implements Selector.
+func (s *LeastInFlightSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	available, err := availableAuths(provider, model, auths, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if s.Counter == nil || len(available) == 1 {
+		return s.rr.Pick(ctx, provider, model, opts, available)
+	}
+	least := int64(-1)
+	tied := make([]*Auth, 0, len(available))
+	for _, candidate := range available {
+		count := s.Counter.InFlight(candidate.ID)
+		switch {
+		case least < 0 || count < least:
+			least = count
+			tied = append(tied[:0], candidate)
+		case count == least:
+			tied = append(tied, candidate)
+		}
+	}
+	return s.rr.Pick(ctx, provider, model, opts, tied)
+}
+
+// FillFirstSelector always picks the first available auth in a stable order, draining one
+// account until it is blocked before moving on to the next.
+type FillFirstSelector struct{}
+
+// Pick implements Selector.
+func (FillFirstSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	available, err := availableAuths(provider, model, auths, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return available[0], nil
+}
+
+// ProviderSelector routes selection to a per-provider strategy, using Default for the rest.
+type ProviderSelector struct {
+	Default   Selector
+	Providers map[string]Selector
+}
+
+// Pick implements Selector.
+func (s *ProviderSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	if selector, ok := s.Providers[provider]; ok && selector != nil {
+		return selector.Pick(ctx, provider, model, opts, auths)
+	}
+	return s.Default.Pick(ctx, provider, model, opts, auths)
+}
+
+// inFlightTracker counts requests currently executing per auth.
+type inFlightTracker struct {
+	counts sync.Map // auth ID -> *atomic.Int64
+}
+
+func (t *inFlightTracker) acquire(authID string) func() {
+	value, _ := t.counts.LoadOrStore(authID, new(atomic.Int64))
+	counter := value.(*atomic.Int64)
+	counter.Add(1)
+	var once sync.Once
+	return func() { once.Do(func() { counter.Add(-1) }) }
+}
+
+// tryAcquire reserves a slot on the auth unless limit (when positive) is already reached.
+func (t *inFlightTracker) tryAcquire(authID string, limit int) (func(), bool) {
+	if limit <= 0 {
+		return t.acquire(authID), true
+	}
+	value, _ := t.counts.LoadOrStore(authID, new(atomic.Int64))
+	counter := value.(*atomic.Int64)
+	for {
+		current := counter.Load()
+		if current >= int64(limit) {
+			return nil, false
+		}
+		if counter.CompareAndSwap(current, current+1) {
+			var once sync.Once
+			return func() { once.Do(func() { counter.Add(-1) }) }, true
+		}
+	}
+}
+
+func (t *inFlightTracker) load(authID string) int64 {
+	if value, ok := t.counts.Load(authID); ok {
+		return value.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// InFlight returns the number of requests currently executing on the auth.
+func (m *Manager) InFlight(authID string) int64 {
+	return m.inFlight.load(authID)
+}
+
+// SetSelector replaces the selector used when no selection strategy is configured.
+// It also clears any strategy installed through SetSelectionStrategy.
+func (m *Manager) SetSelector(selector Selector) {
+	if selector == nil {
+		selector = &RoundRobinSelector{}
+	}
+	m.mu.Lock()
+	m.baseSelector = selector
+	m.selector = selector
+	m.mu.Unlock()
+}
+
+// SetSelectionStrategy installs the named strategy as the default and applies per-provider
+// overrides on top. An empty default keeps the selector supplied to NewManager or SetSelector.
+// Strategy instances are reused across calls so their state survives config reloads.
+func (m *Manager) SetSelectionStrategy(strategy string, providers map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defaultSelector := m.baseSelector
+	if name := strings.TrimSpace(strategy); name != "" {
+		selector, err := m.strategySelectorLocked(name)
+		if err != nil {
+			return err
+		}
+		defaultSelector = selector
+	}
+	overrides := make(map[string]Selector, len(providers))
+	for provider, name := range providers {
+		key := strings.ToLower(strings.TrimSpace(provider))
+		if key == "" || strings.TrimSpace(name) == "" {
+			continue
+		}
+		selector, err := m.strategySelectorLocked(name)
+		if err != nil {
+			return fmt.Errorf("provider %s: %w", key, err)
+		}
+		overrides[key] = selector
+	}
+	if len(overrides) == 0 {
+		m.selector = defaultSelector
+		return nil
+	}
+	m.selector = &ProviderSelector{Default: defaultSelector, Providers: overrides}
+	return nil
+}
+
+func (m *Manager) strategySelectorLocked(name string) (Selector, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if selector, ok := m.strategies[key]; ok {
+		return selector, nil
+	}
+	var selector Selector
+	switch key {
+	case StrategyRoundRobin:
+		selector = &RoundRobinSelector{}
+	case StrategyWeighted:
+		selector = WeightedSelector{}
+	case StrategyPriority:
+		selector = &PrioritySelector{}
+	case StrategyLeastInFlight:
+		selector = &LeastInFlightSelector{Counter: m}
+	case StrategyFillFirst:
+		selector = FillFirstSelector{}
+	case StrategyPowerOfTwo:
+		selector = &PowerOfTwoSelector{Scores: m}
+	default:
+		return nil, fmt.Errorf("unknown selection strategy %q", name)
+	}
+	m.strategies[key] = selector
+	return selector, nil
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func strategyTestAuths() []*Auth {
+	return []*Auth{
+		{ID: "a", Provider: "p", Attributes: map[string]string{AttributePriority: "1", AttributeWeight: "0"}},
+		{ID: "b", Provider: "p", Attributes: map[string]string{AttributePriority: "1", AttributeWeight: "3"}},
+		{ID: "c", Provider: "p", Attributes: map[string]string{AttributeWeight: "1"}},
+	}
+}
+
+func TestPrioritySelectorPrefersHigherTier(t *testing.T) {
+	auths := strategyTestAuths()
+	s := &PrioritySelector{}
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		picked, err := s.Pick(context.Background(), "p", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("pick: %v", err)
+		}
+		seen[picked.ID] = true
+	}
+	if !seen["a"] || !seen["b"] || seen["c"] {
+		t.Fatalf("expected rotation within the top tier only, got %v", seen)
+	}
+
+	auths[0].Unavailable, auths[0].NextRetryAfter = true, time.Now().Add(time.Minute)
+	auths[1].Unavailable, auths[1].NextRetryAfter = true, time.Now().Add(time.Minute)
+	picked, err := s.Pick(context.Background(), "p", "", cliproxyexecutor.Options{}, auths)
+	if err != nil || picked.ID != "c" {
+		t.Fatalf("expected lower tier once the top tier is blocked, got %v (%v)", picked, err)
+	}
+}
+
+func TestWeightedSelectorSkipsZeroWeight(t *testing.T) {
+	auths := strategyTestAuths()
+	for i := 0; i < 50; i++ {
+		picked, err := WeightedSelector{}.Pick(context.Background(), "p", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("pick: %v", err)
+		}
+		if picked.ID == "a" {
+			t.Fatal("zero-weight auth should not be picked while others are available")
+		}
+	}
+}
+
+func TestLeastInFlightSelectorAvoidsBusyAuth(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	release := m.inFlight.acquire("a")
+	defer release()
+	s := &LeastInFlightSelector{Counter: m}
+	for i := 0; i < 4; i++ {
+		picked, err := s.Pick(context.Background(), "p", "", cliproxyexecutor.Options{}, strategyTestAuths())
+		if err != nil {
+			t.Fatalf("pick: %v", err)
+		}
+		if picked.ID == "a" {
+			t.Fatal("busy auth picked over idle ones")
+		}
+	}
+}
+
+func TestFillFirstAndStrategyHotSwap(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	if err := m.SetSelectionStrategy(StrategyRoundRobin, map[string]string{"p": StrategyFillFirst}); err != nil {
+		t.Fatalf("set strategy: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		picked, err := m.selector.Pick(context.Background(), "p", "", cliproxyexecutor.Options{}, strategyTestAuths())
+		if err != nil || picked.ID != "a" {
+			t.Fatalf("fill-first should keep picking the first auth, got %v (%v)", picked, err)
+		}
+	}
+	if err := m.SetSelectionStrategy("bogus", nil); err == nil {
+		t.Fatal("expected unknown strategy to be rejected")
+	}
+	if err := m.SetSelectionStrategy("", nil); err != nil {
+		t.Fatalf("reset strategy: %v", err)
+	}
+	if _, ok := m.selector.(*RoundRobinSelector); !ok {
+		t.Fatalf("expected base selector after reset, got %T", m.selector)
+	}
+}
+
+type staticScores map[string]float64
+
+func (s staticScores) Score(authID, _ string) float64 { return s[authID] }
+
+func TestPowerOfTwoSelectorPrefersLowerScore(t *testing.T) {
+	auths := []*Auth{{ID: "fast", Provider: "p"}, {ID: "slow", Provider: "p"}}
+	s := &PowerOfTwoSelector{Scores: staticScores{"fast": 100, "slow": 5000}}
+	for i := 0; i < 10; i++ {
+		picked, err := s.Pick(context.Background(), "p", "m", cliproxyexecutor.Options{}, auths)
+		if err != nil || picked.ID != "fast" {
+			t.Fatalf("expected the faster auth, got %v (%v)", picked, err)
+		}
+	}
+}
+
+func TestScoreTrackerPenalisesErrors(t *testing.T) {
+	tracker := newScoreTracker()
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		tracker.observe(Result{AuthID: "ok", Model: "m", Success: true, Latency: 200 * time.Millisecond}, now)
+		tracker.observe(Result{AuthID: "flaky", Model: "m", Success: i%2 == 0, Latency: 200 * time.Millisecond, Error: &Error{HTTPStatus: 502}}, now)
+		tracker.observe(Result{AuthID: "bad-request", Model: "m", Success: false, Error: &Error{HTTPStatus: 400}}, now)
+	}
+	if tracker.score("flaky", "m") <= tracker.score("ok", "m") {
+		t.Fatalf("expected errors to raise the score: ok=%f flaky=%f", tracker.score("ok", "m"), tracker.score("flaky", "m"))
+	}
+	if got := tracker.score("bad-request", "m"); got != 0 {
+		t.Fatalf("client errors should not count against an auth, got score %f", got)
+	}
+}
+
+func TestAuthScoresDropDisabledAuths(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	ctx := context.Background()
+	if _, err := m.Register(ctx, &Auth{ID: "scored", Provider: "p"}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	m.scores.observe(Result{AuthID: "scored", Model: "m", Success: true, Latency: 100 * time.Millisecond}, time.Now())
+	if got := len(m.AuthScores()); got != 2 {
+		t.Fatalf("expected aggregate and model scores, got %d", got)
+	}
+	if _, err := m.Update(ctx, &Auth{ID: "scored", Provider: "p", Disabled: true}); err != nil {
+		t.Fatalf("update auth: %v", err)
+	}
+	if got := len(m.AuthScores()); got != 0 {
+		t.Fatalf("expected no scores for a disabled auth, got %d", got)
+	}
+	if got := m.Score("scored", "m"); got != 0 {
+		t.Fatalf("expected stats to be dropped, got score %f", got)
+	}
+}
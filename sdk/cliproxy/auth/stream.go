@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// SetStreamTTFTTimeout bounds how long ExecuteStream waits for the first payload chunk
+// before treating the credential as failed and moving on. Zero disables the timeout.
+func (m *Manager) SetStreamTTFTTimeout(timeout time.Duration) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	m.streamTTFTTimeout.Store(int64(timeout))
+}
+
+// StreamTTFTTimeout returns the active time-to-first-token timeout for streams.
+func (m *Manager) StreamTTFTTimeout() time.Duration {
+	return time.Duration(m.streamTTFTTimeout.Load())
+}
+
+func newStreamTTFTTimeoutError(timeout time.Duration) *Error {
+	return &Error{
+		Code:       "stream_ttft_timeout",
+		Message:    fmt.Sprintf("no response from upstream within %s", timeout),
+		Retryable:  true,
+		HTTPStatus: http.StatusGatewayTimeout,
+	}
+}
+
+// awaitFirstPayload buffers stream chunks until the first non-empty payload arrives.
+// Nothing has reached the client at that point, so an error chunk, a timeout or a stream
+// that ends early can still fail over to another credential. open reports whether the
+// stream may still deliver more chunks after the buffered ones.
+func awaitFirstPayload(ctx context.Context, chunks <-chan cliproxyexecutor.StreamChunk, timeout time.Duration) (buffered []cliproxyexecutor.StreamChunk, open bool, err error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		case <-deadline:
+			return nil, true, newStreamTTFTTimeoutError(timeout)
+		case chunk, ok := <-chunks:
+			if !ok {
+				return buffered, false, nil
+			}
+			if chunk.Err != nil {
+				return nil, true, chunk.Err
+			}
+			buffered = append(buffered, chunk)
+			if len(chunk.Payload) > 0 {
+				return buffered, true, nil
+			}
+		}
+	}
+}
+
+// drainStream discards the remaining chunks of an abandoned stream so the executor
+// goroutine producing them can exit.
+func drainStream(chunks <-chan cliproxyexecutor.StreamChunk) {
+	go func() {
+		for range chunks {
+		}
+	}()
+}
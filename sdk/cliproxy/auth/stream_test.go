@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// streamTestExecutor fails streams for the "bad" auth, either with an error chunk or by
+// stalling until canceled, and streams a payload for every other auth.
+type streamTestExecutor struct {
+	retryTestExecutor
+	stall bool
+}
+
+func (e *streamTestExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		if auth.ID != "stream-bad" {
+			out <- cliproxyexecutor.StreamChunk{Payload: []byte("hello")}
+			return
+		}
+		if e.stall {
+			<-ctx.Done()
+			return
+		}
+		out <- cliproxyexecutor.StreamChunk{Err: retryTestStatusError{code: http.StatusServiceUnavailable}}
+	}()
+	return out, nil
+}
+
+func newStreamTestManager(t *testing.T, exec *streamTestExecutor) *Manager {
+	t.Helper()
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(exec)
+	for _, id := range []string{"stream-bad", "stream-good"} {
+		registry.GetGlobalRegistry().RegisterClient(id, exec.provider, []*registry.ModelInfo{{ID: "stream-model"}})
+		authID := id
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: exec.provider, Status: StatusActive}); err != nil {
+			t.Fatalf("register auth: %v", err)
+		}
+	}
+	return m
+}
+
+func collectStream(t *testing.T, chunks <-chan cliproxyexecutor.StreamChunk) string {
+	t.Helper()
+	var out []byte
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error: %v", chunk.Err)
+		}
+		out = append(out, chunk.Payload...)
+	}
+	return string(out)
+}
+
+func TestExecuteStreamFailsOverBeforeFirstByte(t *testing.T) {
+	m := newStreamTestManager(t, &streamTestExecutor{retryTestExecutor: retryTestExecutor{provider: "stream-test"}})
+	chunks, err := m.ExecuteStream(context.Background(), []string{"stream-test"}, cliproxyexecutor.Request{Model: "stream-model"}, cliproxyexecutor.Options{Stream: true})
+	if err != nil {
+		t.Fatalf("expected failover to succeed, got %v", err)
+	}
+	if got := collectStream(t, chunks); got != "hello" {
+		t.Fatalf("unexpected stream payload %q", got)
+	}
+}
+
+func TestExecuteStreamTTFTTimeoutFailsOver(t *testing.T) {
+	m := newStreamTestManager(t, &streamTestExecutor{retryTestExecutor: retryTestExecutor{provider: "stream-test-stall"}, stall: true})
+	m.SetStreamTTFTTimeout(20 * time.Millisecond)
+	start := time.Now()
+	chunks, err := m.ExecuteStream(context.Background(), []string{"stream-test-stall"}, cliproxyexecutor.Request{Model: "stream-model"}, cliproxyexecutor.Options{Stream: true})
+	if err != nil {
+		t.Fatalf("expected failover to succeed, got %v", err)
+	}
+	if got := collectStream(t, chunks); got != "hello" {
+		t.Fatalf("unexpected stream payload %q", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("ttft timeout not enforced, took %s", elapsed)
+	}
+}
+
+func TestExecuteStreamClientCancelDoesNotMarkAuth(t *testing.T) {
+	m := newStreamTestManager(t, &streamTestExecutor{retryTestExecutor: retryTestExecutor{provider: "stream-test-cancel"}, stall: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.ExecuteStream(ctx, []string{"stream-test-cancel"}, cliproxyexecutor.Request{Model: "stream-model"}, cliproxyexecutor.Options{Stream: true}); err == nil {
+		t.Fatal("expected the canceled stream to fail")
+	}
+	auth, ok := m.GetByID("stream-bad")
+	if !ok {
+		t.Fatal("auth not found")
+	}
+	if auth.LastError != nil || auth.Unavailable || len(auth.ModelStates) > 0 {
+		t.Fatalf("expected the client cancel to leave the auth untouched, got %+v", auth)
+	}
+}
@@ -7,13 +7,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
+	virtualkeys "github.com/router-for-me/CLIProxyAPI/v6/internal/access/virtual_keys"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrails"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
@@ -185,6 +189,7 @@ func (s *Service) ensureWebsocketGateway() {
 	}
 	opts := wsrelay.Options{
 		Path:           "/v1/ws",
+		CheckOrigin:    s.wsCheckOrigin,
 		OnConnected:    s.wsOnConnected,
 		OnDisconnected: s.wsOnDisconnected,
 		LogDebugf:      log.Debugf,
@@ -194,6 +199,18 @@ func (s *Service) ensureWebsocketGateway() {
 	s.wsGateway = wsrelay.NewManager(opts)
 }
 
+// wsCheckOrigin applies the current cors.websocket policy to relay upgrades.
+func (s *Service) wsCheckOrigin(r *http.Request) bool {
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	var policy config.CORSPolicy
+	if cfg != nil {
+		policy = cfg.CORS.Websocket
+	}
+	return policy.AllowsWebsocket(r.Header.Get("Origin"))
+}
+
 func (s *Service) wsOnConnected(channelID string) {
 	if s == nil || channelID == "" {
 		return
@@ -254,6 +271,7 @@ func (s *Service) applyCoreAuthAddOrUpdate(ctx context.Context, auth *coreauth.A
 		auth.CreatedAt = existing.CreatedAt
 		auth.LastRefreshedAt = existing.LastRefreshedAt
 		auth.NextRefreshAfter = existing.NextRefreshAfter
+		coreauth.PreserveRuntimeState(auth, existing)
 		if _, err := s.coreManager.Update(ctx, auth); err != nil {
 			log.Errorf("failed to update auth %s: %v", auth.ID, err)
 		}
@@ -363,6 +381,49 @@ func (s *Service) rebindExecutors() {
 	}
 }
 
+// applyCoreManagerConfig pushes config driven execution settings into the core auth manager.
+// It runs at startup and after every config reload so changes take effect without a restart.
+func (s *Service) applyCoreManagerConfig(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	s.coreManager.SetRetryPolicy(coreauth.RetryPolicy{
+		MaxRetries:  cfg.RequestRetry,
+		StatusCodes: append([]int(nil), cfg.RetryPolicy.StatusCodes...),
+		BaseDelay:   time.Duration(cfg.RetryPolicy.BaseDelayMS) * time.Millisecond,
+		MaxDelay:    time.Duration(cfg.RetryPolicy.MaxDelayMS) * time.Millisecond,
+		MaxElapsed:  time.Duration(cfg.RetryPolicy.MaxElapsedSeconds) * time.Second,
+	})
+	s.coreManager.SetStreamTTFTTimeout(time.Duration(cfg.StreamTTFTTimeoutSeconds) * time.Second)
+	s.coreManager.SetModelFallbacks(cfg.ModelFallbacks)
+	s.coreManager.SetConcurrency(coreauth.ConcurrencyConfig{
+		DefaultMax:   cfg.Concurrency.DefaultMax,
+		Providers:    cfg.Concurrency.Providers,
+		QueueSize:    cfg.Concurrency.QueueSize,
+		QueueTimeout: time.Duration(cfg.Concurrency.QueueTimeoutSeconds) * time.Second,
+	})
+	s.coreManager.SetHedging(coreauth.HedgeConfig{
+		Enabled:    cfg.Hedging.Enabled,
+		Percentile: cfg.Hedging.Percentile,
+		MinSamples: cfg.Hedging.MinSamples,
+		MinDelay:   time.Duration(cfg.Hedging.MinDelayMS) * time.Millisecond,
+		MaxRatio:   cfg.Hedging.MaxRatio,
+	})
+	if err := s.coreManager.SetSelectionStrategy(cfg.Routing.Strategy, cfg.Routing.Providers); err != nil {
+		log.Warnf("invalid routing config, keeping previous selection strategy: %v", err)
+	}
+	if err := guardrails.Default().Update(cfg.Guardrails); err != nil {
+		log.Warnf("invalid guardrails config, keeping previous rules: %v", err)
+	}
+	affinity := cfg.Routing.SessionAffinity
+	s.coreManager.SetSessionAffinity(coreauth.SessionAffinityConfig{
+		Enabled:    affinity.Enabled,
+		Header:     affinity.Header,
+		TTL:        time.Duration(affinity.TTLSeconds) * time.Second,
+		MaxEntries: affinity.MaxEntries,
+	})
+}
+
 // Run starts the service and blocks until the context is cancelled or the server stops.
 // It initializes all components including authentication, file watching, HTTP server,
 // and starts processing requests. The method blocks until the context is cancelled.
@@ -399,6 +460,13 @@ func (s *Service) Run(ctx context.Context) error {
 			log.Warnf("failed to load auth store: %v", errLoad)
 		}
 	}
+	s.applyCoreManagerConfig(s.cfg)
+	if store, ok := sdkAuth.GetTokenStore().(ratelimit.CounterStore); ok {
+		ratelimit.Default().Start(context.Background(), store, time.Minute)
+	}
+	if store, ok := sdkAuth.GetTokenStore().(virtualkeys.Store); ok {
+		virtualkeys.Default().Start(context.Background(), store, 30*time.Second)
+	}
 
 	tokenResult, err := s.tokenProvider.Load(ctx, s.cfg)
 	if err != nil && !errors.Is(err, context.Canceled) {
@@ -482,6 +550,7 @@ func (s *Service) Run(ctx context.Context) error {
 		s.cfgMu.Lock()
 		s.cfg = newCfg
 		s.cfgMu.Unlock()
+		s.applyCoreManagerConfig(newCfg)
 		s.rebindExecutors()
 	}
 
@@ -508,6 +577,7 @@ func (s *Service) Run(ctx context.Context) error {
 		interval := 15 * time.Minute
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
+		s.coreManager.StartStateSync(context.Background())
 	}
 
 	select {
@@ -545,6 +615,15 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
+			s.coreManager.StopStateSync()
+			if err := s.coreManager.FlushState(ctx); err != nil {
+				log.Warnf("failed to persist auth runtime state: %v", err)
+			}
+		}
+		virtualkeys.Default().Stop()
+		ratelimit.Default().Stop()
+		if err := ratelimit.Default().Flush(ctx); err != nil {
+			log.Warnf("failed to persist client rate limit counters: %v", err)
 		}
 		if s.watcher != nil {
 			if err := s.watcher.Stop(); err != nil {
@@ -710,6 +789,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 							OwnedBy:     compat.Name,
 							Type:        "openai-compatibility",
 							DisplayName: m.Name,
+							Kind:        strings.ToLower(strings.TrimSpace(m.Kind)),
 						})
 					}
 					// Register and return
@@ -18,7 +18,15 @@ type Record struct {
 	Source      string
 	RequestedAt time.Time
 	Failed      bool
-	Detail      Detail
+	// Affinity is the session affinity outcome ("hit", "miss") or empty when not applicable.
+	Affinity string
+	// Hedge is the attempt's role ("primary", "hedge") when the request launched a hedge.
+	Hedge string
+	// Canceled marks an attempt that lost a hedge race; it is neither a success nor a failure.
+	Canceled bool
+	// QueueWait is how long the request waited for a free credential under concurrency limits.
+	QueueWait time.Duration
+	Detail    Detail
 }
 
 // Detail holds the token usage breakdown.
@@ -17,6 +17,116 @@ type SDKConfig struct {
 
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
+
+	// VirtualKeys accepts the client keys issued through the management API in addition to APIKeys.
+	VirtualKeys bool `yaml:"virtual-keys,omitempty" json:"virtual-keys,omitempty"`
+
+	// JWTAuth accepts bearer JWTs issued by an external identity provider.
+	JWTAuth *JWTAuthConfig `yaml:"jwt-auth,omitempty" json:"jwt-auth,omitempty"`
+
+	// MTLSAuth accepts clients presenting a certificate verified against the TLS client CA.
+	MTLSAuth *MTLSAuthConfig `yaml:"mtls-auth,omitempty" json:"mtls-auth,omitempty"`
+
+	// APIKeyPolicies restricts the models, providers, credentials and budgets of individual client keys.
+	APIKeyPolicies []APIKeyPolicy `yaml:"api-key-policies,omitempty" json:"api-key-policies,omitempty"`
+
+	// Transcription configures the /v1/audio/transcriptions endpoint.
+	Transcription TranscriptionConfig `yaml:"transcription,omitempty" json:"transcription,omitempty"`
+}
+
+// DefaultTranscriptionModel transcribes audio when the config does not name a model.
+const DefaultTranscriptionModel = "gemini-2.5-flash"
+
+// TranscriptionConfig selects the multimodal model that serves Whisper-style transcription requests.
+type TranscriptionConfig struct {
+	// Model transcribes audio whenever the requested model, such as whisper-1, is not served by
+	// the proxy; defaults to DefaultTranscriptionModel.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// Instructions are appended to the built-in transcription instruction, e.g. to keep filler words.
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+}
+
+// APIKeyPolicy restricts what requests authenticated with one client API key may reach and
+// how much it may consume. Keys without a policy keep unrestricted access.
+type APIKeyPolicy struct {
+	// APIKey is the client key the policy applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// AllowedModels lists model globs the key may call; empty allows every model.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// DeniedModels lists model globs the key may never call; they take precedence over AllowedModels.
+	DeniedModels []string `yaml:"denied-models,omitempty" json:"denied-models,omitempty"`
+
+	// AllowedProviders limits the providers that may serve the key; empty allows every provider.
+	AllowedProviders []string `yaml:"allowed-providers,omitempty" json:"allowed-providers,omitempty"`
+
+	// APIKeyCredentialsOnly keeps the key off OAuth subscription accounts so it only consumes API keys.
+	APIKeyCredentialsOnly bool `yaml:"api-key-credentials-only,omitempty" json:"api-key-credentials-only,omitempty"`
+
+	// RequestsPerMinute caps the requests the key may send per minute; 0 is unlimited.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// TokensPerMinute caps the tokens the key may consume per minute; 0 is unlimited.
+	TokensPerMinute int64 `yaml:"tokens-per-minute,omitempty" json:"tokens-per-minute,omitempty"`
+
+	// DailyTokens caps the tokens the key may consume per UTC day; 0 is unlimited.
+	DailyTokens int64 `yaml:"daily-tokens,omitempty" json:"daily-tokens,omitempty"`
+
+	// MonthlyTokens caps the tokens the key may consume per UTC calendar month; 0 is unlimited.
+	MonthlyTokens int64 `yaml:"monthly-tokens,omitempty" json:"monthly-tokens,omitempty"`
+}
+
+// APIKeyPolicy returns the policy configured for the client key, or nil when the key is unrestricted.
+func (c *SDKConfig) APIKeyPolicy(key string) *APIKeyPolicy {
+	if c == nil || key == "" {
+		return nil
+	}
+	for i := range c.APIKeyPolicies {
+		if c.APIKeyPolicies[i].APIKey == key {
+			return &c.APIKeyPolicies[i]
+		}
+	}
+	return nil
+}
+
+// JWTAuthConfig configures validation of bearer JWTs against a JSON Web Key Set.
+type JWTAuthConfig struct {
+	// JWKSFile is a local JSON Web Key Set file.
+	JWKSFile string `yaml:"jwks-file,omitempty" json:"jwks-file,omitempty"`
+
+	// JWKSURL is fetched when JWKSFile is empty, e.g. the jwks_uri of an OIDC issuer.
+	JWKSURL string `yaml:"jwks-url,omitempty" json:"jwks-url,omitempty"`
+
+	// Issuer must match the iss claim. Required.
+	Issuer string `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+
+	// Audiences lists accepted aud values; the token must carry at least one. Required.
+	Audiences []string `yaml:"audiences,omitempty" json:"audiences,omitempty"`
+
+	// PrincipalClaim names the claim used as the client identity; defaults to "sub".
+	// Dotted paths address nested claims.
+	PrincipalClaim string `yaml:"principal-claim,omitempty" json:"principal-claim,omitempty"`
+
+	// MetadataClaims are copied into the access metadata for downstream policies.
+	MetadataClaims []string `yaml:"metadata-claims,omitempty" json:"metadata-claims,omitempty"`
+
+	// ClockSkewSeconds tolerates clock drift when checking exp and nbf; defaults to 60.
+	ClockSkewSeconds int `yaml:"clock-skew-seconds,omitempty" json:"clock-skew-seconds,omitempty"`
+
+	// RefreshIntervalSeconds controls how often the key set is reloaded; defaults to 3600.
+	RefreshIntervalSeconds int `yaml:"refresh-interval-seconds,omitempty" json:"refresh-interval-seconds,omitempty"`
+}
+
+// MTLSAuthConfig maps verified client certificates to principals.
+type MTLSAuthConfig struct {
+	// PrincipalFrom selects the identity: "subject-cn" (default), "san-dns", "san-uri" or "san-email".
+	// The first SAN of the chosen type is used.
+	PrincipalFrom string `yaml:"principal-from,omitempty" json:"principal-from,omitempty"`
+
+	// AllowedPrincipals lists globs the principal must match; empty accepts every verified certificate.
+	AllowedPrincipals []string `yaml:"allowed-principals,omitempty" json:"allowed-principals,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -49,6 +159,24 @@ const (
 
 	// DefaultAccessProviderName is applied when no provider name is supplied.
 	DefaultAccessProviderName = "config-inline"
+
+	// AccessProviderTypeVirtualKey is the built-in provider validating keys issued at runtime.
+	AccessProviderTypeVirtualKey = "virtual-key"
+
+	// VirtualKeyAccessProviderName names the virtual key provider instance.
+	VirtualKeyAccessProviderName = "virtual-keys"
+
+	// AccessProviderTypeJWT is the built-in provider validating bearer JWTs.
+	AccessProviderTypeJWT = "jwt"
+
+	// JWTAccessProviderConfigKey holds the JWTAuthConfig in the provider config map.
+	JWTAccessProviderConfigKey = "jwt-auth"
+
+	// AccessProviderTypeMTLS is the built-in provider authenticating client certificates.
+	AccessProviderTypeMTLS = "mtls"
+
+	// MTLSAccessProviderConfigKey holds the MTLSAuthConfig in the provider config map.
+	MTLSAccessProviderConfigKey = "mtls-auth"
 )
 
 // ConfigAPIKeyProvider returns the first inline API key provider if present.
@@ -80,3 +208,33 @@ func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
 	}
 	return provider
 }
+
+// RuntimeAccessProviders returns the built-in providers enabled by top-level settings. They
+// are appended after the configured or inline providers.
+func (c *SDKConfig) RuntimeAccessProviders() []*AccessProvider {
+	if c == nil {
+		return nil
+	}
+	var providers []*AccessProvider
+	if c.VirtualKeys {
+		providers = append(providers, &AccessProvider{
+			Name: VirtualKeyAccessProviderName,
+			Type: AccessProviderTypeVirtualKey,
+		})
+	}
+	if c.JWTAuth != nil && (c.JWTAuth.JWKSFile != "" || c.JWTAuth.JWKSURL != "") {
+		providers = append(providers, &AccessProvider{
+			Name:   AccessProviderTypeJWT,
+			Type:   AccessProviderTypeJWT,
+			Config: map[string]any{JWTAccessProviderConfigKey: *c.JWTAuth},
+		})
+	}
+	if c.MTLSAuth != nil {
+		providers = append(providers, &AccessProvider{
+			Name:   AccessProviderTypeMTLS,
+			Type:   AccessProviderTypeMTLS,
+			Config: map[string]any{MTLSAccessProviderConfigKey: *c.MTLSAuth},
+		})
+	}
+	return providers
+}